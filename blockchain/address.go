@@ -0,0 +1,105 @@
+package blockchain
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// The prefixes of addresses locked with a script, which are followed by a Hash160 hash in hex. Coins sent to a key
+// hash address can only be spent with a signature from the key with that hash, and coins sent to a script hash
+// address can only be spent by giving a script with that hash along with whatever the script needs. Any other
+// address is a plain name, whose coins are spent by naming it.
+const (
+	KeyHashAddressPrefix = "pkh:"
+	ScriptAddressPrefix  = "sh:"
+)
+
+// KeyHashAddress returns the address of a public key, paid without knowing the key itself
+func KeyHashAddress(pk []byte) string {
+	return KeyHashAddressPrefix + hex.EncodeToString(Hash160(pk))
+}
+
+// ScriptHashAddress returns the address of a script, which anyone can pay without knowing the script itself
+func ScriptHashAddress(s Script) string {
+	return ScriptAddressPrefix + hex.EncodeToString(Hash160(s))
+}
+
+// IsScriptHashAddress checks whether an address is a script hash address, well formed or not
+func IsScriptHashAddress(a string) bool {
+	return strings.HasPrefix(a, ScriptAddressPrefix)
+}
+
+// NeedsSignatures checks whether an address is locked with a script, so its coins can't be spent by naming it
+func NeedsSignatures(a string) bool {
+	return strings.HasPrefix(a, KeyHashAddressPrefix) || IsScriptHashAddress(a)
+}
+
+// ScriptHashScript returns the script locking an output to the script with the given Hash160 hash. It is spent with
+// a ScriptSig pushing the data the script needs followed by the script itself, which is then run on that data.
+func ScriptHashScript(h []byte) Script {
+	return Script{}.AddOp(OpHash160).AddData(h).AddOp(OpEqual)
+}
+
+// Fills in the ScriptSig of input in spending an output locked to the hash of a script, pushing the data the
+// script needs followed by the script, and checks the scripts pass
+func setScriptHashSig(t *Transaction, in int, d, s Script) error {
+	t.Inputs[in].ScriptSig = append(Script{}, d...).AddData(s)
+
+	if err := VerifyScript(t.Inputs[in].ScriptSig, ScriptHashScript(Hash160(s)), t, in); err != nil {
+		return fmt.Errorf("input %d: %w", in, err)
+	}
+
+	return nil
+}
+
+// Checks whether a script is one made by ScriptHashScript
+func (s Script) isScriptHash() bool {
+	return len(s) == 23 && Opcode(s[0]) == OpHash160 && s[1] == 20 && Opcode(s[22]) == OpEqual
+}
+
+// Returns the key hash a script made by PubKeyHashScript is locked to, or nil if it isn't one
+func (s Script) pubKeyHash() []byte {
+	if len(s) == 25 && Opcode(s[0]) == OpDup && Opcode(s[1]) == OpHash160 && s[2] == 20 &&
+		Opcode(s[23]) == OpEqualVerify && Opcode(s[24]) == OpCheckSig {
+		return s[3:23]
+	}
+
+	return nil
+}
+
+// AddressScript returns the script outputs paying an address must be locked with. Outputs to key hash and script
+// hash addresses are locked to their hash, and outputs to plain names aren't locked with a script.
+func AddressScript(a string) (Script, error) {
+	if NeedsSignatures(a) == false {
+		return nil, nil
+	}
+
+	p := ScriptAddressPrefix
+	if IsScriptHashAddress(a) == false {
+		p = KeyHashAddressPrefix
+	}
+
+	h, err := hex.DecodeString(strings.TrimPrefix(a, p))
+	if err != nil || len(h) != 20 {
+		return nil, fmt.Errorf("%q is not a valid address", a)
+	}
+
+	if p == KeyHashAddressPrefix {
+		return PubKeyHashScript(h), nil
+	}
+
+	return ScriptHashScript(h), nil
+}
+
+// Checks whether an output is locked the way its address needs. Outputs to plain names can be locked with
+// any script, or none.
+func (o *TxOutput) hasAddressScript() bool {
+	s, err := AddressScript(o.PubKey)
+	if err != nil {
+		return false
+	}
+
+	return s == nil || bytes.Equal(s, o.ScriptPubKey)
+}
@@ -0,0 +1,113 @@
+package blockchain
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Amount is a value of coins in base units, the smallest amount that can be sent
+type Amount uint64
+
+// MaxMoney is the most any amount, or sum of amounts, on the chain can be worth. Anything above it can only
+// come from a crafted or overflowing value so it is rejected.
+const MaxMoney Amount = 21000000 * 100000000
+
+// MaxDecimals is the most decimal places amounts can be written with, enough to show every base unit of MaxMoney
+const MaxDecimals = 16
+
+// Errors returned when amounts are out of range or can't be read
+var (
+	ErrAmountRange = errors.New("amount out of range")
+	ErrBadAmount   = errors.New("not a valid amount")
+)
+
+// Valid checks an amount is no more than MaxMoney
+func (a Amount) Valid() bool {
+	return a <= MaxMoney
+}
+
+// Add returns the sum of two amounts, failing if either of them or the sum is more than MaxMoney
+func (a Amount) Add(b Amount) (Amount, error) {
+	// Both amounts are at most MaxMoney so their sum can't wrap around
+	if a.Valid() == false || b.Valid() == false || a+b > MaxMoney {
+		return 0, fmt.Errorf("%w: %d + %d is more than %d", ErrAmountRange, a, b, MaxMoney)
+	}
+
+	return a + b, nil
+}
+
+// Sub returns the difference of two amounts, failing if it would be negative
+func (a Amount) Sub(b Amount) (Amount, error) {
+	if b > a {
+		return 0, fmt.Errorf("%w: %d - %d is negative", ErrAmountRange, a, b)
+	}
+
+	return a - b, nil
+}
+
+// SumAmounts adds up some amounts, failing if the total is more than MaxMoney
+func SumAmounts(as ...Amount) (Amount, error) {
+	var t Amount
+
+	for _, a := range as {
+		var err error
+
+		t, err = t.Add(a)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return t, nil
+}
+
+// Format writes an amount with d decimal places, so 150 with 2 decimal places is 1.50
+func (a Amount) Format(d int) string {
+	s := strconv.FormatUint(uint64(a), 10)
+
+	if d <= 0 {
+		return s
+	}
+
+	// Pad with zeros so there is at least one digit before the decimal point
+	if len(s) <= d {
+		s = strings.Repeat("0", d-len(s)+1) + s
+	}
+
+	return s[:len(s)-d] + "." + s[len(s)-d:]
+}
+
+// ParseAmount reads an amount written with up to d decimal places, so 1.5 with 2 decimal places is 150.
+// Negative amounts, amounts with more decimal places, and amounts more than MaxMoney are rejected.
+func ParseAmount(s string, d int) (Amount, error) {
+	if d < 0 || d > MaxDecimals {
+		return 0, fmt.Errorf("%w: decimal places must be between 0 and %d", ErrBadAmount, MaxDecimals)
+	}
+
+	// Split the whole and fractional parts
+	w, f := s, ""
+
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		w, f = s[:i], s[i+1:]
+	}
+
+	if len(f) > d {
+		return 0, fmt.Errorf("%w: %q has more than %d decimal places", ErrBadAmount, s, d)
+	}
+
+	// Shift the fractional part up to base units, then read the digits as one number
+	digits := w + f + strings.Repeat("0", d-len(f))
+
+	if w == "" && f == "" || strings.Trim(digits, "0123456789") != "" {
+		return 0, fmt.Errorf("%w: %q", ErrBadAmount, s)
+	}
+
+	v, err := strconv.ParseUint(digits, 10, 64)
+	if err != nil || Amount(v).Valid() == false {
+		return 0, fmt.Errorf("%w: %q is more than %s", ErrAmountRange, s, MaxMoney.Format(d))
+	}
+
+	return Amount(v), nil
+}
@@ -0,0 +1,105 @@
+package blockchain
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestAmountArithmetic(t *testing.T) {
+	tests := []struct {
+		name string
+		op   func() (Amount, error)
+		want Amount
+		err  error
+	}{
+		{"add", func() (Amount, error) { return Amount(2).Add(3) }, 5, nil},
+		{"add up to the most money", func() (Amount, error) { return (MaxMoney - 1).Add(1) }, MaxMoney, nil},
+		{"add past the most money", func() (Amount, error) { return MaxMoney.Add(1) }, 0, ErrAmountRange},
+		{"add an amount past the most money", func() (Amount, error) { return Amount(0).Add(MaxMoney + 1) }, 0, ErrAmountRange},
+		{"add amounts that would wrap around", func() (Amount, error) { return Amount(1 << 63).Add(1 << 63) }, 0, ErrAmountRange},
+		{"sub", func() (Amount, error) { return Amount(5).Sub(3) }, 2, nil},
+		{"sub to zero", func() (Amount, error) { return Amount(5).Sub(5) }, 0, nil},
+		{"sub below zero", func() (Amount, error) { return Amount(3).Sub(5) }, 0, ErrAmountRange},
+		{"sum", func() (Amount, error) { return SumAmounts(1, 2, 3) }, 6, nil},
+		{"sum nothing", func() (Amount, error) { return SumAmounts() }, 0, nil},
+		{"sum past the most money", func() (Amount, error) { return SumAmounts(MaxMoney/2, MaxMoney/2, MaxMoney/2) }, 0, ErrAmountRange},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			a, err := tc.op()
+			if errors.Is(err, tc.err) == false || a != tc.want {
+				t.Errorf("got %d, %v, want %d, %v", a, err, tc.want, tc.err)
+			}
+		})
+	}
+}
+
+func TestFormatAmount(t *testing.T) {
+	tests := []struct {
+		a    Amount
+		d    int
+		want string
+	}{
+		{150, 0, "150"},
+		{150, 2, "1.50"},
+		{5, 2, "0.05"},
+		{0, 3, "0.000"},
+		{100000000, 8, "1.00000000"},
+		{MaxMoney, 8, "21000000.00000000"},
+		{150, -1, "150"},
+	}
+
+	for _, tc := range tests {
+		if s := tc.a.Format(tc.d); s != tc.want {
+			t.Errorf("%d.Format(%d) = %q, want %q", tc.a, tc.d, s, tc.want)
+		}
+	}
+}
+
+func TestParseAmount(t *testing.T) {
+	tests := []struct {
+		s    string
+		d    int
+		want Amount
+		err  error
+	}{
+		{"150", 0, 150, nil},
+		{"1.5", 2, 150, nil},
+		{"1.50", 2, 150, nil},
+		{"0.05", 2, 5, nil},
+		{".05", 2, 5, nil},
+		{"1.", 2, 100, nil},
+		{"007", 0, 7, nil},
+		{"21000000.00000000", 8, MaxMoney, nil},
+		{"21000000.00000001", 8, 0, ErrAmountRange},
+		{"99999999999999999999", 0, 0, ErrAmountRange},
+		{"1.5", 0, 0, ErrBadAmount},
+		{"1.505", 2, 0, ErrBadAmount},
+		{"-1", 0, 0, ErrBadAmount},
+		{"+1", 0, 0, ErrBadAmount},
+		{"1e3", 0, 0, ErrBadAmount},
+		{"1.2.3", 4, 0, ErrBadAmount},
+		{" 1", 0, 0, ErrBadAmount},
+		{"", 0, 0, ErrBadAmount},
+		{".", 2, 0, ErrBadAmount},
+		{"1", -1, 0, ErrBadAmount},
+		{"1", MaxDecimals + 1, 0, ErrBadAmount},
+	}
+
+	for _, tc := range tests {
+		a, err := ParseAmount(tc.s, tc.d)
+		if errors.Is(err, tc.err) == false || a != tc.want {
+			t.Errorf("ParseAmount(%q, %d) = %d, %v, want %d, %v", tc.s, tc.d, a, err, tc.want, tc.err)
+		}
+	}
+
+	// Anything formatted reads back the same
+	for _, a := range []Amount{0, 1, 99, 100, 123456789, MaxMoney} {
+		for d := 0; d <= MaxDecimals; d++ {
+			if p, err := ParseAmount(a.Format(d), d); err != nil || p != a {
+				t.Errorf("ParseAmount(%q, %d) = %d, %v, want %d", a.Format(d), d, p, err, a)
+			}
+		}
+	}
+}
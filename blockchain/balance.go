@@ -0,0 +1,105 @@
+package blockchain
+
+import (
+	"fmt"
+)
+
+// Balance is the value of an address's unspent outputs split by whether they can be spent yet. Confirmed outputs
+// have at least the confirmations asked for and can be spent, unconfirmed ones have fewer or are paid by pending
+// transactions, and immature ones are coinbase outputs too young to spend. Outputs pending transactions spend
+// aren't counted, they are as good as gone.
+type Balance struct {
+	Confirmed   Amount `json:"confirmed"`
+	Unconfirmed Amount `json:"unconfirmed"`
+	Immature    Amount `json:"immature"`
+}
+
+// Spendable returns the value of the outputs that can be spent in the next block, confirmed or not
+func (b Balance) Spendable() (Amount, error) {
+	return b.Confirmed.Add(b.Unconfirmed)
+}
+
+// Total returns the value of every unspent output, whether it can be spent yet or not
+func (b Balance) Total() (Amount, error) {
+	s, err := b.Spendable()
+	if err != nil {
+		return 0, err
+	}
+
+	return s.Add(b.Immature)
+}
+
+// UnspentEntry describes an unspent output of an address, with how many blocks confirm it, whether it can be
+// spent in the next block and whether it is paid by a pending transaction, which has no confirmations
+type UnspentEntry struct {
+	TxID          []byte
+	Out           int
+	Value         Amount
+	Confirmations int
+	Coinbase      bool
+	Spendable     bool
+	Pending       bool
+}
+
+// ListUnspent returns the unspent outputs of an address with at least mc confirmations, oldest first. Outputs
+// pending transactions spend are left out, and when mc is 0 the outputs pending transactions pay to the address
+// are listed last, in the order they arrived.
+func (bc *BlockChain) ListUnspent(a string, mc int) []UnspentEntry {
+	var es []UnspentEntry
+
+	// Confirmations count up to the latest block, and outputs are spent in the block after it
+	h, m := bc.Height(), bc.CoinbaseMaturity()
+
+	mp := bc.Mempool()
+	ps := spentOutputs(mp)
+
+	for _, u := range bc.FindUnspentOutputs(a) {
+		c := h - u.Height + 1
+
+		if _, ok := ps[fmt.Sprintf("%x:%d", u.TxID, u.Out)]; ok || c < mc {
+			continue
+		}
+
+		es = append(es, UnspentEntry{u.TxID, u.Out, u.Value, c, u.Coinbase, u.Mature(h+1, m), false})
+	}
+
+	if mc > 0 {
+		return es
+	}
+
+	// A pending output can be spent in the next block along with the transaction paying it
+	for _, t := range mp {
+		for i, o := range t.Outputs {
+			if _, ok := ps[fmt.Sprintf("%x:%d", t.ID, i)]; ok || o.CanBeUnlocked(a) == false {
+				continue
+			}
+
+			es = append(es, UnspentEntry{t.ID, i, o.Value, 0, false, true, true})
+		}
+	}
+
+	return es
+}
+
+// GetBalances returns the balance of an address, counting pending outputs and outputs with fewer than mc
+// confirmations as unconfirmed
+func (bc *BlockChain) GetBalances(a string, mc int) Balance {
+	var b Balance
+
+	for _, e := range bc.ListUnspent(a, 0) {
+		var err error
+
+		switch {
+		case e.Spendable == false:
+			b.Immature, err = b.Immature.Add(e.Value)
+		case e.Pending || e.Confirmations < mc:
+			b.Unconfirmed, err = b.Unconfirmed.Add(e.Value)
+		default:
+			b.Confirmed, err = b.Confirmed.Add(e.Value)
+		}
+
+		HandleError(err)
+	}
+
+	return b
+}
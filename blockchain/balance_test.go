@@ -0,0 +1,71 @@
+package blockchain
+
+import (
+	"testing"
+)
+
+func TestPendingBalances(t *testing.T) {
+	bc, done := newTestChain(t, "alice", true)
+	defer done()
+
+	gt, _, err := bc.FindTransaction(bc.FindUnspentOutputs("alice")[0].TxID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Alice pays bob 60 of the 100 and takes 30 back as change, then bob pays 50 of that to carol before either is mined
+	pay := Transaction{nil, []TxInput{{gt.ID, 0, "alice", MaxSequence, nil}}, []TxOutput{{60, "bob", nil}, {30, "alice", nil}}, TxVersion, 0}
+	pay.SetID()
+
+	if err := bc.AcceptTransaction(&pay); err != nil {
+		t.Fatal(err)
+	}
+
+	if b := bc.GetBalances("alice", 1); b != (Balance{0, 30, 0}) {
+		t.Errorf("GetBalances(alice) = %+v, want only the pending change", b)
+	}
+
+	if b := bc.GetBalances("bob", 0); b != (Balance{0, 60, 0}) {
+		t.Errorf("GetBalances(bob) = %+v, want 60 unconfirmed", b)
+	}
+
+	// The genesis output is spent, so only the pending change is left, and only when no confirmations are asked for
+	if es := bc.ListUnspent("alice", 1); len(es) != 0 {
+		t.Errorf("ListUnspent(alice, 1) = %+v, want nothing", es)
+	}
+
+	es := bc.ListUnspent("alice", 0)
+	if len(es) != 1 || es[0].Out != 1 || es[0].Value != 30 || es[0].Confirmations != 0 || es[0].Pending == false || es[0].Spendable == false {
+		t.Errorf("ListUnspent(alice, 0) = %+v, want the pending change", es)
+	}
+
+	fwd := Transaction{nil, []TxInput{{pay.ID, 0, "bob", MaxSequence, nil}}, []TxOutput{{50, "carol", nil}}, TxVersion, 0}
+	fwd.SetID()
+
+	if err := bc.AcceptTransaction(&fwd); err != nil {
+		t.Fatal(err)
+	}
+
+	if es := bc.ListUnspent("bob", 0); len(es) != 0 {
+		t.Errorf("ListUnspent(bob, 0) = %+v, want nothing once the pending output is spent", es)
+	}
+
+	if b := bc.GetBalances("carol", 1); b != (Balance{0, 50, 0}) {
+		t.Errorf("GetBalances(carol) = %+v, want 50 unconfirmed", b)
+	}
+
+	// Once mined the outputs are confirmed like any other
+	if _, err := bc.Generate("miner", 1); err != nil {
+		t.Fatal(err)
+	}
+
+	for a, w := range map[string]Balance{"alice": {30, 0, 0}, "bob": {}, "carol": {50, 0, 0}} {
+		if b := bc.GetBalances(a, 1); b != w {
+			t.Errorf("GetBalances(%s) after mining = %+v, want %+v", a, b, w)
+		}
+	}
+
+	if es := bc.ListUnspent("carol", 0); len(es) != 1 || es[0].Pending || es[0].Confirmations != 1 {
+		t.Errorf("ListUnspent(carol, 0) after mining = %+v, want one confirmed output", es)
+	}
+}
@@ -4,28 +4,39 @@ package blockchain
 import (
 	"bytes"
 	"crypto/sha256"
-	"encoding/gob"
 	"fmt"
+	"io"
 	"log"
+	"os"
 )
 
-// Block stores all the parts of a block, including the hash of the previous block
+// Block stores all the parts of a block, including the hash of the previous block, the version it is encoded with,
+// the unix time it was created and the difficulty it was proved at
 type Block struct {
 	Hash         []byte
 	Transactions []*Transaction
 	PreviousHash []byte
 	Counter      int
+	Version      byte
+	Timestamp    int64
+	Difficulty   int
 }
 
-// HashTransactions hashes the transactions on a block
+// HashTransactions hashes the transactions on a block. From block version 3 it hashes the hash of each
+// transaction's whole encoding, as version 4 transaction IDs leave out the script sigs.
 func (b *Block) HashTransactions() []byte {
 	// Create holding variable for the transaction hashes and the return hash
 	var th [][]byte
 	var h [32]byte
 
-	// For each of the blocks transactions, append the ID to the transaction hashes slice
+	// For each of the blocks transactions, append the hash of its encoding, or its ID for older blocks, to the
+	// transaction hashes slice
 	for _, t := range b.Transactions {
-		th = append(th, t.ID)
+		if b.Version >= 3 {
+			th = append(th, txHash(t.Serialise()))
+		} else {
+			th = append(th, t.ID)
+		}
 	}
 
 	// Finally create a hash of the transaction hashes
@@ -35,11 +46,40 @@ func (b *Block) HashTransactions() []byte {
 
 }
 
-// CreateBlock takes some data and a previous hash and returns a new block
-func CreateBlock(t []*Transaction, ph []byte) *Block {
-	// Create a new instance of Block
-	b := &Block{[]byte{}, t, ph, 0}
+// CreateBlock takes some data, a previous hash and a difficulty and returns a new block created at the clock's time
+func CreateBlock(t []*Transaction, ph []byte, d int) *Block {
+	return CreateBlockAt(t, ph, Clock().Unix(), d)
+}
+
+// CreateBlockAt takes some data, a previous hash, a timestamp and a difficulty and returns a new block
+func CreateBlockAt(t []*Transaction, ph []byte, ts int64, d int) *Block {
+	return proveBlock(&Block{[]byte{}, t, ph, 0, BlockVersion, ts, d})
+}
+
+// PackTransactions takes transactions in order while they fit in a block on top of the given previous hash,
+// returning those that fit and those left over. It stops at the first one that doesn't fit so a transaction is
+// never packed ahead of one it may spend from.
+func PackTransactions(t []*Transaction, ph []byte) ([]*Transaction, []*Transaction) {
+	// Start from the size of a block holding no transactions
+	eb := Block{make([]byte, sha256.Size), nil, ph, 0, BlockVersion, 0, 0}
+	s := len(eb.Serialise())
+	so := 0
+
+	for i, tx := range t {
+		// Each transaction is stored with its length before it
+		s += 4 + len(tx.Serialise())
+		so += tx.SigOps()
+
+		if i == MaxBlockTransactions || s > MaxBlockSize || so > MaxBlockSigOps {
+			return t[:i], t[i:]
+		}
+	}
+
+	return t, nil
+}
 
+// Runs the proof of work for a block, setting its hash and counter
+func proveBlock(b *Block) *Block {
 	// Create a new proof of work for the block
 	pow := NewProof(b)
 
@@ -53,46 +93,37 @@ func CreateBlock(t []*Transaction, ph []byte) *Block {
 	return b
 }
 
-// CreateInitialBlock makes a first block in a chain
-func CreateInitialBlock(c *Transaction) *Block {
+// CreateInitialBlock makes a first block in a chain with the given timestamp and difficulty
+func CreateInitialBlock(c *Transaction, ts int64, d int) *Block {
 	// Create the intial block
-	return CreateBlock([]*Transaction{c}, []byte{})
+	return proveBlock(&Block{[]byte{}, []*Transaction{c}, []byte{}, 0, BlockVersion, ts, d})
 }
 
-// Serialise is a method on the Block struct that serialises the block's data
+// Serialise is a method on the Block struct that serialises the block's data into its canonical encoding
 func (b *Block) Serialise() []byte {
-	// Create the data buffer variable
-	var d bytes.Buffer
+	// Create a new encoder and encode the block with it
+	var e encoder
+	b.encode(&e)
 
-	// Create a new encoder with the data buffer
-	e := gob.NewEncoder(&d)
-
-	// Encode the block with the encoder, handling any errors
-	err := e.Encode(b)
-	HandleError(err)
-
-	return d.Bytes()
+	return e.buf.Bytes()
 }
 
-// Deserialise takes some data and returns it in the form of a block
+// Deserialise takes some canonically encoded data and returns it in the form of a block
 func Deserialise(d []byte) *Block {
-	// Create a storage variable for the block
-	var b Block
-
-	// Create a new encoder with the given data
-	dc := gob.NewDecoder(bytes.NewReader(d))
-
 	// Decode the data, handling any errors
-	err := dc.Decode(&b)
+	b, err := DeserialiseBlock(d)
 	HandleError(err)
 
-	return &b
+	return b
 }
 
+// Output is where the package prints its progress and messages, it can be redirected to keep stdout clean
+var Output io.Writer = os.Stdout
+
 // HandleError is a generic function to handle any errors in the process
 func HandleError(err error) {
 	if err != nil {
-		fmt.Println("Error occurred: ")
+		fmt.Fprintln(Output, "Error occurred: ")
 
 		log.Panic(err)
 	}
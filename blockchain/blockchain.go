@@ -4,23 +4,26 @@ package blockchain
 import (
 	"encoding/hex"
 	"fmt"
+	"log"
 	"os"
+	"path/filepath"
 	"runtime"
 
 	"github.com/dgraph-io/badger"
 )
 
-// Path for the database to write to
-const (
-	dbPath      = "./tmp/blocks"
-	dbFile      = "./tmp/blocks/MANIFEST"
-	initialData = "First Transaction from Initialising Chain"
-)
+// The message in the initial block's coinbase
+const initialData = "First Transaction from Initialising Chain"
+
+// DataDir is the directory the database is written to. It can be changed before a chain is opened, so one process
+// or test can use several chains.
+var DataDir = "./tmp/blocks"
 
-// BlockChain holds the last hash and a pointer to the database
+// BlockChain holds the last hash, a pointer to the database and whether transactions are indexed
 type BlockChain struct {
 	LatestHash []byte
 	Database   *badger.DB
+	TxIndex    bool
 }
 
 // Iterator holds the current hash and a pointer to the database
@@ -32,14 +35,16 @@ type Iterator struct {
 // Checks whether the database exists and is setup
 func checkDB() bool {
 	// Check if the database file exists and no error is returned
-	if _, err := os.Stat(dbFile); os.IsNotExist(err) {
+	if _, err := os.Stat(filepath.Join(DataDir, "MANIFEST")); os.IsNotExist(err) {
 		return false
 	}
 
 	return true
 }
 
-// AppendBlock is a method on the BlockChain struct which adds a block the the chain
+// AppendBlock is a method on the BlockChain struct which adds the transactions to the chain, packing them in order
+// into as many blocks as the block limits need. The pending transactions that are final go first, so nothing is
+// left waiting on chains that can't generate blocks, apart from those the transactions conflict with.
 func (bc *BlockChain) AppendBlock(t []*Transaction) {
 	// Storage variable for the latest hash in the chain
 	var lh []byte
@@ -64,79 +69,103 @@ func (bc *BlockChain) AppendBlock(t []*Transaction) {
 	// Handle any errors that occurred
 	HandleError(err)
 
-	// Create a new block with the given data and the latest hash
-	nb := CreateBlock(t, lh)
+	// Put the pending transactions ready to be mined before the transactions, which may spend their outputs
+	t = append(readyTransactions(bc.Mempool(), t, bc.Height()+1, bc.MedianTimePast()), t...)
 
-	// Make a call to the database to update it...
-	err = bc.Database.Update(func(txn *badger.Txn) error {
-		// Set the hash of the new block and the serialised data, handling any errors
-		err := txn.Set(nb.Hash, nb.Serialise())
-		HandleError(err)
+	for len(t) > 0 {
+		// Take as many of the transactions as fit in a block, if not even one fits it can never be added
+		var bt []*Transaction
+		bt, t = PackTransactions(t, lh)
 
-		// Set the hash of the new block to the latest hash for future use
-		err = txn.Set([]byte("lh"), nb.Hash)
+		if len(bt) == 0 {
+			log.Panic("Error : Transaction is too large for a block!")
+		}
 
-		// Set the latest hash on the blockchain to the hash of the new block
-		bc.LatestHash = nb.Hash
+		// Get the latest block so the new block can be proved at the same difficulty, handling any errors
+		pb, err := bc.GetBlock(lh)
+		HandleError(err)
 
-		return err
+		// Create a new block with the packed transactions and the latest hash
+		nb := CreateBlockAt(bt, lh, bc.nextTimestamp(pb), pb.Difficulty)
 
-	})
+		// Make a call to the database to check the new block and store it on top of the chain
+		err = bc.Database.Update(func(txn *badger.Txn) error {
+			err := bc.validateBlock(txn, nb)
+			if err != nil {
+				return err
+			}
 
-	// Handle any errors that occurred
-	HandleError(err)
+			return bc.connectBlock(txn, nb)
+		})
 
-}
+		// Handle any errors that occurred
+		HandleError(err)
 
-// InitialiseBlockChain is a function which returns a new BlockChain
-func InitialiseBlockChain(a string) *BlockChain {
-	// Storage variable for the latest hash in the chain
-	var lh []byte
+		lh = nb.Hash
+	}
+}
 
+// InitialiseBlockChain is a function which returns a new BlockChain with an initial block made from the genesis,
+// ti turns on the transaction index
+func InitialiseBlockChain(g *Genesis, ti bool) *BlockChain {
 	// Check if the database already exists...
 	if checkDB() {
-		fmt.Println("Blockchain already exists...")
+		fmt.Fprintln(Output, "Blockchain already exists...")
 		runtime.Goexit()
 	}
 
-	// Create an instance of the database options and set the path to the constant above
+	// Create an instance of the database options and set the path to the data directory
 	// Both the directory and value directory live on the same path
 	o := badger.DefaultOptions("")
-	o.Dir = dbPath
-	o.ValueDir = dbPath
+	o.Dir = DataDir
+	o.ValueDir = DataDir
+
+	// Make sure the directory exists, then open the connection to the database with the options, creating the
+	// database variable, handling any errors
+	err := os.MkdirAll(DataDir, 0755)
+	HandleError(err)
 
-	// Open the connection to the database with the options, creating the database variable, handling any errors
 	db, err := badger.Open(o)
 	HandleError(err)
 
+	// Create the blockchain with the database, it has no latest hash until the initial block is stored
+	bc := BlockChain{nil, db, ti}
+
 	// Open a transaction with the database to update it...
 	err = db.Update(func(txn *badger.Txn) error {
-		// Set up a coinbase (initial) transaction with the address and initial data const
-		c := CoinbaseTx(a, initialData)
+		// Set up a coinbase (initial) transaction paying out the genesis allocations
+		c := GenesisTx(g)
 
-		// Create an initial block
-		ib := CreateInitialBlock(c)
+		// Create an initial block with the genesis timestamp and difficulty
+		ib := CreateInitialBlock(c, g.Timestamp, g.Difficulty)
 
-		fmt.Println("Initial block created and proved")
+		fmt.Fprintln(Output, "Initial block created and proved")
 
-		// Set the initial blocks hash and serialised data, handling any errors
-		err = txn.Set(ib.Hash, ib.Serialise())
-		HandleError(err)
+		// Record whether the transaction index is turned on and the index version
+		err = writeIndexOptions(txn, ti)
+		if err != nil {
+			return err
+		}
 
-		// Set the initialblocks hash as the latest hash in the database
-		err = txn.Set([]byte("lh"), ib.Hash)
+		// Record that the blocks use the canonical encoding
+		err = txn.Set([]byte(encodingKey), []byte{BlockVersion})
+		if err != nil {
+			return err
+		}
 
-		// Set the latest hash variable as the initial blocks hash
-		lh = ib.Hash
+		// Record the genesis hash and the chain parameters
+		err = writeGenesisOptions(txn, ib.Hash, g.params())
+		if err != nil {
+			return err
+		}
 
-		return err
+		// Store the initial block as the latest block in the chain
+		return bc.connectBlock(txn, ib)
 	})
 
 	// Handle any errors
 	HandleError(err)
 
-	// Create the blockchain with the latest hash and the database and return it
-	bc := BlockChain{lh, db}
 	return &bc
 }
 
@@ -144,23 +173,26 @@ func InitialiseBlockChain(a string) *BlockChain {
 func ContinueBlockChain(a string) *BlockChain {
 	// Check if the database hasn't been made...
 	if checkDB() == false {
-		fmt.Println("No existing blockchain found, one needs to be made...")
+		fmt.Fprintln(Output, "No existing blockchain found, one needs to be made...")
 		runtime.Goexit()
 	}
 
 	// Create a storage variable for the latest hash
 	var lh []byte
 
-	// Create an instance of the database options and set the path to the constant above
+	// Create an instance of the database options and set the path to the data directory
 	// Both the directory and value directory live on the same path
 	o := badger.DefaultOptions("")
-	o.Dir = dbPath
-	o.ValueDir = dbPath
+	o.Dir = DataDir
+	o.ValueDir = DataDir
 
 	// Open the connection to the database with the options, creating the database variable, handling any errors
 	db, err := badger.Open(o)
 	HandleError(err)
 
+	// Check the blocks can be read with the canonical encoding
+	checkEncoding(db)
+
 	// Use the transaction to get the item stored under lh, handling any errors
 	err = db.Update(func(txn *badger.Txn) error {
 		item, err := txn.Get([]byte("lh"))
@@ -179,13 +211,65 @@ func ContinueBlockChain(a string) *BlockChain {
 	// Handle any errors
 	HandleError(err)
 
-	// Create the chain with the lash hash and the database
-	c := BlockChain{lh, db}
+	// Create the chain with the lash hash, the database and the transaction index option
+	c := BlockChain{lh, db, loadTxIndexOption(db)}
+
+	// Chains made before the current indexes existed need them building before use
+	if indexesOutdated(db) {
+		c.Reindex()
+	}
 
 	return &c
 
 }
 
+// ContinueBlockChainReadOnly opens an existing saved blockchain without the ability to change it.
+// Any number of read only chains can share the database, but not with a process that writes to it.
+func ContinueBlockChainReadOnly() *BlockChain {
+	// Check if the database hasn't been made...
+	if checkDB() == false {
+		fmt.Fprintln(Output, "No existing blockchain found, one needs to be made...")
+		runtime.Goexit()
+	}
+
+	// Create a storage variable for the latest hash
+	var lh []byte
+
+	// Create an instance of the database options, set the path to the data directory and open it read only
+	o := badger.DefaultOptions("")
+	o.Dir = DataDir
+	o.ValueDir = DataDir
+	o.ReadOnly = true
+
+	// Open the connection to the database with the options, creating the database variable, handling any errors
+	db, err := badger.Open(o)
+	HandleError(err)
+
+	// Check the blocks can be read with the canonical encoding
+	checkEncoding(db)
+
+	// Get the item stored under lh, handling any errors
+	err = db.View(func(txn *badger.Txn) error {
+		var err error
+		lh, err = getValue(txn, []byte("lh"))
+
+		return err
+	})
+	HandleError(err)
+
+	// The indexes can't be rebuilt without writing, so they must already be up to date
+	if indexesOutdated(db) {
+		fmt.Fprintln(Output, "The chain's indexes are out of date, run reindex first...")
+		db.Close()
+		runtime.Goexit()
+	}
+
+	// Create the chain with the lash hash, the database and the transaction index option
+	c := BlockChain{lh, db, loadTxIndexOption(db)}
+
+	return &c
+}
+
 // GetUnspentTransactions is a Blockchain method which returns any unspent transaction for an address
 func (bc *BlockChain) GetUnspentTransactions(a string) []Transaction {
 	// Create a holding variable for the unspent transactions
@@ -227,8 +311,10 @@ func (bc *BlockChain) GetUnspentTransactions(a string) []Transaction {
 
 				// If the output can be unlocked by the address do the following...
 				if o.CanBeUnlocked(a) {
-					// Append the transaction to the unspent transactions slice
+					// Append the transaction to the unspent transactions slice, once however many outputs it has
 					ut = append(ut, *t)
+
+					break
 				}
 			}
 
@@ -263,69 +349,101 @@ func (bc *BlockChain) GetUnspentTransactions(a string) []Transaction {
 
 }
 
-// GetUnspentTransactionOutputs is a method on BlockChain which returns the outputs for each unspent transaction
-func (bc *BlockChain) GetUnspentTransactionOutputs(a string) []TxOutput {
-	// Create a holding variable for the unspent transaction outputs
-	var uto []TxOutput
+// UnspentOutput is an output that hasn't been spent yet, along with the transaction and height it was made at,
+// the script locking it, if any, and whether it was made by a coinbase
+type UnspentOutput struct {
+	TxID         []byte
+	Out          int
+	Value        Amount
+	Height       int
+	ScriptPubKey Script
+	Coinbase     bool
+}
+
+// FindUnspentOutputs is a method on BlockChain which returns every unspent output an address can unlock,
+// oldest first
+func (bc *BlockChain) FindUnspentOutputs(a string) []UnspentOutput {
+	// Create a holding variable for the unspent outputs, gathered newest first
+	var uo []UnspentOutput
+
+	// Make a map to hold the spent outputs
+	st := make(map[string]bool)
 
-	// Get the unspent transactions for an address
-	ut := bc.GetUnspentTransactions(a)
+	// Create an iterator on the chain to loop through, along with the height of the latest block
+	i := bc.CreateIterator()
+	h := bc.Height()
 
-	// Loop through the unspent transactions
-	for _, t := range ut {
-		// Loops through each transactions outputs
-		for _, o := range t.Outputs {
-			// If the output can be unlocked then append
-			if o.CanBeUnlocked(a) {
-				uto = append(uto, o)
+	for {
+		// Get the next block in the chain
+		b := i.Next()
+
+		// Loop through the transactions backwards so outputs spent later in the same block are seen as spent
+		for ti := len(b.Transactions) - 1; ti >= 0; ti-- {
+			t := b.Transactions[ti]
+
+			// Record the outputs the transaction spends
+			if t.IsCoinbase() == false {
+				for _, in := range t.Inputs {
+					if in.CanUnlock(a) {
+						st[fmt.Sprintf("%x:%d", in.ID, in.Out)] = true
+					}
+				}
 			}
-		}
-	}
 
-	// Return the unspent transaction outputs
-	return uto
-}
+			// Collect the outputs the address can unlock that haven't been spent, backwards to keep newest first
+			for oID := len(t.Outputs) - 1; oID >= 0; oID-- {
+				o := t.Outputs[oID]
 
-// GetSpendableOutputs takes an address and a total value to send, it returns spendable outputs for an address, i.e. none coinbase outputs
-func (bc *BlockChain) GetSpendableOutputs(a string, v int) (int, map[string][]int) {
-	// Make a map to store the unspent outputs
-	uo := make(map[string][]int)
+				if o.CanBeUnlocked(a) && st[fmt.Sprintf("%x:%d", t.ID, oID)] == false {
+					uo = append(uo, UnspentOutput{t.ID, oID, o.Value, h, o.ScriptPubKey, t.IsCoinbase()})
+				}
+			}
+		}
 
-	// Go get the unspent transactions for an address
-	ut := bc.GetUnspentTransactions(a)
+		// Check if the block doesn't have any previous hash, i.e. is the original block, if so break
+		if len(b.PreviousHash) == 0 {
+			break
+		}
 
-	// Value for accumulated values
-	acc := 0
+		h--
+	}
 
-	// Create a labeled loop for each of the unspent transactions
-Work:
-	for _, t := range ut {
+	// Reverse the outputs so they are oldest first
+	for l, r := 0, len(uo)-1; l < r; l, r = l+1, r-1 {
+		uo[l], uo[r] = uo[r], uo[l]
+	}
 
-		// Create an ID for the transaction
-		tID := hex.EncodeToString(t.ID)
+	return uo
+}
 
-		// Loop through the transactions outputs
-		for oID, o := range t.Outputs {
+// GetUnspentTransactionOutputs is a method on BlockChain which returns the outputs for each unspent transaction
+func (bc *BlockChain) GetUnspentTransactionOutputs(a string) []TxOutput {
+	// Create a holding variable for the unspent transaction outputs
+	var uto []TxOutput
+
+	// Loop through the unspent outputs for the address, creating a transaction output from each of them
+	for _, u := range bc.FindUnspentOutputs(a) {
+		uto = append(uto, TxOutput{u.Value, a, u.ScriptPubKey})
+	}
 
-			// Check if the output can be unlocked and that the accumulated value is less than the total given value
-			if o.CanBeUnlocked(a) && acc < v {
-				// Assign the output value to the accumulated value
-				acc += o.Value
+	// Return the unspent transaction outputs
+	return uto
+}
 
-				// Assign the output ID to the unspent outputs map
-				uo[tID] = append(uo[tID], oID)
+// GetBalance is a method on BlockChain which returns the total value of the unspent outputs for an address
+func (bc *BlockChain) GetBalance(a string) Amount {
+	// Holding variable for the balance
+	var b Amount
 
-				// If the accumulated value gets above the given value then break
-				if acc >= v {
-					break Work
-				}
-			}
+	// Loop through the unspent ouputs, adding each outputs value to the balance, handling any overflow
+	for _, u := range bc.FindUnspentOutputs(a) {
+		var err error
 
-		}
+		b, err = b.Add(u.Value)
+		HandleError(err)
 	}
 
-	// Return the accumulated value and the unspent outputs
-	return acc, uo
+	return b
 }
 
 // CreateIterator is a method on the BlockChain struct that creates a new Iterator
@@ -0,0 +1,369 @@
+package blockchain
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+)
+
+// Errors returned when opening, paying through and closing payment channels
+var (
+	ErrNotChannel        = errors.New("not a payment channel")
+	ErrChannelNotFunded  = errors.New("payment channel has not been funded")
+	ErrChannelCapacity   = errors.New("payment is more than the channel holds")
+	ErrBadCommitment     = errors.New("commitment is not signed by the payer")
+	ErrNothingToClose    = errors.New("nothing has been paid through the channel")
+	ErrChannelPaymentLow = errors.New("payment is below the dust limit")
+	ErrChannelFunding    = errors.New("payment channel's funding doesn't match the chain")
+	ErrChannelClosed     = errors.New("payment channel has already been closed")
+)
+
+// Channel is a unidirectional payment channel. The payer locks the channel's capacity in an output needing both
+// the payer's and the payee's signatures, then pays the payee by signing commitments, transactions spending that
+// output paying the payee more each time with the rest going back to the payer. Commitments are handed to the
+// payee without going near the chain, and the payee closes the channel by adding their own signature to the latest
+// and sending it. If the payee never does, the payer can take everything back once LockTime has passed, so the
+// payee has to close before then.
+type Channel struct {
+	Payer      []byte
+	Payee      []byte
+	LockTime   uint32
+	DustLimit  Amount
+	FundingID  []byte
+	FundingOut int
+	Capacity   Amount
+	Paid       Amount
+	PayerSig   []byte
+}
+
+// NewChannel makes a channel from the payer's key to the payee's, refundable to the payer after the lock time, a
+// block height or unix time as with a transaction's lock time. Commitments won't make outputs below the dust limit
+// of the chain it is opened on. It has to be funded before anything can be paid through it.
+func NewChannel(payer, payee []byte, lt uint32, dl Amount) (*Channel, error) {
+	if len(payer) != ed25519.PublicKeySize || len(payee) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("%w: public keys must be %d bytes", ErrNotChannel, ed25519.PublicKeySize)
+	}
+
+	if bytes.Equal(payer, payee) {
+		return nil, fmt.Errorf("%w: the payer and payee keys are the same", ErrNotChannel)
+	}
+
+	if lt == 0 {
+		return nil, fmt.Errorf("%w: the lock time can't be 0", ErrNotChannel)
+	}
+
+	return &Channel{payer, payee, lt, dl, nil, 0, 0, 0, nil}, nil
+}
+
+// Script returns the script of the channel's funding output, which is paid through its script hash address.
+//
+//	OP_IF
+//	    2 <payer> <payee> 2 OP_CHECKMULTISIG
+//	OP_ELSE
+//	    <lock time> OP_CHECKLOCKTIMEVERIFY OP_DROP <payer> OP_CHECKSIG
+//	OP_ENDIF
+func (c *Channel) Script() Script {
+	s := Script{}.AddOp(OpIf)
+	s = append(s, MultiSigScript(2, [][]byte{c.Payer, c.Payee})...)
+	s = s.AddOp(OpElse)
+	s = append(s, LockTimeScript(c.LockTime, Script{}.AddData(c.Payer).AddOp(OpCheckSig))...)
+
+	return s.AddOp(OpEndIf)
+}
+
+// Address returns the script hash address the channel is funded through
+func (c *Channel) Address() string {
+	return ScriptHashAddress(c.Script())
+}
+
+// SetFunding records the output of a transaction paying the channel's address as the one it is funded with.
+// Whatever it is worth is the channel's capacity.
+func (c *Channel) SetFunding(t *Transaction) error {
+	a := c.Address()
+
+	for i, o := range t.Outputs {
+		if o.PubKey != a {
+			continue
+		}
+
+		if o.Value < c.DustLimit {
+			return fmt.Errorf("%w: capacity %d", ErrChannelPaymentLow, o.Value)
+		}
+
+		c.FundingID, c.FundingOut, c.Capacity = t.ID, i, o.Value
+		c.Paid, c.PayerSig = 0, nil
+
+		return nil
+	}
+
+	return fmt.Errorf("%w: transaction %x doesn't pay %s", ErrNotChannel, t.ID, a)
+}
+
+// Returns the funding output as the input of a transaction spending it
+func (c *Channel) fundingInput() TxInput {
+	return TxInput{c.FundingID, c.FundingOut, c.Address(), MaxSequence, nil}
+}
+
+// Commitment returns the unsigned transaction paying what has been paid through the channel to the payee's key
+// hash address, with the rest going back to the payer's unless it is dust
+func (c *Channel) Commitment() (*Transaction, error) {
+	if c.FundingID == nil {
+		return nil, ErrChannelNotFunded
+	}
+
+	var o []TxOutput
+
+	if c.Paid > 0 {
+		o = append(o, TxOutput{c.Paid, KeyHashAddress(c.Payee), PubKeyHashScript(Hash160(c.Payee))})
+	}
+
+	if r := c.Capacity - c.Paid; r >= c.DustLimit && r > 0 {
+		o = append(o, TxOutput{r, KeyHashAddress(c.Payer), PubKeyHashScript(Hash160(c.Payer))})
+	}
+
+	tx := Transaction{nil, []TxInput{c.fundingInput()}, o, TxVersion, 0}
+	tx.SetID()
+
+	return &tx, nil
+}
+
+// Pay moves an amount more to the payee, signing a new commitment with the payer's key. The channel is then handed
+// to the payee, who can close it having checked the funding with VerifyFunding and the signature with VerifyPayment.
+func (c *Channel) Pay(a Amount, k ed25519.PrivateKey) error {
+	if bytes.Equal(k.Public().(ed25519.PublicKey), c.Payer) == false {
+		return fmt.Errorf("%w: key is not the channel's payer", ErrCannotSign)
+	}
+
+	p, err := c.Paid.Add(a)
+	if err != nil {
+		return err
+	}
+
+	if a == 0 || p > c.Capacity {
+		return fmt.Errorf("%w: %d paid of %d", ErrChannelCapacity, p, c.Capacity)
+	}
+
+	if p < c.DustLimit {
+		return fmt.Errorf("%w: %d paid is below %d", ErrChannelPaymentLow, p, c.DustLimit)
+	}
+
+	// Sign a copy so the channel is left as it was if it can't be signed
+	n := *c
+	n.Paid = p
+
+	t, err := n.Commitment()
+	if err != nil {
+		return err
+	}
+
+	c.Paid, c.PayerSig = p, t.Sign(0, k)
+
+	return nil
+}
+
+// VerifyFunding checks the channel's funding output is in the chain, is unspent, is locked to the channel's
+// address and is worth exactly its capacity. The payer writes the channel file, so until this passes a signed
+// commitment is no promise of anything.
+func (c *Channel) VerifyFunding(bc *BlockChain) error {
+	if c.FundingID == nil {
+		return ErrChannelNotFunded
+	}
+
+	// Only transactions in blocks are found, so a funding transaction that is found is confirmed
+	t, _, err := bc.FindTransaction(c.FundingID)
+	if errors.Is(err, ErrTxNotFound) {
+		return fmt.Errorf("%w: transaction %x is not in the chain", ErrChannelNotFunded, c.FundingID)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if c.FundingOut < 0 || c.FundingOut >= len(t.Outputs) {
+		return fmt.Errorf("%w: transaction %x has no output %d", ErrChannelFunding, c.FundingID, c.FundingOut)
+	}
+
+	o := t.Outputs[c.FundingOut]
+
+	if a := c.Address(); o.PubKey != a || bytes.Equal(o.ScriptPubKey, ScriptHashScript(Hash160(c.Script()))) == false {
+		return fmt.Errorf("%w: output %d of %x doesn't pay %s", ErrChannelFunding, c.FundingOut, c.FundingID, a)
+	}
+
+	if o.Value != c.Capacity {
+		return fmt.Errorf("%w: output %d of %x is worth %d, not the capacity of %d", ErrChannelFunding, c.FundingOut, c.FundingID, o.Value, c.Capacity)
+	}
+
+	s, err := bc.IsSpent(c.FundingID, c.FundingOut)
+	if err != nil {
+		return err
+	}
+
+	if s {
+		return fmt.Errorf("%w: output %d of %x is spent", ErrChannelClosed, c.FundingOut, c.FundingID)
+	}
+
+	return nil
+}
+
+// VerifyPayment checks the payer has signed the commitment paying what the channel says has been paid. It doesn't
+// look at the chain, VerifyFunding checks the commitment spends something.
+func (c *Channel) VerifyPayment() error {
+	t, err := c.Commitment()
+	if err != nil {
+		return err
+	}
+
+	if c.Paid == 0 {
+		return ErrNothingToClose
+	}
+
+	if CheckSignature(t, 0, c.Payer, c.PayerSig) == false {
+		return fmt.Errorf("%w: %d paid", ErrBadCommitment, c.Paid)
+	}
+
+	return nil
+}
+
+// Close adds the payee's signature to the latest commitment, returning the transaction closing the channel
+func (c *Channel) Close(k ed25519.PrivateKey) (*Transaction, error) {
+	if bytes.Equal(k.Public().(ed25519.PublicKey), c.Payee) == false {
+		return nil, fmt.Errorf("%w: key is not the channel's payee", ErrCannotSign)
+	}
+
+	if err := c.VerifyPayment(); err != nil {
+		return nil, err
+	}
+
+	t, _ := c.Commitment()
+
+	// The signatures go in the same order as the keys of the multisig branch
+	d := Script{}.AddData(c.PayerSig).AddData(t.Sign(0, k)).AddInt(1)
+
+	if err := setScriptHashSig(t, 0, d, c.Script()); err != nil {
+		return nil, err
+	}
+
+	t.SetID()
+
+	return t, nil
+}
+
+// Refund returns a transaction paying the channel's whole capacity back to an address with the payer's key.
+// It is locked until the channel's lock time, so it can't be sent before then.
+func (c *Channel) Refund(k ed25519.PrivateKey, to string) (*Transaction, error) {
+	if bytes.Equal(k.Public().(ed25519.PublicKey), c.Payer) == false {
+		return nil, fmt.Errorf("%w: key is not the channel's payer", ErrCannotSign)
+	}
+
+	if c.FundingID == nil {
+		return nil, ErrChannelNotFunded
+	}
+
+	ts, err := AddressScript(to)
+	if err != nil {
+		return nil, err
+	}
+
+	t := &Transaction{nil, []TxInput{c.fundingInput()}, []TxOutput{{c.Capacity, to, ts}}, TxVersion, 0}
+
+	// Lock the transaction before it is signed, as the signature covers the lock time
+	t.SetLockTime(c.LockTime)
+
+	if err := setScriptHashSig(t, 0, Script{}.AddData(t.Sign(0, k)).AddInt(0), c.Script()); err != nil {
+		return nil, err
+	}
+
+	t.SetID()
+
+	return t, nil
+}
+
+// The JSON form of a channel file, with keys, IDs and signatures in hex
+type channelJSON struct {
+	Payer      string `json:"payer"`
+	Payee      string `json:"payee"`
+	LockTime   uint32 `json:"lockTime"`
+	DustLimit  Amount `json:"dustLimit"`
+	FundingID  string `json:"fundingTxid"`
+	FundingOut int    `json:"fundingOut"`
+	Capacity   Amount `json:"capacity"`
+	Paid       Amount `json:"paid"`
+	PayerSig   string `json:"payerSignature,omitempty"`
+}
+
+// SaveChannel writes a channel to a JSON file, which is what the payer hands to the payee after each payment
+func SaveChannel(f string, c *Channel) error {
+	j := channelJSON{
+		hex.EncodeToString(c.Payer),
+		hex.EncodeToString(c.Payee),
+		c.LockTime,
+		c.DustLimit,
+		hex.EncodeToString(c.FundingID),
+		c.FundingOut,
+		c.Capacity,
+		c.Paid,
+		hex.EncodeToString(c.PayerSig),
+	}
+
+	data, err := json.MarshalIndent(j, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(f, append(data, '\n'), 0644)
+}
+
+// LoadChannel reads a channel from a JSON file written by SaveChannel
+func LoadChannel(f string) (*Channel, error) {
+	data, err := ioutil.ReadFile(f)
+	if err != nil {
+		return nil, err
+	}
+
+	var j channelJSON
+
+	err = json.Unmarshal(data, &j)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", f, err)
+	}
+
+	// Decode each of the hex fields, remembering the first that isn't hex
+	var herr error
+
+	decode := func(s string) []byte {
+		b, err := hex.DecodeString(s)
+		if err != nil && herr == nil {
+			herr = fmt.Errorf("%s: %q is not hex encoded", f, s)
+		}
+
+		if len(b) == 0 {
+			return nil
+		}
+
+		return b
+	}
+
+	payer, payee := decode(j.Payer), decode(j.Payee)
+
+	c, err := NewChannel(payer, payee, j.LockTime, j.DustLimit)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", f, err)
+	}
+
+	c.FundingID, c.FundingOut, c.Capacity = decode(j.FundingID), j.FundingOut, j.Capacity
+	c.Paid, c.PayerSig = j.Paid, decode(j.PayerSig)
+
+	if herr != nil {
+		return nil, herr
+	}
+
+	if c.Paid > c.Capacity {
+		return nil, fmt.Errorf("%s: %w: %d paid of %d", f, ErrChannelCapacity, c.Paid, c.Capacity)
+	}
+
+	return c, nil
+}
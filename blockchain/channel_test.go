@@ -0,0 +1,130 @@
+package blockchain
+
+import (
+	"crypto/ed25519"
+	"errors"
+	"testing"
+)
+
+func TestChannelSpends(t *testing.T) {
+	// The channel can be refunded from this height
+	const lt = 5
+
+	// Pays through the channel then checks the funding and closes it, as the payee does
+	payAndClose := func(a Amount) func(c *Channel, bc *BlockChain, pk, ek ed25519.PrivateKey) (*Transaction, error) {
+		return func(c *Channel, bc *BlockChain, pk, ek ed25519.PrivateKey) (*Transaction, error) {
+			if err := c.Pay(a, pk); err != nil {
+				return nil, err
+			}
+
+			if err := c.VerifyFunding(bc); err != nil {
+				return nil, err
+			}
+
+			return c.Close(ek)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		blocks int
+		spend  func(c *Channel, bc *BlockChain, pk, ek ed25519.PrivateKey) (*Transaction, error)
+		err    error
+		mine   error
+	}{
+		{"pay and close", 0, payAndClose(30), nil, nil},
+		{"pay everything and close", 0, payAndClose(40), nil, nil},
+		{"pay more than the capacity", 0, payAndClose(41), ErrChannelCapacity, nil},
+		{"close with nothing paid", 0, func(c *Channel, bc *BlockChain, pk, ek ed25519.PrivateKey) (*Transaction, error) {
+			return c.Close(ek)
+		}, ErrNothingToClose, nil},
+		{"close with the payer's key", 0, func(c *Channel, bc *BlockChain, pk, ek ed25519.PrivateKey) (*Transaction, error) {
+			if err := c.Pay(30, pk); err != nil {
+				return nil, err
+			}
+
+			return c.Close(pk)
+		}, ErrCannotSign, nil},
+		{"capacity above the funding", 0, func(c *Channel, bc *BlockChain, pk, ek ed25519.PrivateKey) (*Transaction, error) {
+			c.Capacity += 10
+			return payAndClose(45)(c, bc, pk, ek)
+		}, ErrChannelFunding, nil},
+		{"funding output that doesn't pay the channel", 0, func(c *Channel, bc *BlockChain, pk, ek ed25519.PrivateKey) (*Transaction, error) {
+			c.FundingOut = 1
+			return payAndClose(30)(c, bc, pk, ek)
+		}, ErrChannelFunding, nil},
+		{"funding transaction not in the chain", 0, func(c *Channel, bc *BlockChain, pk, ek ed25519.PrivateKey) (*Transaction, error) {
+			c.FundingID = make([]byte, len(c.FundingID))
+			return payAndClose(30)(c, bc, pk, ek)
+		}, ErrChannelNotFunded, nil},
+		{"close a closed channel", 0, func(c *Channel, bc *BlockChain, pk, ek ed25519.PrivateKey) (*Transaction, error) {
+			t, err := payAndClose(20)(c, bc, pk, ek)
+			if err != nil {
+				return nil, err
+			}
+
+			if err := mineTx(bc, t); err != nil {
+				return nil, err
+			}
+
+			return payAndClose(10)(c, bc, pk, ek)
+		}, ErrChannelClosed, nil},
+		{"refund before the lock time", 0, func(c *Channel, bc *BlockChain, pk, ek ed25519.PrivateKey) (*Transaction, error) {
+			return c.Refund(pk, "alice")
+		}, nil, ErrInvalidTx},
+		{"refund after the lock time", lt, func(c *Channel, bc *BlockChain, pk, ek ed25519.PrivateKey) (*Transaction, error) {
+			return c.Refund(pk, "alice")
+		}, nil, nil},
+		{"refund with the payee's key", lt, func(c *Channel, bc *BlockChain, pk, ek ed25519.PrivateKey) (*Transaction, error) {
+			return c.Refund(ek, "alice")
+		}, ErrCannotSign, nil},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			bc, done := newTestChain(t, "alice", true)
+			defer done()
+
+			// Open a channel between two keys and fund it, with the change going back to alice
+			pk, ek := testKey(t), testKey(t)
+
+			c, err := NewChannel(pk.Public().(ed25519.PublicKey), ek.Public().(ed25519.PublicKey), lt, bc.DustLimit())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ft := NewTransaction("alice", []Recipient{{Address: c.Address(), Amount: 40}}, LargestFirst{}, bc)
+
+			if err := c.SetFunding(ft); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := mineTx(bc, ft); err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := bc.Generate("miner", tc.blocks); err != nil {
+				t.Fatal(err)
+			}
+
+			// Build the spend, then mine it if it could be built
+			st, err := tc.spend(c, bc, pk, ek)
+			if errors.Is(err, tc.err) == false {
+				t.Fatalf("spend error = %v, want %v", err, tc.err)
+			}
+
+			if err != nil {
+				return
+			}
+
+			if err := mineTx(bc, st); errors.Is(err, tc.mine) == false {
+				t.Fatalf("mining the spend = %v, want %v", err, tc.mine)
+			}
+
+			// Once the spend is mined the channel is closed
+			if err := c.VerifyFunding(bc); tc.mine == nil && errors.Is(err, ErrChannelClosed) == false {
+				t.Errorf("VerifyFunding() after the spend = %v, want %v", err, ErrChannelClosed)
+			}
+		})
+	}
+}
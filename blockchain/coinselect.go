@@ -0,0 +1,237 @@
+package blockchain
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"sort"
+	"time"
+)
+
+// ErrInsufficientFunds is returned when the outputs available can't cover the value to send
+var ErrInsufficientFunds = errors.New("not enough funds")
+
+// CoinSelector picks which unspent outputs a transaction spends. Select returns outputs worth at least v,
+// or ErrInsufficientFunds if all of them together aren't worth enough.
+type CoinSelector interface {
+	Select(uo []UnspentOutput, v Amount) ([]UnspentOutput, error)
+}
+
+// The names coin selectors are chosen by
+const (
+	LargestFirstSelector   = "largest"
+	SmallestFirstSelector  = "smallest"
+	BranchAndBoundSelector = "bnb"
+	RandomImproveSelector  = "random"
+)
+
+// CoinSelectors lists the names of the coin selectors available
+var CoinSelectors = []string{LargestFirstSelector, SmallestFirstSelector, BranchAndBoundSelector, RandomImproveSelector}
+
+// NewCoinSelector returns the coin selector with the given name, the default is largest first
+func NewCoinSelector(n string) (CoinSelector, error) {
+	switch n {
+	case LargestFirstSelector, "":
+		return LargestFirst{}, nil
+	case SmallestFirstSelector:
+		return SmallestFirst{}, nil
+	case BranchAndBoundSelector:
+		return BranchAndBound{}, nil
+	case RandomImproveSelector:
+		return RandomImprove{}, nil
+	}
+
+	return nil, fmt.Errorf("unknown coin selector %q, use one of %v", n, CoinSelectors)
+}
+
+// Adds up the value of some unspent outputs
+func unspentTotal(uo []UnspentOutput) Amount {
+	var v Amount
+
+	for _, u := range uo {
+		v += u.Value
+	}
+
+	return v
+}
+
+// Returns a copy of some unspent outputs sorted by value, largest first if desc is set. Outputs of the same value
+// keep their order so the selection is always the same for the same outputs.
+func sortUnspent(uo []UnspentOutput, desc bool) []UnspentOutput {
+	s := append([]UnspentOutput{}, uo...)
+
+	sort.SliceStable(s, func(i, j int) bool {
+		if desc {
+			return s[i].Value > s[j].Value
+		}
+
+		return s[i].Value < s[j].Value
+	})
+
+	return s
+}
+
+// Takes outputs in order until they are worth at least v
+func accumulate(uo []UnspentOutput, v Amount) ([]UnspentOutput, error) {
+	var sel []UnspentOutput
+	var acc Amount
+
+	for _, u := range uo {
+		if acc >= v {
+			break
+		}
+
+		sel = append(sel, u)
+		acc += u.Value
+	}
+
+	if acc < v {
+		return nil, ErrInsufficientFunds
+	}
+
+	return sel, nil
+}
+
+// LargestFirst spends the largest outputs first, using as few inputs as possible
+type LargestFirst struct{}
+
+// Select takes the largest outputs until they are worth at least v
+func (LargestFirst) Select(uo []UnspentOutput, v Amount) ([]UnspentOutput, error) {
+	return accumulate(sortUnspent(uo, true), v)
+}
+
+// SmallestFirst spends the smallest outputs first, consolidating lots of small outputs
+type SmallestFirst struct{}
+
+// Select takes the smallest outputs until they are worth at least v
+func (SmallestFirst) Select(uo []UnspentOutput, v Amount) ([]UnspentOutput, error) {
+	return accumulate(sortUnspent(uo, false), v)
+}
+
+// The number of branches BranchAndBound explores when Tries isn't set
+const defaultBranchAndBoundTries = 100000
+
+// BranchAndBound searches for outputs worth exactly the value to send so no change is needed, preferring the
+// fewest inputs. Tries limits how many branches are explored, and if no exact match is found Fallback is used,
+// which is largest first when it isn't set.
+type BranchAndBound struct {
+	Tries    int
+	Fallback CoinSelector
+}
+
+// Select looks for outputs worth exactly v, falling back if there aren't any
+func (bb BranchAndBound) Select(uo []UnspentOutput, v Amount) ([]UnspentOutput, error) {
+	if unspentTotal(uo) < v {
+		return nil, ErrInsufficientFunds
+	}
+
+	tries := bb.Tries
+	if tries <= 0 {
+		tries = defaultBranchAndBoundTries
+	}
+
+	// Search largest first so matches with fewer inputs tend to be found early
+	s := sortUnspent(uo, true)
+
+	// The value of the outputs from each position to the end, so branches that can't reach v are cut
+	rest := make([]Amount, len(s)+1)
+	for i := len(s) - 1; i >= 0; i-- {
+		rest[i] = rest[i+1] + s[i].Value
+	}
+
+	var best, cur []UnspentOutput
+
+	// Decide whether to include each output in turn, keeping the exact match with the fewest inputs
+	var search func(i int, acc Amount)
+	search = func(i int, acc Amount) {
+		if tries <= 0 {
+			return
+		}
+
+		tries--
+
+		if acc == v {
+			if best == nil || len(cur) < len(best) {
+				best = append([]UnspentOutput{}, cur...)
+			}
+
+			return
+		}
+
+		// Stop if the value is overshot, nothing is left, the rest can't reach v, or this can't beat the best
+		if acc > v || i == len(s) || acc+rest[i] < v || (best != nil && len(cur)+1 >= len(best)) {
+			return
+		}
+
+		cur = append(cur, s[i])
+		search(i+1, acc+s[i].Value)
+		cur = cur[:len(cur)-1]
+
+		search(i+1, acc)
+	}
+
+	search(0, 0)
+
+	if best != nil {
+		return best, nil
+	}
+
+	fb := bb.Fallback
+	if fb == nil {
+		fb = LargestFirst{}
+	}
+
+	return fb.Select(uo, v)
+}
+
+// RandomImprove picks outputs at random until the value is covered, then keeps adding random outputs while they
+// bring the change closer to the value sent, up to at most twice the value in change. This leaves change outputs
+// of a useful size rather than lots of tiny ones. Rand is used to pick, and is seeded from the time if not set.
+type RandomImprove struct {
+	Rand *rand.Rand
+}
+
+// Select picks random outputs worth at least v, then improves the change
+func (ri RandomImprove) Select(uo []UnspentOutput, v Amount) ([]UnspentOutput, error) {
+	if unspentTotal(uo) < v {
+		return nil, ErrInsufficientFunds
+	}
+
+	r := ri.Rand
+	if r == nil {
+		r = rand.New(rand.NewSource(time.Now().UnixNano()))
+	}
+
+	// Shuffle the outputs, working through them in that order
+	s := append([]UnspentOutput{}, uo...)
+	r.Shuffle(len(s), func(i, j int) { s[i], s[j] = s[j], s[i] })
+
+	// Take random outputs until the value is covered
+	sel, err := accumulate(s, v)
+	if err != nil {
+		return nil, err
+	}
+
+	acc := unspentTotal(sel)
+
+	// Then add any remaining outputs that bring the total closer to twice the value without passing three times it
+	for _, u := range s[len(sel):] {
+		n := acc + u.Value
+
+		if distance(n, 2*v) < distance(acc, 2*v) && n <= 3*v {
+			sel = append(sel, u)
+			acc = n
+		}
+	}
+
+	return sel, nil
+}
+
+// Returns how far apart two values are
+func distance(a, b Amount) Amount {
+	if a > b {
+		return a - b
+	}
+
+	return b - a
+}
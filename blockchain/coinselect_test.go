@@ -0,0 +1,131 @@
+package blockchain
+
+import (
+	"errors"
+	"math/rand"
+	"testing"
+)
+
+// Makes unspent outputs with the given values, each output's number is its position
+func unspentValues(vs ...Amount) []UnspentOutput {
+	var uo []UnspentOutput
+
+	for i, v := range vs {
+		uo = append(uo, UnspentOutput{[]byte{byte(i)}, i, v, 1, nil, false})
+	}
+
+	return uo
+}
+
+// Returns the output numbers of a selection, in the order they were picked
+func selectedOuts(uo []UnspentOutput) []int {
+	var outs []int
+
+	for _, u := range uo {
+		outs = append(outs, u.Out)
+	}
+
+	return outs
+}
+
+func sameOuts(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+func TestCoinSelectors(t *testing.T) {
+	tests := []struct {
+		name   string
+		cs     CoinSelector
+		values []Amount
+		v      Amount
+		want   []int
+		err    error
+	}{
+		{"largest first takes the largest", LargestFirst{}, []Amount{10, 50, 30}, 60, []int{1, 2}, nil},
+		{"largest first stops once covered", LargestFirst{}, []Amount{10, 50, 30}, 50, []int{1}, nil},
+		{"largest first keeps the order of equal values", LargestFirst{}, []Amount{20, 20, 20}, 30, []int{0, 1}, nil},
+		{"largest first without enough", LargestFirst{}, []Amount{10, 20}, 31, nil, ErrInsufficientFunds},
+		{"smallest first takes the smallest", SmallestFirst{}, []Amount{10, 50, 30}, 35, []int{0, 2}, nil},
+		{"smallest first spends everything if it has to", SmallestFirst{}, []Amount{10, 50, 30}, 90, []int{0, 2, 1}, nil},
+		{"smallest first without enough", SmallestFirst{}, []Amount{}, 1, nil, ErrInsufficientFunds},
+		{"branch and bound finds an exact match", BranchAndBound{}, []Amount{50, 13, 7, 30}, 20, []int{1, 2}, nil},
+		{"branch and bound prefers fewer inputs", BranchAndBound{}, []Amount{5, 5, 10, 3, 7}, 10, []int{2}, nil},
+		{"branch and bound falls back to largest first", BranchAndBound{}, []Amount{50, 13, 7}, 21, []int{0}, nil},
+		{"branch and bound uses its fallback", BranchAndBound{Fallback: SmallestFirst{}}, []Amount{50, 13, 7}, 21, []int{2, 1, 0}, nil},
+		{"branch and bound without enough", BranchAndBound{}, []Amount{5, 5}, 11, nil, ErrInsufficientFunds},
+		{"branch and bound gives up after its tries", BranchAndBound{Tries: 1}, []Amount{30, 7, 13}, 20, []int{0}, nil},
+		{"random improve without enough", RandomImprove{rand.New(rand.NewSource(1))}, []Amount{5, 5}, 11, nil, ErrInsufficientFunds},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			sel, err := tc.cs.Select(unspentValues(tc.values...), tc.v)
+			if errors.Is(err, tc.err) == false {
+				t.Fatalf("Select() = %v, want %v", err, tc.err)
+			}
+
+			if outs := selectedOuts(sel); sameOuts(outs, tc.want) == false {
+				t.Errorf("Select() picked %v, want %v", outs, tc.want)
+			}
+		})
+	}
+}
+
+func TestRandomImprove(t *testing.T) {
+	uo := unspentValues(1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12)
+
+	for seed := int64(0); seed < 50; seed++ {
+		sel, err := RandomImprove{rand.New(rand.NewSource(seed))}.Select(uo, 10)
+		if err != nil {
+			t.Fatalf("seed %d: Select() = %v", seed, err)
+		}
+
+		// The value is always covered, each output is picked at most once, and as no output is worth 20 the first
+		// picks can't pass three times the value so improving never does either
+		seen := make(map[int]bool)
+		var total Amount
+
+		for _, u := range sel {
+			if seen[u.Out] {
+				t.Fatalf("seed %d: output %d picked twice", seed, u.Out)
+			}
+
+			seen[u.Out] = true
+			total += u.Value
+		}
+
+		if total < 10 || total > 30 {
+			t.Errorf("seed %d: picked %d, want between 10 and 30", seed, total)
+		}
+	}
+
+	// The same seed always picks the same outputs
+	a, _ := RandomImprove{rand.New(rand.NewSource(7))}.Select(uo, 10)
+	b, _ := RandomImprove{rand.New(rand.NewSource(7))}.Select(uo, 10)
+
+	if sameOuts(selectedOuts(a), selectedOuts(b)) == false {
+		t.Errorf("the same seed picked %v then %v", selectedOuts(a), selectedOuts(b))
+	}
+}
+
+func TestNewCoinSelector(t *testing.T) {
+	for _, n := range append(CoinSelectors, "") {
+		if _, err := NewCoinSelector(n); err != nil {
+			t.Errorf("NewCoinSelector(%q) = %v", n, err)
+		}
+	}
+
+	if _, err := NewCoinSelector("biggest"); err == nil {
+		t.Errorf("NewCoinSelector(%q) didn't fail", "biggest")
+	}
+}
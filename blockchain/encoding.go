@@ -0,0 +1,336 @@
+package blockchain
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// The current versions of the block and transaction encodings, written as the first byte of each encoded
+// block, transaction, input and output. Inputs and outputs are always encoded with their transaction's version.
+const (
+	BlockVersion byte = 3
+	TxVersion    byte = 4
+)
+
+// The canonical encoding is made of the following, all integers are big endian:
+//
+//	bytes:       uint32 length followed by the bytes
+//	int:         int64
+//	block:       version byte, hash bytes, previous hash bytes, counter int, timestamp int, difficulty int,
+//	             uint32 transaction count, each transaction as bytes
+//	             version 1 blocks have no timestamp or difficulty, and were all proved at the default difficulty
+//	transaction: version byte, uint32 input count, each input, uint32 output count, each output, uint32 lock time
+//	input:       version byte, ID bytes, out int, sig bytes, uint32 sequence, script sig bytes
+//	             version 1 transactions have no lock time and their inputs no sequence, so they are always final
+//	output:      version byte, value uint64, public key bytes, script public key bytes
+//	             version 1 and 2 transactions have no scripts
+//
+// A transaction's ID is the sha256 hash of its encoding, so it isn't part of the encoding itself. From version 4
+// the encoding is the same as version 3, but the ID is hashed with every script sig left empty.
+//
+// Version 3 blocks are encoded the same as version 2, but their proof of work commits to the hash of each
+// transaction's whole encoding rather than its ID, so the script sigs can't be changed without changing the block's
+// hash. Blocks before version 3 commit to the IDs, so they can't hold version 4 transactions.
+
+// ErrShortData is returned when encoded data ends before everything has been decoded
+var ErrShortData = errors.New("encoded data is too short")
+
+// encoder builds up the canonical encoding of a value
+type encoder struct {
+	buf bytes.Buffer
+}
+
+// Writes a single byte
+func (e *encoder) writeByte(v byte) {
+	e.buf.WriteByte(v)
+}
+
+// Writes a uint32
+func (e *encoder) writeUint32(v uint32) {
+	var b [4]byte
+	binary.BigEndian.PutUint32(b[:], v)
+	e.buf.Write(b[:])
+}
+
+// Writes a uint64
+func (e *encoder) writeUint64(v uint64) {
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], v)
+	e.buf.Write(b[:])
+}
+
+// Writes an int as an int64
+func (e *encoder) writeInt(v int) {
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], uint64(int64(v)))
+	e.buf.Write(b[:])
+}
+
+// Writes some bytes prefixed with their length
+func (e *encoder) writeBytes(v []byte) {
+	e.writeUint32(uint32(len(v)))
+	e.buf.Write(v)
+}
+
+// decoder reads values back out of a canonical encoding, remembering the first error it hits
+type decoder struct {
+	data []byte
+	err  error
+}
+
+// Takes the next n bytes, or nil if there aren't enough
+func (d *decoder) take(n int) []byte {
+	if d.err != nil {
+		return nil
+	}
+
+	if n < 0 || len(d.data) < n {
+		d.err = ErrShortData
+		return nil
+	}
+
+	b := d.data[:n]
+	d.data = d.data[n:]
+
+	return b
+}
+
+// Reads a single byte
+func (d *decoder) readByte() byte {
+	b := d.take(1)
+	if b == nil {
+		return 0
+	}
+
+	return b[0]
+}
+
+// Reads a uint32
+func (d *decoder) readUint32() uint32 {
+	b := d.take(4)
+	if b == nil {
+		return 0
+	}
+
+	return binary.BigEndian.Uint32(b)
+}
+
+// Reads a uint64
+func (d *decoder) readUint64() uint64 {
+	b := d.take(8)
+	if b == nil {
+		return 0
+	}
+
+	return binary.BigEndian.Uint64(b)
+}
+
+// Reads an int64 as an int
+func (d *decoder) readInt() int {
+	b := d.take(8)
+	if b == nil {
+		return 0
+	}
+
+	return int(int64(binary.BigEndian.Uint64(b)))
+}
+
+// Reads some bytes prefixed with their length, copying them out of the data
+func (d *decoder) readBytes() []byte {
+	n := d.readUint32()
+
+	b := d.take(int(n))
+	if b == nil {
+		return nil
+	}
+
+	return append([]byte{}, b...)
+}
+
+// Reads a version byte, failing if it is newer than the latest version known
+func (d *decoder) readVersion(latest byte) byte {
+	v := d.readByte()
+
+	if d.err == nil && (v == 0 || v > latest) {
+		d.err = fmt.Errorf("unknown encoding version %d", v)
+	}
+
+	return v
+}
+
+// Writes the canonical encoding of a transaction, without its ID
+func (t *Transaction) encode(e *encoder) {
+	e.writeByte(t.Version)
+
+	e.writeUint32(uint32(len(t.Inputs)))
+	for _, in := range t.Inputs {
+		in.encode(e, t.Version)
+	}
+
+	e.writeUint32(uint32(len(t.Outputs)))
+	for _, o := range t.Outputs {
+		o.encode(e, t.Version)
+	}
+
+	if t.Version >= 2 {
+		e.writeUint32(t.LockTime)
+	}
+}
+
+// Writes the canonical encoding of an input with the given version
+func (i *TxInput) encode(e *encoder, v byte) {
+	e.writeByte(v)
+	e.writeBytes(i.ID)
+	e.writeInt(i.Out)
+	e.writeBytes([]byte(i.Sig))
+
+	if v >= 2 {
+		e.writeUint32(i.Sequence)
+	}
+
+	if v >= 3 {
+		e.writeBytes(i.ScriptSig)
+	}
+}
+
+// Writes the canonical encoding of an output with the given version
+func (o *TxOutput) encode(e *encoder, v byte) {
+	e.writeByte(v)
+	e.writeUint64(uint64(o.Value))
+	e.writeBytes([]byte(o.PubKey))
+
+	if v >= 3 {
+		e.writeBytes(o.ScriptPubKey)
+	}
+}
+
+// Reads a transaction, setting its ID from what was read
+func (d *decoder) readTransaction() *Transaction {
+	t := Transaction{Version: d.readVersion(TxVersion)}
+
+	// Read the inputs, each one is written with the transaction's version
+	n := d.readUint32()
+	for i := uint32(0); i < n && d.err == nil; i++ {
+		d.readVersion(t.Version)
+		in := TxInput{d.readBytes(), d.readInt(), string(d.readBytes()), MaxSequence, nil}
+
+		if t.Version >= 2 {
+			in.Sequence = d.readUint32()
+		}
+
+		if t.Version >= 3 {
+			in.ScriptSig = d.readBytes()
+		}
+
+		t.Inputs = append(t.Inputs, in)
+	}
+
+	// Read the outputs in the same way
+	n = d.readUint32()
+	for i := uint32(0); i < n && d.err == nil; i++ {
+		d.readVersion(t.Version)
+		o := TxOutput{Amount(d.readUint64()), string(d.readBytes()), nil}
+
+		if t.Version >= 3 {
+			o.ScriptPubKey = d.readBytes()
+		}
+
+		t.Outputs = append(t.Outputs, o)
+	}
+
+	if t.Version >= 2 {
+		t.LockTime = d.readUint32()
+	}
+
+	if d.err != nil {
+		return nil
+	}
+
+	t.SetID()
+
+	return &t
+}
+
+// DeserialiseTransaction takes the canonical encoding of a transaction and returns the transaction
+func DeserialiseTransaction(data []byte) (*Transaction, error) {
+	// Decode the transaction, making sure there is nothing left over
+	d := decoder{data: data}
+	t := d.readTransaction()
+
+	if d.err == nil && len(d.data) != 0 {
+		d.err = errors.New("trailing data after transaction")
+	}
+
+	return t, d.err
+}
+
+// Writes the canonical encoding of a block
+func (b *Block) encode(e *encoder) {
+	e.writeByte(b.Version)
+	e.writeBytes(b.Hash)
+	e.writeBytes(b.PreviousHash)
+	e.writeInt(b.Counter)
+
+	if b.Version >= 2 {
+		e.writeInt(int(b.Timestamp))
+		e.writeInt(b.Difficulty)
+	}
+
+	e.writeUint32(uint32(len(b.Transactions)))
+	for _, t := range b.Transactions {
+		e.writeBytes(t.Serialise())
+	}
+}
+
+// Reads a block
+func (d *decoder) readBlock() *Block {
+	b := Block{Version: d.readVersion(BlockVersion)}
+
+	b.Hash = d.readBytes()
+	b.PreviousHash = d.readBytes()
+	b.Counter = d.readInt()
+	b.Difficulty = Difficulty
+
+	if b.Version >= 2 {
+		b.Timestamp = int64(d.readInt())
+		b.Difficulty = d.readInt()
+	}
+
+	// Each transaction is stored as length prefixed bytes
+	n := d.readUint32()
+	for i := uint32(0); i < n && d.err == nil; i++ {
+		td := d.readBytes()
+		if d.err != nil {
+			break
+		}
+
+		t, err := DeserialiseTransaction(td)
+		if err != nil {
+			d.err = err
+			break
+		}
+
+		b.Transactions = append(b.Transactions, t)
+	}
+
+	if d.err != nil {
+		return nil
+	}
+
+	return &b
+}
+
+// DeserialiseBlock takes the canonical encoding of a block and returns the block
+func DeserialiseBlock(data []byte) (*Block, error) {
+	// Decode the block, making sure there is nothing left over
+	d := decoder{data: data}
+	b := d.readBlock()
+
+	if d.err == nil && len(d.data) != 0 {
+		d.err = errors.New("trailing data after block")
+	}
+
+	return b, d.err
+}
@@ -0,0 +1,156 @@
+package blockchain
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+// Makes a transaction of the given version spending an output with a script sig, paying to a script
+func encodingTestTx(v byte) *Transaction {
+	in := TxInput{bytes.Repeat([]byte{7}, 32), 1, "alice", MaxSequence - 2, Script{0x01, 0xaa}}
+	out := TxOutput{250, "bob", PubKeyHashScript(bytes.Repeat([]byte{9}, 20))}
+
+	// Older versions have no sequences, lock times or scripts to keep
+	if v < 2 {
+		in.Sequence = MaxSequence
+	}
+
+	if v < 3 {
+		in.ScriptSig, out.ScriptPubKey = nil, nil
+	}
+
+	t := Transaction{nil, []TxInput{in}, []TxOutput{out}, v, 0}
+
+	if v >= 2 {
+		t.LockTime = 12
+	}
+
+	t.SetID()
+
+	return &t
+}
+
+func TestTransactionEncoding(t *testing.T) {
+	for v := byte(1); v <= TxVersion; v++ {
+		tx := encodingTestTx(v)
+		data := tx.Serialise()
+
+		dt, err := DeserialiseTransaction(data)
+		if err != nil {
+			t.Fatalf("version %d: DeserialiseTransaction() = %v", v, err)
+		}
+
+		if bytes.Equal(dt.Serialise(), data) == false || bytes.Equal(dt.ID, tx.ID) == false {
+			t.Errorf("version %d: transaction changed in a round trip", v)
+		}
+
+		// Every prefix is too short, and anything after the transaction is left over
+		for i := 0; i < len(data); i++ {
+			if _, err := DeserialiseTransaction(data[:i]); err == nil {
+				t.Fatalf("version %d: decoded %d of %d bytes", v, i, len(data))
+			}
+		}
+
+		if _, err := DeserialiseTransaction(append(data, 0)); err == nil {
+			t.Errorf("version %d: decoded with trailing data", v)
+		}
+	}
+
+	// Versions that don't exist yet can't be read
+	data := encodingTestTx(TxVersion).Serialise()
+	data[0] = TxVersion + 1
+
+	if _, err := DeserialiseTransaction(data); err == nil {
+		t.Errorf("decoded transaction version %d", TxVersion+1)
+	}
+}
+
+func TestTransactionIDs(t *testing.T) {
+	// Up to version 3 the script sig is part of the ID, from version 4 it isn't
+	for v := byte(3); v <= TxVersion; v++ {
+		tx := encodingTestTx(v)
+
+		c := *tx
+		c.Inputs = []TxInput{tx.Inputs[0]}
+		c.Inputs[0].ScriptSig = Script{0x01, 0xbb}
+		c.SetID()
+
+		if same := bytes.Equal(c.ID, tx.ID); same != (v >= 4) {
+			t.Errorf("version %d: changing the script sig kept the ID the same = %t", v, same)
+		}
+	}
+}
+
+func TestBlockEncoding(t *testing.T) {
+	b := CreateBlockAt([]*Transaction{CoinbaseTx("miner", "encoding test"), encodingTestTx(TxVersion)}, bytes.Repeat([]byte{3}, 32), RegtestTimestamp, 1)
+	data := b.Serialise()
+
+	db, err := DeserialiseBlock(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if bytes.Equal(db.Serialise(), data) == false {
+		t.Errorf("block changed in a round trip")
+	}
+
+	if db.Version != BlockVersion || db.Timestamp != b.Timestamp || db.Difficulty != b.Difficulty || len(db.Transactions) != 2 {
+		t.Errorf("DeserialiseBlock() = %+v, want %+v", db, b)
+	}
+
+	// The decoded block still proves its hash
+	if NewProof(db).ValidateProof() == false || bytes.Equal(db.HashTransactions(), b.HashTransactions()) == false {
+		t.Errorf("decoded block doesn't match its proof of work")
+	}
+
+	if _, err := DeserialiseBlock(data[:len(data)-1]); err == nil {
+		t.Errorf("decoded a block cut short")
+	}
+}
+
+func TestBlockCommitsToScriptSigs(t *testing.T) {
+	bc, done := newTestChain(t, "alice", true)
+	defer done()
+
+	pb, err := bc.GetBlock(bc.LatestHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Changing a script sig keeps the transaction's ID but changes what the block commits to
+	c := CoinbaseTx("miner", "script sig test")
+	b := CreateBlockAt([]*Transaction{c}, pb.Hash, bc.nextTimestamp(pb), pb.Difficulty)
+	h := b.HashTransactions()
+
+	mc := *c
+	mc.Inputs = []TxInput{c.Inputs[0]}
+	mc.Inputs[0].ScriptSig = Script{0x01, 0xff}
+	mc.SetID()
+
+	if bytes.Equal(mc.ID, c.ID) == false {
+		t.Fatalf("changing the script sig changed the ID")
+	}
+
+	mb := *b
+	mb.Transactions = []*Transaction{&mc}
+
+	if bytes.Equal(mb.HashTransactions(), h) {
+		t.Errorf("changing a script sig didn't change the transactions hash")
+	}
+
+	if err := bc.ValidateBlock(&mb); errors.Is(err, ErrInvalidBlock) == false {
+		t.Errorf("ValidateBlock() with a changed script sig = %v, want %v", err, ErrInvalidBlock)
+	}
+
+	// Blocks from before the commitment can't hold transactions whose IDs leave the script sigs out
+	ob := proveBlock(&Block{[]byte{}, []*Transaction{c}, pb.Hash, 0, 2, b.Timestamp, pb.Difficulty})
+
+	if err := bc.ValidateBlock(ob); errors.Is(err, ErrInvalidTx) == false {
+		t.Errorf("ValidateBlock() of a version 2 block = %v, want %v", err, ErrInvalidTx)
+	}
+
+	if err := bc.ValidateBlock(b); err != nil {
+		t.Errorf("ValidateBlock() = %v", err)
+	}
+}
@@ -0,0 +1,382 @@
+package blockchain
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+
+	"github.com/dgraph-io/badger"
+)
+
+// The magic bytes an export file starts with, and the version of the export format
+const (
+	exportMagic   = "GBCX"
+	exportVersion = 3
+)
+
+// An export file is made of the following, all integers are big endian:
+//
+//	header:   the magic bytes, export version byte, uint64 block count, uint32 parameter count, then each of the
+//	          chain's parameters as a uint32 length followed by its key and a uint32 length followed by its value
+//	          version 2 exports only hold the network name as a uint32 length followed by the name,
+//	          and version 1 exports have no parameters and belong to the default network
+//	blocks:   each block from the initial block to the latest as a uint32 length followed by its canonical encoding
+//	checksum: the sha256 hash of everything before it
+
+// ErrBadExport is returned when an export file is not in the export format or is corrupted
+var ErrBadExport = errors.New("not a valid export file")
+
+// Export writes every block in the chain, from the initial block to the latest, to w in the export format.
+// It returns the number of blocks written.
+func (bc *BlockChain) Export(w io.Writer) (int, error) {
+	// Hash everything written so the checksum can be added at the end
+	h := sha256.New()
+	bw := bufio.NewWriter(io.MultiWriter(w, h))
+
+	// Write the header
+	n := bc.Height() + 1
+
+	bw.WriteString(exportMagic)
+	bw.WriteByte(exportVersion)
+
+	err := binary.Write(bw, binary.BigEndian, uint64(n))
+	if err != nil {
+		return 0, err
+	}
+
+	var ps map[string][]byte
+
+	err = bc.Database.View(func(txn *badger.Txn) error {
+		var err error
+		ps, err = readChainParams(txn)
+
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	err = binary.Write(bw, binary.BigEndian, uint32(len(ps)))
+	if err != nil {
+		return 0, err
+	}
+
+	// Write the parameters in a fixed order so the same chain always exports the same way
+	for _, k := range chainParamKeys {
+		if v, ok := ps[k]; ok {
+			writeExportBytes(bw, []byte(k))
+			writeExportBytes(bw, v)
+		}
+	}
+
+	// Write each block, fetching them by height so they come out initial block first
+	for height := 0; height < n; height++ {
+		err = bc.Database.View(func(txn *badger.Txn) error {
+			bh, err := getValue(txn, hashIndexKey(height))
+			if err != nil {
+				return err
+			}
+
+			rb, err := getValue(txn, bh)
+			if err != nil {
+				return err
+			}
+
+			err = binary.Write(bw, binary.BigEndian, uint32(len(rb)))
+			if err != nil {
+				return err
+			}
+
+			_, err = bw.Write(rb)
+
+			return err
+		})
+
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	// Flush everything through the hash before writing the checksum itself
+	err = bw.Flush()
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = w.Write(h.Sum(nil))
+
+	return n, err
+}
+
+// Writes some bytes to an export file prefixed with their length
+func writeExportBytes(w io.Writer, v []byte) {
+	binary.Write(w, binary.BigEndian, uint32(len(v)))
+	w.Write(v)
+}
+
+// Reads some bytes from an export file prefixed with their length
+func readExportBytes(r io.Reader) ([]byte, error) {
+	var l uint32
+
+	err := binary.Read(r, binary.BigEndian, &l)
+	if err != nil {
+		return nil, ErrBadExport
+	}
+
+	// Nothing in an export is bigger than a block, so a longer length can only be corrupt and isn't allocated
+	if l > MaxBlockSize {
+		return nil, fmt.Errorf("%w: %d bytes is more than the limit of %d", ErrBadExport, l, MaxBlockSize)
+	}
+
+	v := make([]byte, l)
+
+	_, err = io.ReadFull(r, v)
+	if err != nil {
+		return nil, ErrBadExport
+	}
+
+	return v, nil
+}
+
+// Reads the header of an export file, returning the number of blocks it holds and the chain's parameters
+func readExportHeader(r io.Reader) (int, map[string][]byte, error) {
+	// Read the magic, version and block count
+	hd := make([]byte, len(exportMagic)+1+8)
+
+	_, err := io.ReadFull(r, hd)
+	if err != nil {
+		return 0, nil, ErrBadExport
+	}
+
+	if string(hd[:len(exportMagic)]) != exportMagic {
+		return 0, nil, ErrBadExport
+	}
+
+	v := hd[len(exportMagic)]
+	if v == 0 || v > exportVersion {
+		return 0, nil, fmt.Errorf("%w: unknown export version %d", ErrBadExport, v)
+	}
+
+	n := int(binary.BigEndian.Uint64(hd[len(exportMagic)+1:]))
+
+	// Version 1 exports were all made on the default network
+	if v == 1 {
+		return n, map[string][]byte{networkKey: []byte(DefaultNetwork)}, nil
+	}
+
+	// Version 2 exports only hold the network name
+	if v == 2 {
+		nw, err := readExportBytes(r)
+		if err != nil {
+			return 0, nil, err
+		}
+
+		return n, map[string][]byte{networkKey: nw}, nil
+	}
+
+	// Read each of the parameters, which must all be ones this chain knows about
+	var c uint32
+
+	err = binary.Read(r, binary.BigEndian, &c)
+	if err != nil {
+		return 0, nil, ErrBadExport
+	}
+
+	ps := make(map[string][]byte)
+
+	for i := uint32(0); i < c; i++ {
+		k, err := readExportBytes(r)
+		if err != nil {
+			return 0, nil, err
+		}
+
+		pv, err := readExportBytes(r)
+		if err != nil {
+			return 0, nil, err
+		}
+
+		if knownChainParam(string(k)) == false {
+			return 0, nil, fmt.Errorf("%w: unknown chain parameter %q", ErrBadExport, k)
+		}
+
+		ps[string(k)] = pv
+	}
+
+	return n, ps, nil
+}
+
+// Checks whether a key is one of the chain parameters
+func knownChainParam(k string) bool {
+	for _, pk := range chainParamKeys {
+		if pk == k {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Reads the next block from an export file
+func readExportBlock(r io.Reader) (*Block, error) {
+	// Read the block, which is stored as length prefixed bytes
+	rb, err := readExportBytes(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return DeserialiseBlock(rb)
+}
+
+// Checks the sha256 checksum at the end of a file matches the rest of its contents
+func verifyChecksum(r io.Reader) error {
+	// Hash everything up to the last 32 bytes, holding back the most recent 32 bytes as the possible checksum
+	h := sha256.New()
+	br := bufio.NewReader(r)
+
+	var tail []byte
+	buf := make([]byte, 32*1024)
+
+	for {
+		n, err := br.Read(buf)
+		tail = append(tail, buf[:n]...)
+
+		if len(tail) > sha256.Size {
+			h.Write(tail[:len(tail)-sha256.Size])
+			tail = append([]byte{}, tail[len(tail)-sha256.Size:]...)
+		}
+
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(tail) != sha256.Size || bytes.Equal(h.Sum(nil), tail) == false {
+		return errors.New("checksum does not match")
+	}
+
+	return nil
+}
+
+// ImportBlockChain creates a new chain from an export file, checking its checksum first and then validating each
+// block as it is connected. If anything fails the new database is removed. ti turns on the transaction index.
+func ImportBlockChain(r io.ReadSeeker, ti bool) (*BlockChain, error) {
+	// Check if the database already exists...
+	if checkDB() {
+		fmt.Fprintln(Output, "Blockchain already exists...")
+		runtime.Goexit()
+	}
+
+	// Check the whole file is intact before touching the database
+	err := verifyChecksum(r)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrBadExport, err)
+	}
+
+	_, err = r.Seek(0, io.SeekStart)
+	if err != nil {
+		return nil, err
+	}
+
+	br := bufio.NewReader(r)
+
+	n, ps, err := readExportHeader(br)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create an instance of the database options and set the path to the data directory
+	o := badger.DefaultOptions("")
+	o.Dir = DataDir
+	o.ValueDir = DataDir
+
+	// Make sure the directory exists, then open the connection to the database with the options
+	err = os.MkdirAll(DataDir, 0755)
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := badger.Open(o)
+	if err != nil {
+		return nil, err
+	}
+
+	bc := BlockChain{nil, db, ti}
+
+	// Import the blocks, removing the new database if anything goes wrong
+	err = bc.importBlocks(br, n, ps)
+	if err != nil {
+		db.Close()
+		os.RemoveAll(DataDir)
+
+		return nil, err
+	}
+
+	return &bc, nil
+}
+
+// Reads n blocks from an export file, validating and connecting each one under the chain parameters ps
+func (bc *BlockChain) importBlocks(r io.Reader, n int, ps map[string][]byte) error {
+	// An export always holds at least the initial block
+	if n == 0 {
+		return fmt.Errorf("%w: no blocks", ErrBadExport)
+	}
+
+	// Record the options for the new database, and the chain parameters the blocks are validated under
+	err := bc.Database.Update(func(txn *badger.Txn) error {
+		err := writeIndexOptions(txn, bc.TxIndex)
+		if err != nil {
+			return err
+		}
+
+		err = writeChainParams(txn, ps)
+		if err != nil {
+			return err
+		}
+
+		return txn.Set([]byte(encodingKey), []byte{BlockVersion})
+	})
+	if err != nil {
+		return err
+	}
+
+	// Validate and connect each block in its own database transaction
+	for i := 0; i < n; i++ {
+		b, err := readExportBlock(r)
+		if err != nil {
+			return err
+		}
+
+		err = bc.Database.Update(func(txn *badger.Txn) error {
+			err := bc.validateBlock(txn, b)
+			if err != nil {
+				return fmt.Errorf("block %d: %w", i, err)
+			}
+
+			// The first block is the initial block, so record it as the genesis
+			if i == 0 {
+				err = txn.Set([]byte(genesisKey), b.Hash)
+				if err != nil {
+					return err
+				}
+			}
+
+			return bc.connectBlock(txn, b)
+		})
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,59 @@
+package blockchain
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/dgraph-io/badger"
+)
+
+// ErrNotRegtest is returned when blocks are generated on a chain that isn't a regtest chain
+var ErrNotRegtest = errors.New("blocks can only be generated on regtest chains")
+
+// Generate mines n blocks on top of the chain, each holding a coinbase paying the reward to the given address
+// followed by as many of the pending transactions that are final as fit. It returns the hashes of the new blocks, oldest first.
+// Only regtest chains can generate blocks, anywhere else the rewards would be free to anyone who asked.
+func (bc *BlockChain) Generate(a string, n int) ([][]byte, error) {
+	if bc.Network() != RegtestNetwork {
+		return nil, fmt.Errorf("%w: this chain is on the %s network", ErrNotRegtest, bc.Network())
+	}
+
+	// Storage variable for the hashes of the new blocks
+	var hs [][]byte
+
+	for i := 0; i < n; i++ {
+		// Get the latest block to build on
+		pb, err := bc.GetBlock(bc.LatestHash)
+		if err != nil {
+			return hs, err
+		}
+
+		// Coinbase IDs are hashes of their contents, so the height goes in the data to keep each one unique
+		c := CoinbaseTx(a, fmt.Sprintf("Coins to %s at height %d", a, bc.Height()+1))
+
+		// Take the pending transactions in the order they arrived, mining them removes them from the pool
+		rt := readyTransactions(bc.Mempool(), nil, bc.Height()+1, bc.MedianTimePast())
+		bt, _ := PackTransactions(append([]*Transaction{c}, rt...), pb.Hash)
+
+		// Create the block at the same difficulty as the latest block
+		nb := CreateBlockAt(bt, pb.Hash, bc.nextTimestamp(pb), pb.Difficulty)
+
+		// Check the new block and store it on top of the chain
+		err = bc.Database.Update(func(txn *badger.Txn) error {
+			err := bc.validateBlock(txn, nb)
+			if err != nil {
+				return err
+			}
+
+			return bc.connectBlock(txn, nb)
+		})
+
+		if err != nil {
+			return hs, err
+		}
+
+		hs = append(hs, nb.Hash)
+	}
+
+	return hs, nil
+}
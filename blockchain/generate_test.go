@@ -0,0 +1,43 @@
+package blockchain
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestGenerateOnlyOnRegtest(t *testing.T) {
+	tests := []struct {
+		name    string
+		network string
+		err     error
+	}{
+		{"regtest", RegtestNetwork, nil},
+		{"default network", DefaultNetwork, ErrNotRegtest},
+		{"other network", "testnet", ErrNotRegtest},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			g := RegtestGenesis("alice", true)
+			g.Network = tc.network
+
+			bc, done := newTestChainFrom(t, g)
+			defer done()
+
+			hs, err := bc.Generate("miner", 2)
+			if errors.Is(err, tc.err) == false {
+				t.Fatalf("Generate() = %v, want %v", err, tc.err)
+			}
+
+			// Nothing is mined when generating isn't allowed
+			w := 2
+			if tc.err != nil {
+				w = 0
+			}
+
+			if len(hs) != w || bc.Height() != w {
+				t.Errorf("generated %d blocks to height %d, want %d", len(hs), bc.Height(), w)
+			}
+		})
+	}
+}
@@ -0,0 +1,336 @@
+package blockchain
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/dgraph-io/badger"
+)
+
+// Keys for the genesis details stored alongside the blocks in the database
+const (
+	genesisKey       = "opt:genesis"
+	networkKey       = "opt:network"
+	deterministicKey = "opt:deterministic"
+	dustLimitKey     = "opt:dustlimit"
+	maturityKey      = "opt:coinbasematurity"
+)
+
+// The keys of the chain parameters set by the genesis, these are the rules of the chain so they travel with
+// it in exports. Chains made before a parameter existed don't have its key and use the parameter's default.
+var chainParamKeys = []string{networkKey, deterministicKey, dustLimitKey, maturityKey}
+
+// DefaultNetwork is the network a chain belongs to when its genesis doesn't name one
+const DefaultNetwork = "main"
+
+// The regtest network is for testing, its blocks are proved at the lowest difficulty so they are mined instantly,
+// and its initial block always has the same timestamp
+const (
+	RegtestNetwork    = "regtest"
+	RegtestDifficulty = 1
+	RegtestTimestamp  = 1296688602
+)
+
+// Allocation is an amount paid to an address by the initial block
+type Allocation struct {
+	Address string `json:"address"`
+	Value   Amount `json:"value"`
+}
+
+// Genesis holds everything needed to create the initial block of a chain. When Deterministic is set every block
+// after the initial block is timestamped one second after the block before it, rather than with the current time,
+// so the same transactions always make the same blocks. Outputs worth less than DustLimit are dust, and are
+// rejected by validation. Coinbase outputs, including the initial block's allocations, can't be spent until they
+// have CoinbaseMaturity confirmations.
+type Genesis struct {
+	Network       string       `json:"network"`
+	Message       string       `json:"message"`
+	Timestamp     int64        `json:"timestamp"`
+	Difficulty    int          `json:"difficulty"`
+	Allocations   []Allocation `json:"allocations"`
+	Deterministic bool         `json:"deterministicTimestamps"`
+	DustLimit     Amount       `json:"dustLimit"`
+	Maturity      int          `json:"coinbaseMaturity"`
+}
+
+// DefaultGenesis returns the genesis used when no genesis file is given, paying the reward to a single address
+func DefaultGenesis(a string) *Genesis {
+	return &Genesis{DefaultNetwork, initialData, Clock().Unix(), Difficulty, []Allocation{{a, Reward}}, false, 0, DefaultCoinbaseMaturity}
+}
+
+// RegtestGenesis returns the genesis of a regtest chain paying the reward to a single address,
+// d turns on deterministic timestamps
+func RegtestGenesis(a string, d bool) *Genesis {
+	return &Genesis{RegtestNetwork, initialData, RegtestTimestamp, RegtestDifficulty, []Allocation{{a, Reward}}, d, 0, 0}
+}
+
+// LoadGenesis reads a genesis from a JSON file, filling in defaults for anything left out and checking the rest
+func LoadGenesis(path string) (*Genesis, error) {
+	// Read and decode the file
+	d, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var g Genesis
+
+	err = json.Unmarshal(d, &g)
+	if err != nil {
+		return nil, err
+	}
+
+	// Fill in the defaults
+	if g.Network == "" {
+		g.Network = DefaultNetwork
+	}
+
+	if g.Message == "" {
+		g.Message = initialData
+	}
+
+	if g.Timestamp == 0 {
+		g.Timestamp = Clock().Unix()
+	}
+
+	if g.Difficulty == 0 {
+		g.Difficulty = Difficulty
+	}
+
+	return &g, g.Validate()
+}
+
+// Validate checks a genesis can be used to create a chain
+func (g *Genesis) Validate() error {
+	if g.Difficulty < 1 || g.Difficulty > MaxDifficulty {
+		return fmt.Errorf("difficulty must be between 1 and %d", MaxDifficulty)
+	}
+
+	if g.Timestamp < 0 {
+		return errors.New("timestamp can't be negative")
+	}
+
+	if g.Maturity < 0 || g.Maturity > MaxCoinbaseMaturity {
+		return fmt.Errorf("coinbase maturity must be between 0 and %d", MaxCoinbaseMaturity)
+	}
+
+	if len(g.Allocations) == 0 {
+		return errors.New("at least one allocation is needed")
+	}
+
+	var t Amount
+
+	for _, a := range g.Allocations {
+		if a.Address == "" || a.Value == 0 {
+			return fmt.Errorf("allocation to %q must have an address and a positive value", a.Address)
+		}
+
+		if _, err := AddressScript(a.Address); err != nil {
+			return fmt.Errorf("allocation: %w", err)
+		}
+
+		if a.Value < g.DustLimit {
+			return fmt.Errorf("allocation to %q is below the dust limit of %d", a.Address, g.DustLimit)
+		}
+
+		// The allocations together can't be worth more than there could ever be
+		var err error
+
+		t, err = t.Add(a.Value)
+		if err != nil {
+			return fmt.Errorf("allocations: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// GenesisTx creates the coinbase transaction for the initial block, paying out each of the allocations
+func GenesisTx(g *Genesis) *Transaction {
+	// Create a transaction input with the message and an output for each allocation
+	tIn := TxInput{[]byte{}, -1, g.Message, MaxSequence, nil}
+
+	var tOut []TxOutput
+	for _, a := range g.Allocations {
+		s, err := AddressScript(a.Address)
+		HandleError(err)
+
+		tOut = append(tOut, TxOutput{a.Value, a.Address, s})
+	}
+
+	// Use the above to construct a new transaction and set its ID
+	t := Transaction{nil, []TxInput{tIn}, tOut, TxVersion, 0}
+	t.SetID()
+
+	return &t
+}
+
+// Returns the chain parameters set by the genesis, keyed by where they are stored
+func (g *Genesis) params() map[string][]byte {
+	return map[string][]byte{
+		networkKey:       []byte(g.Network),
+		deterministicKey: {boolByte(g.Deterministic)},
+		dustLimitKey:     ToHex(int64(g.DustLimit)),
+		maturityKey:      ToHex(int64(g.Maturity)),
+	}
+}
+
+// Records the genesis hash of a chain along with its chain parameters
+func writeGenesisOptions(txn *badger.Txn, h []byte, ps map[string][]byte) error {
+	err := txn.Set([]byte(genesisKey), h)
+	if err != nil {
+		return err
+	}
+
+	return writeChainParams(txn, ps)
+}
+
+// Records the chain parameters
+func writeChainParams(txn *badger.Txn, ps map[string][]byte) error {
+	for _, k := range chainParamKeys {
+		if v, ok := ps[k]; ok {
+			err := txn.Set([]byte(k), v)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// Reads the chain parameters that have been recorded, keyed by where they are stored
+func readChainParams(txn *badger.Txn) (map[string][]byte, error) {
+	ps := make(map[string][]byte)
+
+	for _, k := range chainParamKeys {
+		v, err := getValue(txn, []byte(k))
+		if err == badger.ErrKeyNotFound {
+			continue
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		ps[k] = v
+	}
+
+	return ps, nil
+}
+
+// Reads a single chain parameter, returning nil if it hasn't been recorded
+func getChainParam(txn *badger.Txn, k string) ([]byte, error) {
+	v, err := getValue(txn, []byte(k))
+	if err == badger.ErrKeyNotFound {
+		return nil, nil
+	}
+
+	return v, err
+}
+
+// Reads a single chain parameter outside of a database transaction, handling any errors
+func (bc *BlockChain) chainParam(k string) []byte {
+	// Storage variable for the parameter
+	var v []byte
+
+	// Look up the parameter
+	err := bc.Database.View(func(txn *badger.Txn) error {
+		var err error
+		v, err = getChainParam(txn, k)
+
+		return err
+	})
+	HandleError(err)
+
+	return v
+}
+
+// GenesisHash returns the hash of the chain's initial block
+func (bc *BlockChain) GenesisHash() []byte {
+	// Storage variable for the hash
+	var h []byte
+
+	// Look up the hash, chains made before it was recorded fall back to the height index, handling any errors
+	err := bc.Database.View(func(txn *badger.Txn) error {
+		var err error
+		h, err = getValue(txn, []byte(genesisKey))
+		if err == badger.ErrKeyNotFound {
+			h, err = getValue(txn, hashIndexKey(0))
+		}
+
+		return err
+	})
+	HandleError(err)
+
+	return h
+}
+
+// Network returns the network the chain belongs to, chains made before networks existed belong to the default
+func (bc *BlockChain) Network() string {
+	if v := bc.chainParam(networkKey); v != nil {
+		return string(v)
+	}
+
+	return DefaultNetwork
+}
+
+// Deterministic returns whether the chain's blocks have deterministic timestamps
+func (bc *BlockChain) Deterministic() bool {
+	v := bc.chainParam(deterministicKey)
+
+	return len(v) == 1 && v[0] == 1
+}
+
+// DustLimit returns the value below which outputs are dust, chains made before the limit existed have none
+func (bc *BlockChain) DustLimit() Amount {
+	var d Amount
+
+	err := bc.Database.View(func(txn *badger.Txn) error {
+		var err error
+		d, err = dustLimit(txn)
+
+		return err
+	})
+	HandleError(err)
+
+	return d
+}
+
+// Reads the dust limit within a database transaction
+func dustLimit(txn *badger.Txn) (Amount, error) {
+	v, err := getChainParam(txn, dustLimitKey)
+	if err != nil || len(v) != 8 {
+		return 0, err
+	}
+
+	return Amount(binary.BigEndian.Uint64(v)), nil
+}
+
+// CoinbaseMaturity returns how many confirmations a coinbase output needs before it can be spent, chains made
+// before the rule existed need none
+func (bc *BlockChain) CoinbaseMaturity() int {
+	var m int
+
+	err := bc.Database.View(func(txn *badger.Txn) error {
+		var err error
+		m, err = coinbaseMaturity(txn)
+
+		return err
+	})
+	HandleError(err)
+
+	return m
+}
+
+// Reads the coinbase maturity within a database transaction
+func coinbaseMaturity(txn *badger.Txn) (int, error) {
+	v, err := getChainParam(txn, maturityKey)
+	if err != nil || len(v) != 8 {
+		return 0, err
+	}
+
+	return int(binary.BigEndian.Uint64(v)), nil
+}
@@ -0,0 +1,172 @@
+package blockchain
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+
+	"github.com/dgraph-io/badger"
+)
+
+// Prefix for the address history keys stored alongside the blocks in the database
+const addressIndexPrefix = "ah:"
+
+// ErrInvalidCursor is returned when a history cursor isn't one returned for the address being paged through
+var ErrInvalidCursor = errors.New("cursor is not valid for the address")
+
+// HistoryEntry stores how much a single transaction credited and debited an address
+type HistoryEntry struct {
+	TxID      []byte
+	BlockHash []byte
+	Height    int
+	Received  Amount
+	Sent      Amount
+}
+
+// Creates the prefix all of an address's history keys start with.
+// The address is hex encoded so that one address can never be a prefix of another.
+func addressIndexPrefixFor(a string) []byte {
+	return []byte(addressIndexPrefix + hex.EncodeToString([]byte(a)) + ":")
+}
+
+// Creates the key a transaction is recorded under in an address's history, ordered by height then position
+func addressIndexKey(a string, height, p int) []byte {
+	return bytes.Join([][]byte{addressIndexPrefixFor(a), ToHex(int64(height)), ToHex(int64(p))}, []byte{})
+}
+
+// indexAddresses records every address credited or debited by the transactions in a block
+func (bc *BlockChain) indexAddresses(txn *badger.Txn, b *Block, height int) error {
+	// Loop through the blocks transactions
+	for p, t := range b.Transactions {
+		// Make maps to hold the amounts received and sent by each address in the transaction
+		r := make(map[string]Amount)
+		s := make(map[string]Amount)
+
+		// Each output credits its owner
+		for _, o := range t.Outputs {
+			r[o.PubKey] += o.Value
+		}
+
+		// Each input, other than a coinbase, debits the owner of the output it spends
+		if t.IsCoinbase() == false {
+			for _, in := range t.Inputs {
+				pt, _, err := bc.findInputTransaction(txn, b.Transactions[:p], in.ID)
+				if err != nil {
+					return err
+				}
+
+				s[in.Sig] += pt.Outputs[in.Out].Value
+			}
+		}
+
+		// Write an entry for every address touched by the transaction
+		for a := range r {
+			err := txn.Set(addressIndexKey(a, height, p), encodeHistoryEntry(t.ID, b.Hash, r[a], s[a]))
+			if err != nil {
+				return err
+			}
+		}
+
+		for a := range s {
+			// Addresses that also received have already been written above
+			if _, ok := r[a]; ok {
+				continue
+			}
+
+			err := txn.Set(addressIndexKey(a, height, p), encodeHistoryEntry(t.ID, b.Hash, 0, s[a]))
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// Encodes the value stored for an address history entry
+func encodeHistoryEntry(id, h []byte, r, s Amount) []byte {
+	return bytes.Join(
+		[][]byte{
+			ToHex(int64(r)),
+			ToHex(int64(s)),
+			ToHex(int64(len(h))),
+			h,
+			id,
+		},
+		[]byte{},
+	)
+}
+
+// Decodes an address history entry from its key and value
+func decodeHistoryEntry(k, v []byte) HistoryEntry {
+	// The height is the second to last number in the key
+	height := int(binary.BigEndian.Uint64(k[len(k)-16 : len(k)-8]))
+
+	// The value holds the amounts, then the length prefixed block hash, then the transaction ID
+	r := Amount(binary.BigEndian.Uint64(v[0:8]))
+	s := Amount(binary.BigEndian.Uint64(v[8:16]))
+	hl := int(binary.BigEndian.Uint64(v[16:24]))
+	h := v[24 : 24+hl]
+	id := v[24+hl:]
+
+	return HistoryEntry{id, h, height, r, s}
+}
+
+// AddressHistory returns up to l transactions that credited or debited an address, oldest first.
+// An empty cursor starts from the beginning of the history, the returned cursor continues from where this page ended
+// and is empty when there is nothing left.
+func (bc *BlockChain) AddressHistory(a, c string, l int) ([]HistoryEntry, string, error) {
+	// Storage variables for the entries, the next cursor and the key of the last entry read
+	var es []HistoryEntry
+	var nc string
+	var lk []byte
+
+	// Decode the cursor, which is the hex encoded key of the last entry returned
+	ck, err := hex.DecodeString(c)
+	if err != nil {
+		return nil, "", err
+	}
+
+	p := addressIndexPrefixFor(a)
+
+	// The cursor has to be the key of an entry in this address's history, anything else would start somewhere else
+	if len(ck) != 0 && (bytes.HasPrefix(ck, p) == false || len(ck) != len(p)+16) {
+		return nil, "", ErrInvalidCursor
+	}
+
+	// Make a call to the database to read the history...
+	err = bc.Database.View(func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+
+		// Start at the beginning of the address's history, or just past the cursor
+		start := p
+		if len(ck) != 0 {
+			start = append(ck, 0)
+		}
+
+		for it.Seek(start); it.ValidForPrefix(p); it.Next() {
+			// If the page is full then there is more to come, set the cursor to the last entry and stop
+			if l > 0 && len(es) == l {
+				nc = hex.EncodeToString(lk)
+				break
+			}
+
+			// Copy the key and value out of the iterator and decode the entry
+			item := it.Item()
+			lk = item.KeyCopy(nil)
+
+			v, err := item.ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+
+			es = append(es, decodeHistoryEntry(lk, v))
+		}
+
+		return nil
+	})
+
+	return es, nc, err
+}
@@ -0,0 +1,262 @@
+package blockchain
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+)
+
+// SecretSize is the size of the secrets hash time locked contracts are made with. The contract checks the size of
+// the secret as well as its hash so a secret can't be redeemable on one chain but too big to redeem on another.
+const SecretSize = 32
+
+// Errors returned when making, spending and searching for hash time locked contracts
+var (
+	ErrNotHTLC        = errors.New("not a hash time locked contract")
+	ErrNoHTLCOutputs  = errors.New("no unspent outputs are locked to the contract")
+	ErrWrongSecret    = errors.New("secret does not match the contract's hash")
+	ErrSecretNotFound = errors.New("secret not found")
+)
+
+// HTLC is a hash time locked contract. Coins paid to its address can be redeemed by the recipient with the secret
+// whose sha256 hash is SecretHash, or refunded to the sender once LockTime has passed. Paying the same secret hash
+// to each other on two chains lets two people swap coins without trusting each other, as redeeming one side
+// reveals the secret needed to redeem the other.
+type HTLC struct {
+	SecretHash []byte
+	Recipient  []byte
+	Sender     []byte
+	LockTime   uint32
+}
+
+// NewSecret returns a random secret for a contract along with its sha256 hash
+func NewSecret() ([]byte, []byte, error) {
+	s := make([]byte, SecretSize)
+
+	_, err := rand.Read(s)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	h := sha256.Sum256(s)
+
+	return s, h[:], nil
+}
+
+// NewHTLC makes a contract paying the recipient's key for the secret with the given hash, or the sender's key once
+// the lock time, a block height or unix time as with a transaction's lock time, has passed
+func NewHTLC(h, r, s []byte, lt uint32) (*HTLC, error) {
+	if len(h) != sha256.Size {
+		return nil, fmt.Errorf("%w: secret hash is not %d bytes", ErrNotHTLC, sha256.Size)
+	}
+
+	if len(r) != ed25519.PublicKeySize || len(s) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("%w: public keys must be %d bytes", ErrNotHTLC, ed25519.PublicKeySize)
+	}
+
+	if lt == 0 {
+		return nil, fmt.Errorf("%w: the lock time can't be 0", ErrNotHTLC)
+	}
+
+	return &HTLC{h, r, s, lt}, nil
+}
+
+// Script returns the contract's script, which is paid through its script hash address.
+//
+//	OP_IF
+//	    OP_SIZE 32 OP_EQUALVERIFY OP_SHA256 <secret hash> OP_EQUALVERIFY <recipient> OP_CHECKSIG
+//	OP_ELSE
+//	    <lock time> OP_CHECKLOCKTIMEVERIFY OP_DROP <sender> OP_CHECKSIG
+//	OP_ENDIF
+func (h *HTLC) Script() Script {
+	s := Script{}.AddOp(OpIf).AddOp(OpSize).AddInt(SecretSize).AddOp(OpEqualVerify)
+	s = append(s, HashLockScript(h.SecretHash, Script{}.AddData(h.Recipient).AddOp(OpCheckSig))...)
+	s = s.AddOp(OpElse)
+	s = append(s, LockTimeScript(h.LockTime, Script{}.AddData(h.Sender).AddOp(OpCheckSig))...)
+
+	return s.AddOp(OpEndIf)
+}
+
+// Address returns the script hash address coins are paid to to lock them with the contract
+func (h *HTLC) Address() string {
+	return ScriptHashAddress(h.Script())
+}
+
+// ParseHTLC reads a contract back out of its script
+func ParseHTLC(s Script) (*HTLC, error) {
+	ops, err := s.parse()
+	if err != nil || len(ops) != 16 {
+		return nil, ErrNotHTLC
+	}
+
+	lt, err := decodeScriptNum(pushedData(ops[10]), 5)
+	if err != nil || lt < 1 || lt > int64(MaxSequence) {
+		return nil, ErrNotHTLC
+	}
+
+	h, err := NewHTLC(ops[5].data, ops[7].data, ops[13].data, uint32(lt))
+	if err != nil {
+		return nil, err
+	}
+
+	// Making the script again from what was read out of it has to give the same script back
+	if bytes.Equal(h.Script(), s) == false {
+		return nil, ErrNotHTLC
+	}
+
+	return h, nil
+}
+
+// Returns a transaction spending every unspent output locked to the contract to an address, unsigned
+func (h *HTLC) newSpend(bc *BlockChain, to string) (*Transaction, error) {
+	ts, err := AddressScript(to)
+	if err != nil {
+		return nil, err
+	}
+
+	a := h.Address()
+	s := ScriptHashScript(Hash160(h.Script()))
+
+	var i []TxInput
+	var v Amount
+
+	for _, u := range bc.FindUnspentOutputs(a) {
+		if bytes.Equal(u.ScriptPubKey, s) == false {
+			continue
+		}
+
+		i = append(i, TxInput{u.TxID, u.Out, a, MaxSequence, nil})
+
+		v, err = v.Add(u.Value)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if len(i) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrNoHTLCOutputs, a)
+	}
+
+	tx := Transaction{nil, i, []TxOutput{{v, to, ts}}, TxVersion, 0}
+	tx.SetID()
+
+	return &tx, nil
+}
+
+// Signs each input of a transaction spending the contract, pushing the signature then the branch data before the
+// contract's script
+func (h *HTLC) sign(t *Transaction, k ed25519.PrivateKey, d Script) error {
+	s := h.Script()
+
+	for i := range t.Inputs {
+		err := setScriptHashSig(t, i, append(Script{}.AddData(t.Sign(i, k)), d...), s)
+		if err != nil {
+			return err
+		}
+	}
+
+	t.SetID()
+
+	return nil
+}
+
+// Redeem returns a transaction paying everything locked to the contract to an address, using the secret and the
+// recipient's key. Redeeming puts the secret in the chain for the sender to find.
+func (h *HTLC) Redeem(bc *BlockChain, secret []byte, k ed25519.PrivateKey, to string) (*Transaction, error) {
+	if d := sha256.Sum256(secret); len(secret) != SecretSize || bytes.Equal(d[:], h.SecretHash) == false {
+		return nil, ErrWrongSecret
+	}
+
+	if bytes.Equal(k.Public().(ed25519.PublicKey), h.Recipient) == false {
+		return nil, fmt.Errorf("%w: key is not the contract's recipient", ErrCannotSign)
+	}
+
+	t, err := h.newSpend(bc, to)
+	if err != nil {
+		return nil, err
+	}
+
+	err = h.sign(t, k, Script{}.AddData(secret).AddInt(1))
+	if err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
+
+// Refund returns a transaction paying everything locked to the contract to an address using the sender's key.
+// It is locked until the contract's lock time, so it can't be sent before then.
+func (h *HTLC) Refund(bc *BlockChain, k ed25519.PrivateKey, to string) (*Transaction, error) {
+	if bytes.Equal(k.Public().(ed25519.PublicKey), h.Sender) == false {
+		return nil, fmt.Errorf("%w: key is not the contract's sender", ErrCannotSign)
+	}
+
+	t, err := h.newSpend(bc, to)
+	if err != nil {
+		return nil, err
+	}
+
+	// Lock the transaction before it is signed, as the signatures cover the lock time
+	t.SetLockTime(h.LockTime)
+
+	err = h.sign(t, k, Script{}.AddInt(0))
+	if err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
+
+// ExtractSecret looks through the script sigs of a transaction for the secret whose sha256 hash is the contract's
+func (h *HTLC) ExtractSecret(t *Transaction) ([]byte, error) {
+	for _, in := range t.Inputs {
+		ops, err := in.ScriptSig.parse()
+		if err != nil {
+			continue
+		}
+
+		for _, o := range ops {
+			if d := sha256.Sum256(o.data); len(o.data) == SecretSize && bytes.Equal(d[:], h.SecretHash) {
+				return o.data, nil
+			}
+		}
+	}
+
+	return nil, fmt.Errorf("%w in transaction %x", ErrSecretNotFound, t.ID)
+}
+
+// FindSecret searches the chain for a transaction redeeming the contract, returning the secret it reveals along
+// with the transaction
+func (h *HTLC) FindSecret(bc *BlockChain) ([]byte, *Transaction, error) {
+	a := h.Address()
+	i := bc.CreateIterator()
+
+	for {
+		b := i.Next()
+
+		for _, t := range b.Transactions {
+			if t.IsCoinbase() {
+				continue
+			}
+
+			for _, in := range t.Inputs {
+				if in.CanUnlock(a) == false {
+					continue
+				}
+
+				if s, err := h.ExtractSecret(t); err == nil {
+					return s, t, nil
+				}
+			}
+		}
+
+		if len(b.PreviousHash) == 0 {
+			break
+		}
+	}
+
+	return nil, nil, fmt.Errorf("%w: the contract at %s hasn't been redeemed", ErrSecretNotFound, a)
+}
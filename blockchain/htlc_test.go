@@ -0,0 +1,136 @@
+package blockchain
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/dgraph-io/badger"
+)
+
+// Mines a block holding a coinbase then the given transactions on top of the chain, returning why it is invalid if
+// it is
+func mineTx(bc *BlockChain, txs ...*Transaction) error {
+	pb, err := bc.GetBlock(bc.LatestHash)
+	if err != nil {
+		return err
+	}
+
+	c := CoinbaseTx("miner", fmt.Sprintf("Test block at height %d", bc.Height()+1))
+	nb := CreateBlockAt(append([]*Transaction{c}, txs...), pb.Hash, bc.nextTimestamp(pb), pb.Difficulty)
+
+	return bc.Database.Update(func(txn *badger.Txn) error {
+		err := bc.validateBlock(txn, nb)
+		if err != nil {
+			return err
+		}
+
+		return bc.connectBlock(txn, nb)
+	})
+}
+
+// Generates a key, failing the test if it can't
+func testKey(t *testing.T) ed25519.PrivateKey {
+	t.Helper()
+
+	_, k, err := GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return k
+}
+
+func TestHTLCSpends(t *testing.T) {
+	// The contract can be refunded from this height
+	const lt = 5
+
+	tests := []struct {
+		name   string
+		blocks int
+		spend  func(h *HTLC, bc *BlockChain, s []byte, rk, sk ed25519.PrivateKey) (*Transaction, error)
+		err    error
+		mine   error
+		secret bool
+	}{
+		{"redeem with the secret", 0, func(h *HTLC, bc *BlockChain, s []byte, rk, sk ed25519.PrivateKey) (*Transaction, error) {
+			return h.Redeem(bc, s, rk, "bob")
+		}, nil, nil, true},
+		{"redeem with the wrong secret", 0, func(h *HTLC, bc *BlockChain, s []byte, rk, sk ed25519.PrivateKey) (*Transaction, error) {
+			return h.Redeem(bc, bytes.Repeat([]byte{1}, SecretSize), rk, "bob")
+		}, ErrWrongSecret, nil, false},
+		{"redeem with the sender's key", 0, func(h *HTLC, bc *BlockChain, s []byte, rk, sk ed25519.PrivateKey) (*Transaction, error) {
+			return h.Redeem(bc, s, sk, "bob")
+		}, ErrCannotSign, nil, false},
+		{"refund before the lock time", 0, func(h *HTLC, bc *BlockChain, s []byte, rk, sk ed25519.PrivateKey) (*Transaction, error) {
+			return h.Refund(bc, sk, "alice")
+		}, nil, ErrInvalidTx, false},
+		{"refund after the lock time", lt, func(h *HTLC, bc *BlockChain, s []byte, rk, sk ed25519.PrivateKey) (*Transaction, error) {
+			return h.Refund(bc, sk, "alice")
+		}, nil, nil, false},
+		{"refund with the recipient's key", lt, func(h *HTLC, bc *BlockChain, s []byte, rk, sk ed25519.PrivateKey) (*Transaction, error) {
+			return h.Refund(bc, rk, "alice")
+		}, ErrCannotSign, nil, false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			bc, done := newTestChain(t, "alice", true)
+			defer done()
+
+			// Make a contract between two keys and pay into it
+			rk, sk := testKey(t), testKey(t)
+
+			s, sh, err := NewSecret()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			h, err := NewHTLC(sh, rk.Public().(ed25519.PublicKey), sk.Public().(ed25519.PublicKey), lt)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ft := NewTransaction("alice", []Recipient{{Address: h.Address(), Amount: 40}}, LargestFirst{}, bc)
+
+			if err := mineTx(bc, ft); err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := bc.Generate("miner", tc.blocks); err != nil {
+				t.Fatal(err)
+			}
+
+			// Build the spend, then mine it if it could be built
+			st, err := tc.spend(h, bc, s, rk, sk)
+			if errors.Is(err, tc.err) == false {
+				t.Fatalf("spend error = %v, want %v", err, tc.err)
+			}
+
+			if err != nil {
+				return
+			}
+
+			if err := mineTx(bc, st); errors.Is(err, tc.mine) == false {
+				t.Fatalf("mining the spend = %v, want %v", err, tc.mine)
+			}
+
+			// Redeeming reveals the secret, in the transaction and in the chain, a refund doesn't
+			es, err := h.ExtractSecret(st)
+			if tc.secret != (err == nil) || (tc.secret && bytes.Equal(es, s) == false) {
+				t.Errorf("ExtractSecret() = %x, %v", es, err)
+			}
+
+			if tc.mine != nil {
+				return
+			}
+
+			fs, _, err := h.FindSecret(bc)
+			if tc.secret != (err == nil) || (tc.secret && bytes.Equal(fs, s) == false) {
+				t.Errorf("FindSecret() = %x, %v", fs, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,464 @@
+package blockchain
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+
+	"github.com/dgraph-io/badger"
+)
+
+// Prefixes for the index keys stored alongside the blocks in the database
+const (
+	txIndexPrefix     = "tx:"
+	heightIndexPrefix = "bh:"
+	hashIndexPrefix   = "hb:"
+	spentIndexPrefix  = "sp:"
+	txIndexOption     = "opt:txindex"
+	indexVersionKey   = "opt:indexversion"
+)
+
+// The version of the indexes this code writes, chains with older indexes are reindexed when opened
+const indexVersion = 3
+
+// ErrTxNotFound is returned when a transaction can't be found in the chain
+var ErrTxNotFound = errors.New("transaction not found")
+
+// TxLocation stores where in the chain a transaction lives
+type TxLocation struct {
+	BlockHash []byte
+	Position  int
+	Height    int
+}
+
+// Creates the key a transaction is indexed under
+func txIndexKey(id []byte) []byte {
+	return append([]byte(txIndexPrefix), id...)
+}
+
+// Creates the key a blocks height is indexed under
+func heightIndexKey(h []byte) []byte {
+	return append([]byte(heightIndexPrefix), h...)
+}
+
+// Creates the key the block hash at a given height is indexed under
+func hashIndexKey(height int) []byte {
+	return append([]byte(hashIndexPrefix), ToHex(int64(height))...)
+}
+
+// Fetches the value stored under a key, returning badger.ErrKeyNotFound if there is none
+func getValue(txn *badger.Txn, k []byte) ([]byte, error) {
+	// Storage variable for the value
+	var v []byte
+
+	// Get the item stored under the key
+	item, err := txn.Get(k)
+	if err != nil {
+		return nil, err
+	}
+
+	// Copy the value of the item out of the transaction
+	err = item.Value(func(val []byte) error {
+		v = append([]byte{}, val...)
+
+		return nil
+	})
+
+	return v, err
+}
+
+// Creates the key recording which transaction spent an output
+func spentIndexKey(id []byte, out int) []byte {
+	return bytes.Join([][]byte{[]byte(spentIndexPrefix), id, ToHex(int64(out))}, []byte{})
+}
+
+// Looks up the height of the block with the given hash
+func getHeight(txn *badger.Txn, h []byte) (int, error) {
+	// Get the height stored under the block hash
+	v, err := getValue(txn, heightIndexKey(h))
+	if err != nil {
+		return 0, err
+	}
+
+	return int(binary.BigEndian.Uint64(v)), nil
+}
+
+// indexBlock writes the index entries for a block at a given height
+func (bc *BlockChain) indexBlock(txn *badger.Txn, b *Block, height int) error {
+	// Store the height under the block hash and the block hash under the height
+	err := txn.Set(heightIndexKey(b.Hash), ToHex(int64(height)))
+	if err != nil {
+		return err
+	}
+
+	err = txn.Set(hashIndexKey(height), b.Hash)
+	if err != nil {
+		return err
+	}
+
+	// If the transaction index is turned on, for each transaction store the block hash and its position in the block
+	if bc.TxIndex {
+		for p, t := range b.Transactions {
+			v := append(append([]byte{}, b.Hash...), ToHex(int64(p))...)
+
+			err = txn.Set(txIndexKey(t.ID), v)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	// Record which transaction spent each output spent by the block
+	for _, t := range b.Transactions {
+		if t.IsCoinbase() {
+			continue
+		}
+
+		for _, in := range t.Inputs {
+			err = txn.Set(spentIndexKey(in.ID, in.Out), t.ID)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	// Record the block in the history of each address it touches
+	return bc.indexAddresses(txn, b, height)
+}
+
+// connectBlock stores a block on top of the current latest block, updating the latest hash and the indexes
+func (bc *BlockChain) connectBlock(txn *badger.Txn, b *Block) error {
+	// The initial block sits at height 0, every other block sits on top of its previous block
+	height := 0
+
+	if len(b.PreviousHash) != 0 {
+		ph, err := getHeight(txn, b.PreviousHash)
+		if err != nil {
+			return err
+		}
+
+		height = ph + 1
+	}
+
+	// Set the hash of the block and the serialised data
+	err := txn.Set(b.Hash, b.Serialise())
+	if err != nil {
+		return err
+	}
+
+	// Index the block
+	err = bc.indexBlock(txn, b, height)
+	if err != nil {
+		return err
+	}
+
+	// Take what the block mines, and anything it now conflicts with, out of the pending transactions
+	err = removeMined(txn, b)
+	if err != nil {
+		return err
+	}
+
+	// Set the hash of the block as the latest hash for future use
+	err = txn.Set([]byte("lh"), b.Hash)
+	if err != nil {
+		return err
+	}
+
+	bc.LatestHash = b.Hash
+
+	return nil
+}
+
+// Height returns the height of the latest block in the chain, the initial block being height 0
+func (bc *BlockChain) Height() int {
+	// Storage variable for the height
+	var h int
+
+	// Look up the height of the latest hash, handling any errors
+	err := bc.Database.View(func(txn *badger.Txn) error {
+		var err error
+		h, err = getHeight(txn, bc.LatestHash)
+
+		return err
+	})
+	HandleError(err)
+
+	return h
+}
+
+// Reindex rebuilds the height, spent output and address indexes and, if it is turned on, the transaction index
+// for the whole chain
+func (bc *BlockChain) Reindex() {
+	// Collect the chain from the latest block back to the initial block
+	var blocks []*Block
+
+	it := bc.CreateIterator()
+
+	for {
+		b := it.Next()
+		blocks = append(blocks, b)
+
+		if len(b.PreviousHash) == 0 {
+			break
+		}
+	}
+
+	// With the transaction index turned off any entries left from when it was on would go stale, so remove them
+	if bc.TxIndex == false {
+		err := bc.Database.DropPrefix([]byte(txIndexPrefix))
+		HandleError(err)
+	}
+
+	// Index the blocks from the initial block upwards, one database transaction per block
+	for i := len(blocks) - 1; i >= 0; i-- {
+		b := blocks[i]
+		height := len(blocks) - 1 - i
+
+		err := bc.Database.Update(func(txn *badger.Txn) error {
+			return bc.indexBlock(txn, b, height)
+		})
+		HandleError(err)
+	}
+
+	// Record whether the transaction index is turned on and the index version for future use
+	err := bc.Database.Update(func(txn *badger.Txn) error {
+		return writeIndexOptions(txn, bc.TxIndex)
+	})
+	HandleError(err)
+}
+
+// Writes the transaction index option and the current index version
+func writeIndexOptions(txn *badger.Txn, ti bool) error {
+	err := txn.Set([]byte(txIndexOption), []byte{boolByte(ti)})
+	if err != nil {
+		return err
+	}
+
+	return txn.Set([]byte(indexVersionKey), ToHex(indexVersion))
+}
+
+// Checks whether the indexes in the database were written by an older version of the code
+func indexesOutdated(db *badger.DB) bool {
+	// Storage variable for the version, a missing version means the indexes predate versioning
+	v := 0
+
+	// Read the version, handling any errors
+	err := db.View(func(txn *badger.Txn) error {
+		b, err := getValue(txn, []byte(indexVersionKey))
+		if err == badger.ErrKeyNotFound {
+			return nil
+		}
+
+		if err == nil {
+			v = int(binary.BigEndian.Uint64(b))
+		}
+
+		return err
+	})
+	HandleError(err)
+
+	return v < indexVersion
+}
+
+// Reads whether the transaction index is turned on for the database
+func loadTxIndexOption(db *badger.DB) bool {
+	// Storage variable for the option
+	on := false
+
+	// Read the option, a missing option means the index is off
+	err := db.View(func(txn *badger.Txn) error {
+		v, err := getValue(txn, []byte(txIndexOption))
+		if err == badger.ErrKeyNotFound {
+			return nil
+		}
+
+		on = err == nil && len(v) == 1 && v[0] == 1
+
+		return err
+	})
+	HandleError(err)
+
+	return on
+}
+
+// Turns a bool into a single byte
+func boolByte(b bool) byte {
+	if b {
+		return 1
+	}
+
+	return 0
+}
+
+// FindTransaction returns the transaction with the given ID along with where it lives in the chain.
+// The transaction index is used when it is turned on, otherwise the chain is scanned.
+func (bc *BlockChain) FindTransaction(id []byte) (*Transaction, *TxLocation, error) {
+	// Storage variables for the transaction and its location
+	var t *Transaction
+	var l *TxLocation
+
+	// Find the transaction and its blocks height within one database transaction
+	err := bc.Database.View(func(txn *badger.Txn) error {
+		var b *Block
+		var p int
+		var err error
+
+		t, b, p, err = bc.findTransaction(txn, id)
+		if err != nil {
+			return err
+		}
+
+		h, err := getHeight(txn, b.Hash)
+		l = &TxLocation{b.Hash, p, h}
+
+		return err
+	})
+
+	return t, l, err
+}
+
+// Finds a transaction along with its block and its position in the block
+func (bc *BlockChain) findTransaction(txn *badger.Txn, id []byte) (*Transaction, *Block, int, error) {
+	// If the index is turned on use it to go straight to the block
+	if bc.TxIndex {
+		v, err := getValue(txn, txIndexKey(id))
+		if err == badger.ErrKeyNotFound {
+			return nil, nil, 0, ErrTxNotFound
+		}
+
+		if err != nil {
+			return nil, nil, 0, err
+		}
+
+		// Split the entry into the block hash and the position and fetch the block
+		h := v[:len(v)-8]
+		p := int(binary.BigEndian.Uint64(v[len(v)-8:]))
+
+		rb, err := getValue(txn, h)
+		if err != nil {
+			return nil, nil, 0, err
+		}
+
+		b := Deserialise(rb)
+
+		return b.Transactions[p], b, p, nil
+	}
+
+	// Otherwise loop through the chain from the latest block looking for the transaction
+	h := bc.LatestHash
+
+	for len(h) != 0 {
+		rb, err := getValue(txn, h)
+		if err != nil {
+			return nil, nil, 0, err
+		}
+
+		b := Deserialise(rb)
+
+		for p, t := range b.Transactions {
+			if bytes.Equal(t.ID, id) {
+				return t, b, p, nil
+			}
+		}
+
+		h = b.PreviousHash
+	}
+
+	return nil, nil, 0, ErrTxNotFound
+}
+
+// Finds the transaction an input spends along with its block. The transactions before the input's own in the
+// block being checked or connected are looked through first, as that block isn't in the chain yet, and the block
+// returned is nil if the transaction is one of them.
+func (bc *BlockChain) findInputTransaction(txn *badger.Txn, bt []*Transaction, id []byte) (*Transaction, *Block, error) {
+	for _, t := range bt {
+		if bytes.Equal(t.ID, id) {
+			return t, nil, nil
+		}
+	}
+
+	t, b, _, err := bc.findTransaction(txn, id)
+
+	return t, b, err
+}
+
+// GetBlock returns the block stored under the given hash
+func (bc *BlockChain) GetBlock(h []byte) (*Block, error) {
+	// Storage variable for the raw block
+	var rb []byte
+
+	// Get the raw block stored under the hash
+	err := bc.Database.View(func(txn *badger.Txn) error {
+		var err error
+		rb, err = getValue(txn, h)
+
+		return err
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return Deserialise(rb), nil
+}
+
+// GetBlockHash returns the hash of the block at the given height
+func (bc *BlockChain) GetBlockHash(height int) ([]byte, error) {
+	// Storage variable for the hash
+	var h []byte
+
+	// Get the hash stored under the height
+	err := bc.Database.View(func(txn *badger.Txn) error {
+		var err error
+		h, err = getValue(txn, hashIndexKey(height))
+
+		return err
+	})
+
+	return h, err
+}
+
+// GetBlockHeight returns the height of the block with the given hash
+func (bc *BlockChain) GetBlockHeight(h []byte) (int, error) {
+	// Storage variable for the height
+	var height int
+
+	// Look up the height stored under the hash
+	err := bc.Database.View(func(txn *badger.Txn) error {
+		var err error
+		height, err = getHeight(txn, h)
+
+		return err
+	})
+
+	return height, err
+}
+
+// Checks whether an output has been spent by a transaction in the chain
+func isSpent(txn *badger.Txn, id []byte, out int) (bool, error) {
+	_, err := txn.Get(spentIndexKey(id, out))
+	if err == badger.ErrKeyNotFound {
+		return false, nil
+	}
+
+	return err == nil, err
+}
+
+// IsSpent checks whether an output has been spent by a transaction in the chain
+func (bc *BlockChain) IsSpent(id []byte, out int) (bool, error) {
+	var s bool
+
+	err := bc.Database.View(func(txn *badger.Txn) error {
+		var err error
+		s, err = isSpent(txn, id, out)
+
+		return err
+	})
+
+	return s, err
+}
+
+// Confirmations returns how many blocks have been built on top of a location, including its own block
+func (bc *BlockChain) Confirmations(l *TxLocation) int {
+	return bc.Height() - l.Height + 1
+}
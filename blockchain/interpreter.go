@@ -0,0 +1,411 @@
+package blockchain
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+)
+
+// ErrScriptFailed is returned when a script doesn't allow an output to be spent
+var ErrScriptFailed = errors.New("script failed")
+
+// Wraps ErrScriptFailed with the reason for it
+func scriptFailed(format string, a ...interface{}) error {
+	return fmt.Errorf("%w: %s", ErrScriptFailed, fmt.Sprintf(format, a...))
+}
+
+// engine runs scripts for one input of a transaction, keeping the stack between them
+type engine struct {
+	tx    *Transaction
+	in    int
+	stack [][]byte
+	ops   int
+}
+
+// VerifyScript checks that input in of a transaction, with the given ScriptSig, can spend an output locked with
+// the given ScriptPubKey. The ScriptSig can only push data, and the ScriptPubKey is run on the stack it leaves.
+// If the ScriptPubKey is a script hash, the last item the ScriptSig pushes is the script that was hashed and it
+// is then run on the rest of the items.
+func VerifyScript(sig, pk Script, t *Transaction, in int) error {
+	if sig.IsPushOnly() == false {
+		return scriptFailed("script sig does more than push data")
+	}
+
+	e := engine{tx: t, in: in}
+
+	if err := e.run(sig); err != nil {
+		return err
+	}
+
+	// Keep the stack the script sig leaves in case the script it pushed needs to be run on it
+	ss := append([][]byte{}, e.stack...)
+
+	if err := e.run(pk); err != nil {
+		return err
+	}
+
+	if err := e.finished(); err != nil {
+		return err
+	}
+
+	if pk.isScriptHash() == false {
+		return nil
+	}
+
+	// The hash matched so run the script on what was pushed before it, with a fresh opcode count
+	e = engine{tx: t, in: in, stack: ss}
+
+	rs, err := e.pop()
+	if err != nil {
+		return err
+	}
+
+	if err := e.run(rs); err != nil {
+		return err
+	}
+
+	return e.finished()
+}
+
+// Checks a script finished with a true value on top of the stack
+func (e *engine) finished() error {
+	if len(e.stack) == 0 || castToBool(e.stack[len(e.stack)-1]) == false {
+		return scriptFailed("script finished without a true value on the stack")
+	}
+
+	return nil
+}
+
+// Pops the top item off the stack
+func (e *engine) pop() ([]byte, error) {
+	if len(e.stack) == 0 {
+		return nil, scriptFailed("stack is empty")
+	}
+
+	v := e.stack[len(e.stack)-1]
+	e.stack = e.stack[:len(e.stack)-1]
+
+	return v, nil
+}
+
+// Pops the top item off the stack as a number of at most 4 bytes
+func (e *engine) popInt() (int64, error) {
+	v, err := e.pop()
+	if err != nil {
+		return 0, err
+	}
+
+	return decodeScriptNum(v, 4)
+}
+
+// Pushes an item onto the stack
+func (e *engine) push(v []byte) {
+	e.stack = append(e.stack, v)
+}
+
+// Pushes a true or false value onto the stack
+func (e *engine) pushBool(b bool) {
+	if b {
+		e.push([]byte{1})
+	} else {
+		e.push(nil)
+	}
+}
+
+// Pops the top item off the stack, failing if it isn't true, for the opcodes ending in verify
+func (e *engine) verify(op Opcode) error {
+	v, err := e.pop()
+	if err != nil {
+		return err
+	}
+
+	if castToBool(v) == false {
+		return scriptFailed("%s failed", op)
+	}
+
+	return nil
+}
+
+// Runs a script on the stack
+func (e *engine) run(s Script) error {
+	if len(s) > MaxScriptSize {
+		return scriptFailed("script is %d bytes, more than the limit of %d", len(s), MaxScriptSize)
+	}
+
+	ops, err := s.parse()
+	if err != nil {
+		return scriptFailed("%s", err)
+	}
+
+	// Whether each branch of the conditionals the script is in is being run
+	var cond []bool
+
+	for _, o := range ops {
+		if len(o.data) > MaxScriptElementSize {
+			return scriptFailed("push of %d bytes is more than the limit of %d", len(o.data), MaxScriptElementSize)
+		}
+
+		// Only opcodes that aren't pushes count towards the limit, whether they are run or not
+		if o.op.isPush() == false {
+			e.ops++
+
+			if e.ops > MaxScriptOps {
+				return scriptFailed("script runs more than %d opcodes", MaxScriptOps)
+			}
+		}
+
+		run := true
+		for _, c := range cond {
+			run = run && c
+		}
+
+		// Outside of a branch being run, only the conditionals themselves are followed
+		if run == false && (o.op < OpIf || o.op > OpEndIf) {
+			continue
+		}
+
+		if err := e.step(o, run, &cond); err != nil {
+			return err
+		}
+
+		if len(e.stack) > MaxStackSize {
+			return scriptFailed("stack holds more than %d items", MaxStackSize)
+		}
+	}
+
+	if len(cond) != 0 {
+		return scriptFailed("OP_IF without OP_ENDIF")
+	}
+
+	return nil
+}
+
+// Runs a single opcode, run is whether the branch the opcode is in is being run
+func (e *engine) step(o scriptOp, run bool, cond *[]bool) error {
+	switch {
+	case o.op <= OpPushData2:
+		e.push(o.data)
+		return nil
+	case o.op >= Op1 && o.op <= Op16:
+		e.push(encodeScriptNum(int64(o.op-Op1) + 1))
+		return nil
+	}
+
+	switch o.op {
+	case OpIf, OpNotIf:
+		// A branch inside one that isn't being run isn't run either, without taking anything off the stack
+		b := false
+
+		if run {
+			v, err := e.pop()
+			if err != nil {
+				return err
+			}
+
+			b = castToBool(v) == (o.op == OpIf)
+		}
+
+		*cond = append(*cond, b)
+
+	case OpElse:
+		if len(*cond) == 0 {
+			return scriptFailed("OP_ELSE without OP_IF")
+		}
+
+		(*cond)[len(*cond)-1] = !(*cond)[len(*cond)-1]
+
+	case OpEndIf:
+		if len(*cond) == 0 {
+			return scriptFailed("OP_ENDIF without OP_IF")
+		}
+
+		*cond = (*cond)[:len(*cond)-1]
+
+	case OpVerify:
+		return e.verify(o.op)
+
+	case OpReturn:
+		return scriptFailed("OP_RETURN makes an output unspendable")
+
+	case OpDrop:
+		_, err := e.pop()
+		return err
+
+	case OpDup:
+		v, err := e.pop()
+		if err != nil {
+			return err
+		}
+
+		e.push(v)
+		e.push(v)
+
+	case OpSize:
+		if len(e.stack) == 0 {
+			return scriptFailed("stack is empty")
+		}
+
+		e.push(encodeScriptNum(int64(len(e.stack[len(e.stack)-1]))))
+
+	case OpEqual, OpEqualVerify:
+		a, err := e.pop()
+		if err != nil {
+			return err
+		}
+
+		b, err := e.pop()
+		if err != nil {
+			return err
+		}
+
+		e.pushBool(bytes.Equal(a, b))
+
+		if o.op == OpEqualVerify {
+			return e.verify(o.op)
+		}
+
+	case OpSha256:
+		v, err := e.pop()
+		if err != nil {
+			return err
+		}
+
+		h := sha256.Sum256(v)
+		e.push(h[:])
+
+	case OpHash160:
+		v, err := e.pop()
+		if err != nil {
+			return err
+		}
+
+		e.push(Hash160(v))
+
+	case OpCheckSig, OpCheckSigVerify:
+		pk, err := e.pop()
+		if err != nil {
+			return err
+		}
+
+		sig, err := e.pop()
+		if err != nil {
+			return err
+		}
+
+		e.pushBool(CheckSignature(e.tx, e.in, pk, sig))
+
+		if o.op == OpCheckSigVerify {
+			return e.verify(o.op)
+		}
+
+	case OpCheckMultiSig, OpCheckMultiSigVerify:
+		err := e.checkMultiSig()
+		if err != nil {
+			return err
+		}
+
+		if o.op == OpCheckMultiSigVerify {
+			return e.verify(o.op)
+		}
+
+	case OpCheckLockTimeVerify:
+		return e.checkLockTime()
+
+	default:
+		return scriptFailed("unknown opcode %s", o.op)
+	}
+
+	return nil
+}
+
+// Checks m of n signatures, with the stack holding the signatures, m, the public keys and then n on top.
+// The signatures must be in the same order as their keys, so each key can only be matched once.
+func (e *engine) checkMultiSig() error {
+	n, err := e.popInt()
+	if err != nil {
+		return err
+	}
+
+	if n < 0 || n > MaxMultiSigKeys {
+		return scriptFailed("multisig with %d keys", n)
+	}
+
+	// Each key counts towards the opcode limit
+	e.ops += int(n)
+	if e.ops > MaxScriptOps {
+		return scriptFailed("script runs more than %d opcodes", MaxScriptOps)
+	}
+
+	// The keys come off the stack last first
+	pks := make([][]byte, n)
+	for i := n - 1; i >= 0; i-- {
+		if pks[i], err = e.pop(); err != nil {
+			return err
+		}
+	}
+
+	m, err := e.popInt()
+	if err != nil {
+		return err
+	}
+
+	if m < 0 || m > n {
+		return scriptFailed("multisig needing %d of %d signatures", m, n)
+	}
+
+	sigs := make([][]byte, m)
+	for i := m - 1; i >= 0; i-- {
+		if sigs[i], err = e.pop(); err != nil {
+			return err
+		}
+	}
+
+	// Match the signatures against the keys in order, failing once there are more signatures left than keys
+	s, k := 0, 0
+	for s < len(sigs) && len(sigs)-s <= len(pks)-k {
+		if CheckSignature(e.tx, e.in, pks[k], sigs[s]) {
+			s++
+		}
+
+		k++
+	}
+
+	e.pushBool(s == len(sigs))
+
+	return nil
+}
+
+// Checks the transaction's lock time has reached the lock time on top of the stack, leaving it there. Both must be
+// heights or both times, and the input can't have opted out of the transaction's lock time.
+func (e *engine) checkLockTime() error {
+	if len(e.stack) == 0 {
+		return scriptFailed("stack is empty")
+	}
+
+	// Lock times go up to 2^32 so need 5 bytes
+	lt, err := decodeScriptNum(e.stack[len(e.stack)-1], 5)
+	if err != nil {
+		return err
+	}
+
+	if lt < 0 || lt > int64(MaxSequence) {
+		return scriptFailed("lock time %d is out of range", lt)
+	}
+
+	tlt := e.tx.LockTime
+
+	if (uint32(lt) < LockTimeThreshold) != (tlt < LockTimeThreshold) {
+		return scriptFailed("lock time %d and transaction lock time %d aren't both heights or both times", lt, tlt)
+	}
+
+	if uint32(lt) > tlt {
+		return scriptFailed("locked until %s but the transaction is locked until %s", DescribeLockTime(uint32(lt)), DescribeLockTime(tlt))
+	}
+
+	if e.tx.Inputs[e.in].Sequence == MaxSequence {
+		return scriptFailed("input %d opts out of the transaction's lock time", e.in)
+	}
+
+	return nil
+}
@@ -0,0 +1,332 @@
+package blockchain
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"testing"
+)
+
+// Makes a transaction with one input, locked until height 10, for scripts to be checked against
+func scriptTestTx() *Transaction {
+	t := Transaction{nil, []TxInput{{bytes.Repeat([]byte{1}, 32), 0, "", MaxSequence - 1, nil}}, []TxOutput{{10, "bob", nil}}, TxVersion, 10}
+	t.SetID()
+
+	return &t
+}
+
+// Reads a script written out as opcodes, failing the test if it can't
+func mustScript(t *testing.T, w string) Script {
+	t.Helper()
+
+	s, err := ParseScript(w)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return s
+}
+
+// Returns a script pushing the same data n times
+func repeatPush(d []byte, n int) Script {
+	s := Script{}
+
+	for i := 0; i < n; i++ {
+		s = s.AddData(d)
+	}
+
+	return s
+}
+
+// Returns a script running the same opcode n times
+func repeatOp(op Opcode, n int) Script {
+	s := Script{}
+
+	for i := 0; i < n; i++ {
+		s = s.AddOp(op)
+	}
+
+	return s
+}
+
+func TestScriptOpcodes(t *testing.T) {
+	sha := sha256.Sum256([]byte("abc"))
+
+	tests := []struct {
+		name string
+		pk   string
+		ok   bool
+	}{
+		{"true", "OP_1", true},
+		{"false", "OP_0", false},
+		{"nothing on the stack", "", false},
+		{"negative zero is false", "80", false},
+		{"zeros are false", "0000", false},
+		{"any other data is true", "0001", true},
+		{"numbers", "OP_16 10 OP_EQUAL", true},
+		{"if runs its branch", "OP_1 OP_IF OP_1 OP_ELSE OP_0 OP_ENDIF", true},
+		{"if runs the else branch", "OP_0 OP_IF OP_1 OP_ELSE OP_0 OP_ENDIF", false},
+		{"notif", "OP_0 OP_NOTIF OP_1 OP_ELSE OP_0 OP_ENDIF", true},
+		{"nested if in a branch not run takes nothing off the stack", "OP_1 OP_0 OP_IF OP_IF OP_0 OP_ENDIF OP_ENDIF", true},
+		{"return in a branch not run", "OP_0 OP_IF OP_RETURN OP_ENDIF OP_1", true},
+		{"if without endif", "OP_1 OP_IF OP_1", false},
+		{"else without if", "OP_1 OP_ELSE", false},
+		{"endif without if", "OP_1 OP_ENDIF", false},
+		{"if on an empty stack", "OP_IF OP_ENDIF OP_1", false},
+		{"verify true", "OP_1 OP_VERIFY OP_1", true},
+		{"verify false", "OP_0 OP_VERIFY OP_1", false},
+		{"return", "OP_1 OP_RETURN", false},
+		{"drop", "OP_1 OP_0 OP_DROP", true},
+		{"drop on an empty stack", "OP_DROP OP_1", false},
+		{"dup", "OP_1 OP_DUP OP_EQUAL", true},
+		{"dup on an empty stack", "OP_DUP", false},
+		{"size", "616263 OP_SIZE OP_3 OP_EQUALVERIFY", true},
+		{"size on an empty stack", "OP_SIZE", false},
+		{"equal", "0102 0102 OP_EQUAL", true},
+		{"not equal", "0102 0103 OP_EQUAL", false},
+		{"equal with one item", "01 OP_EQUAL", false},
+		{"equalverify failing", "0102 0103 OP_EQUALVERIFY OP_1", false},
+		{"sha256", "616263 OP_SHA256 " + hex.EncodeToString(sha[:]) + " OP_EQUAL", true},
+		{"sha256 of something else", "616264 OP_SHA256 " + hex.EncodeToString(sha[:]) + " OP_EQUAL", false},
+		{"hash160", "616263 OP_HASH160 " + hex.EncodeToString(Hash160([]byte("abc"))) + " OP_EQUAL", true},
+		{"hash160 on an empty stack", "OP_HASH160", false},
+		{"lock time reached", "0a OP_CHECKLOCKTIMEVERIFY", true},
+		{"lock time below the transaction's", "OP_5 OP_CHECKLOCKTIMEVERIFY OP_DROP OP_1", true},
+		{"lock time not reached", "0b OP_CHECKLOCKTIMEVERIFY", false},
+		{"lock time that is a time", "0065cd1d OP_CHECKLOCKTIMEVERIFY", false},
+		{"negative lock time", "81 OP_CHECKLOCKTIMEVERIFY", false},
+		{"lock time on an empty stack", "OP_CHECKLOCKTIMEVERIFY", false},
+		{"lock time too long", "0100000000ff OP_CHECKLOCKTIMEVERIFY", false},
+		{"unknown opcode", "OP_1 OP_UNKNOWN_255", false},
+		{"unknown opcode in a branch not run", "OP_0 OP_IF OP_UNKNOWN_255 OP_ENDIF OP_1", true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := VerifyScript(Script{}, mustScript(t, tc.pk), scriptTestTx(), 0)
+
+			if (err == nil) != tc.ok || (err != nil && errors.Is(err, ErrScriptFailed) == false) {
+				t.Errorf("VerifyScript(%q) = %v, want ok %t", tc.pk, err, tc.ok)
+			}
+		})
+	}
+}
+
+func TestScriptSignatures(t *testing.T) {
+	ks := []ed25519.PrivateKey{testKey(t), testKey(t), testKey(t)}
+
+	var pks [][]byte
+	for _, k := range ks {
+		pks = append(pks, k.Public().(ed25519.PublicKey))
+	}
+
+	tx := scriptTestTx()
+	sig := func(i int) []byte { return tx.Sign(0, ks[i]) }
+
+	tests := []struct {
+		name string
+		sig  Script
+		pk   Script
+		ok   bool
+	}{
+		{"key hash", Script{}.AddData(sig(0)).AddData(pks[0]), PubKeyHashScript(Hash160(pks[0])), true},
+		{"key hash with another key's signature", Script{}.AddData(sig(1)).AddData(pks[0]), PubKeyHashScript(Hash160(pks[0])), false},
+		{"key hash with another key", Script{}.AddData(sig(1)).AddData(pks[1]), PubKeyHashScript(Hash160(pks[0])), false},
+		{"checksig with a bad signature", Script{}.AddData(sig(0)[1:]).AddData(pks[0]), Script{}.AddOp(OpCheckSig), false},
+		{"checksig with a short key", Script{}.AddData(sig(0)).AddData(pks[0][1:]), Script{}.AddOp(OpCheckSig), false},
+		{"checksig on an empty stack", Script{}, Script{}.AddOp(OpCheckSig), false},
+		{"checksigverify", Script{}.AddData(sig(0)), Script{}.AddData(pks[0]).AddOp(OpCheckSigVerify).AddOp(Op1), true},
+		{"checksigverify failing", Script{}.AddData(sig(1)), Script{}.AddData(pks[0]).AddOp(OpCheckSigVerify).AddOp(Op1), false},
+		{"2 of 3", Script{}.AddData(sig(0)).AddData(sig(2)), MultiSigScript(2, pks), true},
+		{"2 of 3 out of order", Script{}.AddData(sig(2)).AddData(sig(0)), MultiSigScript(2, pks), false},
+		{"2 of 3 with the same signature twice", Script{}.AddData(sig(0)).AddData(sig(0)), MultiSigScript(2, pks), false},
+		{"2 of 3 with one signature", Script{}.AddData(sig(1)), MultiSigScript(2, pks), false},
+		{"0 of 3", Script{}, MultiSigScript(0, pks), true},
+		{"more signatures than keys", Script{}.AddData(sig(0)).AddData(sig(1)).AddData(sig(2)).AddData(sig(2)), MultiSigScript(4, pks), false},
+		{"more keys than allowed", Script{}, Script{}.AddOp(Op0).AddInt(MaxMultiSigKeys + 1).AddOp(OpCheckMultiSig), false},
+		{"multisigverify", Script{}.AddData(sig(1)), append(MultiSigScript(1, pks[1:2]), byte(OpVerify), byte(Op1)), true},
+		{"script hash", Script{}.AddData(sig(0)).AddData(Script{}.AddData(pks[0]).AddOp(OpCheckSig)), ScriptHashScript(Hash160(Script{}.AddData(pks[0]).AddOp(OpCheckSig))), true},
+		{"script hash with a failing script", Script{}.AddData(sig(1)).AddData(Script{}.AddData(pks[0]).AddOp(OpCheckSig)), ScriptHashScript(Hash160(Script{}.AddData(pks[0]).AddOp(OpCheckSig))), false},
+		{"script hash with another script", Script{}.AddData(Script{}.AddOp(Op1)), ScriptHashScript(Hash160(Script{}.AddInt(2))), false},
+		{"script sig that does more than push", Script{}.AddOp(Op1).AddOp(OpDup), Script{}.AddOp(OpEqual), false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := VerifyScript(tc.sig, tc.pk, tx, 0); (err == nil) != tc.ok {
+				t.Errorf("VerifyScript(%s, %s) = %v, want ok %t", tc.sig, tc.pk, err, tc.ok)
+			}
+		})
+	}
+
+	// Signatures cover the transaction, so changing it breaks them
+	c := *tx
+	c.Outputs = []TxOutput{{11, "bob", nil}}
+
+	if err := VerifyScript(Script{}.AddData(sig(0)).AddData(pks[0]), PubKeyHashScript(Hash160(pks[0])), &c, 0); err == nil {
+		t.Errorf("signature still valid after the outputs changed")
+	}
+}
+
+func TestScriptLockTimeSequence(t *testing.T) {
+	// An input opting out of the lock time can't pass a lock time check
+	tx := scriptTestTx()
+	tx.Inputs[0].Sequence = MaxSequence
+
+	if err := VerifyScript(Script{}, LockTimeScript(5, Script{}.AddOp(Op1)), tx, 0); err == nil {
+		t.Errorf("lock time check passed for an input with the final sequence")
+	}
+
+	tx.Inputs[0].Sequence = MaxSequence - 1
+
+	if err := VerifyScript(Script{}, LockTimeScript(5, Script{}.AddOp(Op1)), tx, 0); err != nil {
+		t.Errorf("VerifyScript() = %v", err)
+	}
+}
+
+func TestScriptLimits(t *testing.T) {
+	tests := []struct {
+		name string
+		pk   Script
+		ok   bool
+	}{
+		{"push at the element limit", Script{}.AddData(make([]byte, MaxScriptElementSize)).AddOp(OpDrop).AddOp(Op1), true},
+		{"push past the element limit", Script{}.AddData(make([]byte, MaxScriptElementSize+1)).AddOp(OpDrop).AddOp(Op1), false},
+		{"opcodes at the limit", append(Script{}.AddOp(Op1), repeatOp(OpDup, MaxScriptOps)...), true},
+		{"opcodes past the limit", append(Script{}.AddOp(Op1), repeatOp(OpDup, MaxScriptOps+1)...), false},
+		{"opcodes in a branch not run count", append(Script{}.AddOp(Op1).AddOp(Op0).AddOp(OpIf), append(repeatOp(OpDup, MaxScriptOps), byte(OpEndIf))...), false},
+		{"stack at the limit", repeatPush([]byte{1}, MaxStackSize), true},
+		{"stack past the limit", repeatPush([]byte{1}, MaxStackSize+1), false},
+		{"script past the size limit", append(repeatPush(make([]byte, 100), MaxScriptSize/101), repeatOp(Op1, 101)...), false},
+		{"multisig keys count as opcodes", append(append(Script{}.AddOp(Op0), repeatOp(OpDup, MaxScriptOps-MaxMultiSigKeys)...), Script{}.AddOp(Op0).AddInt(MaxMultiSigKeys).AddOp(OpCheckMultiSig)...), false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := VerifyScript(Script{}, tc.pk, scriptTestTx(), 0); (err == nil) != tc.ok {
+				t.Errorf("VerifyScript() = %v, want ok %t", err, tc.ok)
+			}
+		})
+	}
+}
+
+func TestMalformedScripts(t *testing.T) {
+	tests := []struct {
+		name string
+		s    Script
+	}{
+		{"push past the end", Script{0x05, 0x01, 0x02}},
+		{"pushdata1 without a length", Script{byte(OpPushData1)}},
+		{"pushdata1 past the end", Script{byte(OpPushData1), 0x03, 0x01}},
+		{"pushdata2 without a length", Script{byte(OpPushData2), 0x01}},
+		{"pushdata2 past the end", Script{byte(OpPushData2), 0x00, 0x01, 0x01}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := tc.s.parse(); errors.Is(err, ErrBadScript) == false {
+				t.Errorf("parse() = %v, want %v", err, ErrBadScript)
+			}
+
+			if tc.s.IsPushOnly() {
+				t.Errorf("IsPushOnly() = true for a malformed script")
+			}
+
+			if err := VerifyScript(Script{}, tc.s, scriptTestTx(), 0); errors.Is(err, ErrScriptFailed) == false {
+				t.Errorf("VerifyScript() = %v, want %v", err, ErrScriptFailed)
+			}
+
+			if err := VerifyScript(tc.s, Script{}.AddOp(Op1), scriptTestTx(), 0); err == nil {
+				t.Errorf("VerifyScript() passed with a malformed script sig")
+			}
+		})
+	}
+}
+
+func TestScriptNums(t *testing.T) {
+	tests := []struct {
+		n    int64
+		want []byte
+	}{
+		{0, nil},
+		{1, []byte{0x01}},
+		{-1, []byte{0x81}},
+		{127, []byte{0x7f}},
+		{128, []byte{0x80, 0x00}},
+		{-128, []byte{0x80, 0x80}},
+		{255, []byte{0xff, 0x00}},
+		{256, []byte{0x00, 0x01}},
+		{-256, []byte{0x00, 0x81}},
+		{1<<31 - 1, []byte{0xff, 0xff, 0xff, 0x7f}},
+		{1 << 32, []byte{0x00, 0x00, 0x00, 0x00, 0x01}},
+	}
+
+	for _, tc := range tests {
+		b := encodeScriptNum(tc.n)
+		if bytes.Equal(b, tc.want) == false {
+			t.Errorf("encodeScriptNum(%d) = %x, want %x", tc.n, b, tc.want)
+		}
+
+		if n, err := decodeScriptNum(b, 5); err != nil || n != tc.n {
+			t.Errorf("decodeScriptNum(%x) = %d, %v, want %d", b, n, err, tc.n)
+		}
+	}
+
+	if _, err := decodeScriptNum([]byte{1, 2, 3, 4, 5}, 4); errors.Is(err, ErrScriptFailed) == false {
+		t.Errorf("decodeScriptNum() of 5 bytes with a limit of 4 = %v, want %v", err, ErrScriptFailed)
+	}
+}
+
+func TestScriptWriting(t *testing.T) {
+	// Pushes use the smallest opcode that fits the data
+	tests := []struct {
+		n      int
+		prefix []byte
+	}{
+		{1, []byte{0x01}},
+		{75, []byte{75}},
+		{76, []byte{byte(OpPushData1), 76}},
+		{255, []byte{byte(OpPushData1), 255}},
+		{256, []byte{byte(OpPushData2), 0x00, 0x01}},
+	}
+
+	for _, tc := range tests {
+		s := Script{}.AddData(make([]byte, tc.n))
+
+		if bytes.HasPrefix(s, tc.prefix) == false || len(s) != len(tc.prefix)+tc.n {
+			t.Errorf("AddData() of %d bytes starts %x, want %x", tc.n, s[:len(tc.prefix)], tc.prefix)
+		}
+	}
+
+	if s := (Script{}).AddData(nil); bytes.Equal(s, Script{byte(Op0)}) == false {
+		t.Errorf("AddData() of nothing = %x, want OP_0", []byte(s))
+	}
+
+	// Written scripts read back the same
+	for _, w := range []string{"OP_DUP OP_HASH160 0102 OP_EQUALVERIFY OP_CHECKSIG", "OP_0 OP_16 OP_IF OP_ELSE OP_ENDIF", ""} {
+		if s := mustScript(t, w); s.String() != w {
+			t.Errorf("ParseScript(%q).String() = %q", w, s.String())
+		}
+	}
+
+	if _, err := ParseScript("OP_DUP OP_NOPE"); errors.Is(err, ErrBadScript) == false {
+		t.Errorf("ParseScript() with an unknown word = %v, want %v", err, ErrBadScript)
+	}
+
+	if s := (Script{0x05}).String(); s != "[malformed script 05]" {
+		t.Errorf("String() of a malformed script = %q", s)
+	}
+
+	// Signature checks are counted for the block limit
+	pks := [][]byte{make([]byte, 32), make([]byte, 32)}
+
+	if n := MultiSigScript(1, pks).sigOps(); n != 2 {
+		t.Errorf("sigOps() of a 1 of 2 multisig = %d, want 2", n)
+	}
+
+	if n := (Script{}).AddOp(OpCheckMultiSig).AddOp(OpCheckSig).sigOps(); n != MaxMultiSigKeys+1 {
+		t.Errorf("sigOps() of a multisig without a key count = %d, want %d", n, MaxMultiSigKeys+1)
+	}
+}
@@ -0,0 +1,110 @@
+package blockchain
+
+import (
+	"encoding/hex"
+	"encoding/json"
+)
+
+// MarshalJSON encodes a block as JSON with its hashes hex encoded
+func (b *Block) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Hash         string         `json:"hash"`
+		PreviousHash string         `json:"previousHash"`
+		Counter      int            `json:"counter"`
+		Timestamp    int64          `json:"timestamp"`
+		Difficulty   int            `json:"difficulty"`
+		Transactions []*Transaction `json:"transactions"`
+	}{
+		hex.EncodeToString(b.Hash),
+		hex.EncodeToString(b.PreviousHash),
+		b.Counter,
+		b.Timestamp,
+		b.Difficulty,
+		b.Transactions,
+	})
+}
+
+// MarshalJSON encodes a transaction as JSON with its ID hex encoded
+func (t *Transaction) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		ID       string     `json:"id"`
+		Coinbase bool       `json:"coinbase"`
+		Inputs   []TxInput  `json:"inputs"`
+		Outputs  []TxOutput `json:"outputs"`
+		LockTime uint32     `json:"lockTime"`
+	}{
+		hex.EncodeToString(t.ID),
+		t.IsCoinbase(),
+		t.Inputs,
+		t.Outputs,
+		t.LockTime,
+	})
+}
+
+// MarshalJSON encodes a transaction input as JSON with the ID of the transaction it spends hex encoded
+func (i TxInput) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		ID        string `json:"id"`
+		Out       int    `json:"out"`
+		Sig       string `json:"sig"`
+		Sequence  uint32 `json:"sequence"`
+		ScriptSig string `json:"scriptSig,omitempty"`
+	}{
+		hex.EncodeToString(i.ID),
+		i.Out,
+		i.Sig,
+		i.Sequence,
+		i.ScriptSig.String(),
+	})
+}
+
+// MarshalJSON encodes a transaction output as JSON
+func (o TxOutput) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Value        Amount `json:"value"`
+		PubKey       string `json:"pubKey"`
+		ScriptPubKey string `json:"scriptPubKey,omitempty"`
+	}{
+		o.Value,
+		o.PubKey,
+		o.ScriptPubKey.String(),
+	})
+}
+
+// MarshalJSON encodes an address history entry as JSON with its hashes hex encoded
+func (e HistoryEntry) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		TxID      string `json:"txid"`
+		BlockHash string `json:"blockHash"`
+		Height    int    `json:"height"`
+		Received  Amount `json:"received"`
+		Sent      Amount `json:"sent"`
+	}{
+		hex.EncodeToString(e.TxID),
+		hex.EncodeToString(e.BlockHash),
+		e.Height,
+		e.Received,
+		e.Sent,
+	})
+}
+
+// MarshalJSON encodes an unspent output entry as JSON with its transaction ID hex encoded
+func (e UnspentEntry) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		TxID          string `json:"txid"`
+		Index         int    `json:"index"`
+		Value         Amount `json:"value"`
+		Confirmations int    `json:"confirmations"`
+		Coinbase      bool   `json:"coinbase"`
+		Spendable     bool   `json:"spendable"`
+		Pending       bool   `json:"pending"`
+	}{
+		hex.EncodeToString(e.TxID),
+		e.Out,
+		e.Value,
+		e.Confirmations,
+		e.Coinbase,
+		e.Spendable,
+		e.Pending,
+	})
+}
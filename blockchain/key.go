@@ -0,0 +1,87 @@
+package blockchain
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+)
+
+// Keys are ed25519 key pairs, public keys are 32 bytes and signatures 64 bytes
+
+// GenerateKey makes a new random key pair
+func GenerateKey() (ed25519.PublicKey, ed25519.PrivateKey, error) {
+	return ed25519.GenerateKey(rand.Reader)
+}
+
+// The JSON form of a key file, holding the public key and the seed of the private key in hex
+type keyJSON struct {
+	PublicKey  string `json:"publicKey"`
+	PrivateKey string `json:"privateKey"`
+}
+
+// SaveKey writes a private key to a JSON file only the current user can read
+func SaveKey(f string, k ed25519.PrivateKey) error {
+	pk := k.Public().(ed25519.PublicKey)
+
+	data, err := json.MarshalIndent(keyJSON{hex.EncodeToString(pk), hex.EncodeToString(k.Seed())}, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(f, data, 0600)
+}
+
+// LoadKey reads a private key from a JSON file written by SaveKey
+func LoadKey(f string) (ed25519.PrivateKey, error) {
+	data, err := ioutil.ReadFile(f)
+	if err != nil {
+		return nil, err
+	}
+
+	var j keyJSON
+
+	err = json.Unmarshal(data, &j)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", f, err)
+	}
+
+	s, err := hex.DecodeString(j.PrivateKey)
+	if err != nil || len(s) != ed25519.SeedSize {
+		return nil, fmt.Errorf("%s does not hold a valid private key", f)
+	}
+
+	return ed25519.NewKeyFromSeed(s), nil
+}
+
+// SigHash returns the hash signed to spend input in of a transaction. It is the hash of the transaction's encoding
+// with every ScriptSig left empty, as the signatures can't sign themselves, followed by the input's index.
+func (t *Transaction) SigHash(in int) []byte {
+	// Copy the transaction, clearing the script sigs
+	c := t.withoutScriptSigs()
+
+	var b [4]byte
+	binary.BigEndian.PutUint32(b[:], uint32(in))
+
+	h := sha256.Sum256(append(c.Serialise(), b[:]...))
+
+	return h[:]
+}
+
+// Sign signs input in of a transaction with a private key
+func (t *Transaction) Sign(in int, k ed25519.PrivateKey) []byte {
+	return ed25519.Sign(k, t.SigHash(in))
+}
+
+// CheckSignature checks a signature over input in of a transaction was made by the given public key
+func CheckSignature(t *Transaction, in int, pk, sig []byte) bool {
+	if len(pk) != ed25519.PublicKeySize || len(sig) != ed25519.SignatureSize {
+		return false
+	}
+
+	return ed25519.Verify(pk, t.SigHash(in), sig)
+}
@@ -0,0 +1,90 @@
+package blockchain
+
+import (
+	"fmt"
+	"time"
+)
+
+// MaxSequence is the sequence of an input that doesn't want the transaction's lock time to apply
+const MaxSequence uint32 = 0xffffffff
+
+// LockTimeThreshold splits lock times into block heights, below it, and unix times, at or above it
+const LockTimeThreshold uint32 = 500000000
+
+// IsFinal checks whether a transaction can go in a block at the given height, whose previous blocks have the given
+// median time past. A transaction is final if it has no lock time, its lock time has passed, or none of its
+// inputs have a sequence asking for the lock time to apply.
+func (t *Transaction) IsFinal(height int, mtp int64) bool {
+	if t.LockTime == 0 {
+		return true
+	}
+
+	// Heights are compared with the block's height, times with the median time past
+	if t.LockTime < LockTimeThreshold {
+		if int64(t.LockTime) < int64(height) {
+			return true
+		}
+	} else if int64(t.LockTime) < mtp {
+		return true
+	}
+
+	for _, in := range t.Inputs {
+		if in.Sequence != MaxSequence {
+			return false
+		}
+	}
+
+	return true
+}
+
+// SignalsReplacement checks whether a transaction asks to be replaceable by one paying a higher fee while it is
+// pending, by giving an input a sequence below MaxSequence-1
+func (t *Transaction) SignalsReplacement() bool {
+	for _, in := range t.Inputs {
+		if in.Sequence < MaxSequence-1 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// SetLockTime sets the lock time of a transaction, a block height below LockTimeThreshold or a unix time otherwise.
+// Every input's sequence is set so the lock time applies, or doesn't if it is 0, and the ID is set again.
+func (t *Transaction) SetLockTime(lt uint32) {
+	t.LockTime = lt
+
+	for i := range t.Inputs {
+		t.Inputs[i].Sequence = MaxSequence
+
+		if lt != 0 {
+			t.Inputs[i].Sequence = MaxSequence - 1
+		}
+	}
+
+	t.SetID()
+}
+
+// SetReplaceable signals a transaction can be replaced while it is pending by setting every input's sequence to
+// MaxSequence-2, which also makes any lock time apply, and sets the ID again. It has to be done before signing.
+func (t *Transaction) SetReplaceable() {
+	for i := range t.Inputs {
+		t.Inputs[i].Sequence = MaxSequence - 2
+	}
+
+	t.SetID()
+}
+
+// DescribeLockTime writes a lock time as the height or time it is locked until
+func DescribeLockTime(lt uint32) string {
+	if lt < LockTimeThreshold {
+		return fmt.Sprintf("block %d", lt)
+	}
+
+	return time.Unix(int64(lt), 0).UTC().Format(time.RFC3339)
+}
+
+// IsFinal checks whether a transaction could go in the next block on top of the chain
+func (bc *BlockChain) IsFinal(t *Transaction) bool {
+	return t.IsFinal(bc.Height()+1, bc.MedianTimePast())
+}
@@ -0,0 +1,14 @@
+package blockchain
+
+// MaxCoinbaseMaturity is the most confirmations a chain can make coinbase outputs wait for before they are spent
+const MaxCoinbaseMaturity = 10000
+
+// DefaultCoinbaseMaturity is the confirmations coinbase outputs wait for on chains made without a genesis file,
+// long enough that a reorganisation is unlikely to undo a reward after it has been spent. Regtest chains don't wait.
+const DefaultCoinbaseMaturity = 100
+
+// Mature checks whether an unspent output can be spent in a block at height h, when coinbase outputs need m
+// confirmations. Outputs of other transactions can always be spent.
+func (u *UnspentOutput) Mature(h, m int) bool {
+	return u.Coinbase == false || h-u.Height >= m
+}
@@ -0,0 +1,63 @@
+package blockchain
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestDefaultMaturity(t *testing.T) {
+	if m := DefaultGenesis("alice").Maturity; m != DefaultCoinbaseMaturity {
+		t.Errorf("DefaultGenesis() maturity = %d, want %d", m, DefaultCoinbaseMaturity)
+	}
+
+	if m := RegtestGenesis("alice", false).Maturity; m != 0 {
+		t.Errorf("RegtestGenesis() maturity = %d, want 0", m)
+	}
+}
+
+func TestImmatureSpend(t *testing.T) {
+	// Coinbase outputs need two confirmations, so alice's allocation can be spent from height 2
+	g := RegtestGenesis("alice", true)
+	g.Maturity = 2
+
+	bc, done := newTestChainFrom(t, g)
+	defer done()
+
+	gt, _, err := bc.FindTransaction(bc.FindUnspentOutputs("alice")[0].TxID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// At height 0 the allocation is immature, however few confirmations are asked for
+	for _, mc := range []int{0, 1, 5} {
+		if b := bc.GetBalances("alice", mc); b != (Balance{0, 0, Reward}) {
+			t.Errorf("GetBalances(alice, %d) = %+v, want all %d immature", mc, b, Reward)
+		}
+	}
+
+	if es := bc.ListUnspent("alice", 0); len(es) != 1 || es[0].Spendable || es[0].Coinbase == false {
+		t.Errorf("ListUnspent() = %+v, want one coinbase output that can't be spent", es)
+	}
+
+	// Spending it in the next block has only one confirmation
+	if err := mineTx(bc, spendOutput(gt, 0, 0, "bob", MaxSequence)); errors.Is(err, ErrInvalidTx) == false {
+		t.Fatalf("mining an immature spend = %v, want %v", err, ErrInvalidTx)
+	}
+
+	if err := mineTx(bc); err != nil {
+		t.Fatal(err)
+	}
+
+	// Now it can be spent, while the new block's reward has to wait its turn
+	if b := bc.GetBalances("alice", 1); b != (Balance{Reward, 0, 0}) {
+		t.Errorf("GetBalances(alice, 1) = %+v, want all %d confirmed", b, Reward)
+	}
+
+	if b := bc.GetBalances("miner", 1); b.Immature != Reward {
+		t.Errorf("GetBalances(miner, 1) = %+v, want %d immature", b, Reward)
+	}
+
+	if err := mineTx(bc, spendOutput(gt, 0, 0, "bob", MaxSequence)); err != nil {
+		t.Errorf("mining a mature spend = %v", err)
+	}
+}
@@ -0,0 +1,572 @@
+package blockchain
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/bits"
+	"sort"
+
+	"github.com/dgraph-io/badger"
+)
+
+// The prefix of the keys pending transactions are stored under, followed by the transaction's ID. Each value is
+// the order the transaction arrived in followed by its encoding.
+const mempoolPrefix = "mp:"
+
+// Errors returned when adding, finding and replacing pending transactions
+var (
+	ErrNotPending     = errors.New("transaction is not pending")
+	ErrNotReplaceable = errors.New("pending transaction can't be replaced")
+	ErrReplacementFee = errors.New("replacement doesn't pay enough")
+)
+
+// Returns the key a pending transaction is stored under
+func mempoolKey(id []byte) []byte {
+	return append([]byte(mempoolPrefix), id...)
+}
+
+// Reads the pending transactions in the order they arrived, along with the next arrival number. A transaction
+// can only spend outputs of transactions that arrived before it, so they can be mined in this order.
+func readMempool(txn *badger.Txn) ([]*Transaction, uint64, error) {
+	type pending struct {
+		n  uint64
+		tx *Transaction
+	}
+
+	var ps []pending
+	var next uint64
+
+	it := txn.NewIterator(badger.DefaultIteratorOptions)
+	defer it.Close()
+
+	p := []byte(mempoolPrefix)
+
+	for it.Seek(p); it.ValidForPrefix(p); it.Next() {
+		v, err := it.Item().ValueCopy(nil)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		if len(v) < 8 {
+			return nil, 0, fmt.Errorf("pending transaction %x is cut short", it.Item().Key()[len(p):])
+		}
+
+		t, err := DeserialiseTransaction(v[8:])
+		if err != nil {
+			return nil, 0, err
+		}
+
+		n := binary.BigEndian.Uint64(v)
+		ps = append(ps, pending{n, t})
+
+		if n >= next {
+			next = n + 1
+		}
+	}
+
+	sort.Slice(ps, func(i, j int) bool { return ps[i].n < ps[j].n })
+
+	ts := make([]*Transaction, len(ps))
+
+	for i, p := range ps {
+		ts[i] = p.tx
+	}
+
+	return ts, next, nil
+}
+
+// Mempool returns the pending transactions in the order they arrived, which is the order they are mined in once
+// they are final
+func (bc *BlockChain) Mempool() []*Transaction {
+	var ts []*Transaction
+
+	err := bc.Database.View(func(txn *badger.Txn) error {
+		var err error
+		ts, _, err = readMempool(txn)
+
+		return err
+	})
+	HandleError(err)
+
+	return ts
+}
+
+// PendingTransaction returns the pending transaction with the given ID
+func (bc *BlockChain) PendingTransaction(id []byte) (*Transaction, error) {
+	for _, t := range bc.Mempool() {
+		if bytes.Equal(t.ID, id) {
+			return t, nil
+		}
+	}
+
+	return nil, fmt.Errorf("%w: %x", ErrNotPending, id)
+}
+
+// Returns the outputs spent by some transactions, as hex IDs and output numbers, mapped to the ID spending them
+func spentOutputs(ts []*Transaction) map[string][]byte {
+	s := make(map[string][]byte)
+
+	for _, t := range ts {
+		for _, in := range t.Inputs {
+			s[fmt.Sprintf("%x:%d", in.ID, in.Out)] = t.ID
+		}
+	}
+
+	return s
+}
+
+// Returns the transactions with the given IDs along with every transaction spending their outputs, and those
+// spending theirs, from some transactions in arrival order
+func withDescendants(ts []*Transaction, ids map[string]bool) map[string]bool {
+	d := make(map[string]bool)
+
+	for k := range ids {
+		d[k] = true
+	}
+
+	// Children always arrive after their parents so one pass finds them all
+	for _, t := range ts {
+		for _, in := range t.Inputs {
+			if d[hex.EncodeToString(in.ID)] {
+				d[hex.EncodeToString(t.ID)] = true
+			}
+		}
+	}
+
+	return d
+}
+
+// Works out the fee of a transaction, what its inputs are worth beyond its outputs, looking for the outputs it
+// spends in the chain and in the transactions given
+func (bc *BlockChain) txFee(txn *badger.Txn, t *Transaction, bt []*Transaction) (Amount, error) {
+	var in Amount
+
+	for _, i := range t.Inputs {
+		pt, _, err := bc.findInputTransaction(txn, bt, i.ID)
+		if err != nil {
+			return 0, err
+		}
+
+		if i.Out < 0 || i.Out >= len(pt.Outputs) {
+			return 0, invalid(ErrInvalidTx, "transaction %x spends output %d of %x which doesn't exist", t.ID, i.Out, i.ID)
+		}
+
+		in, err = in.Add(pt.Outputs[i.Out].Value)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	out, err := outputTotal(t)
+	if err != nil {
+		return 0, err
+	}
+
+	return in.Sub(out)
+}
+
+// PendingFee returns the fee of a pending transaction
+func (bc *BlockChain) PendingFee(t *Transaction) (Amount, error) {
+	var f Amount
+
+	err := bc.Database.View(func(txn *badger.Txn) error {
+		ts, _, err := readMempool(txn)
+		if err != nil {
+			return err
+		}
+
+		f, err = bc.txFee(txn, t, ts)
+
+		return err
+	})
+
+	return f, err
+}
+
+// Checks whether fee a over size as is a higher fee rate than fee b over size bs. The sides are cross multiplied
+// into 128 bits so nothing is lost to rounding or overflow.
+func feeRateAbove(a Amount, as int, b Amount, bs int) bool {
+	ah, al := bits.Mul64(uint64(a), uint64(bs))
+	bh, bl := bits.Mul64(uint64(b), uint64(as))
+
+	return ah > bh || (ah == bh && al > bl)
+}
+
+// Returns the pending transactions that can go in a block at the given height and median time past, in the order
+// they arrived. Those that aren't final yet wait for a later block, as do those that are one of the given
+// transactions or spend an output one of them spends, and everything spending their outputs.
+func readyTransactions(ts, gt []*Transaction, h int, mtp int64) []*Transaction {
+	gs := spentOutputs(gt)
+	held := make(map[string]bool)
+
+	for _, t := range gt {
+		held[hex.EncodeToString(t.ID)] = true
+	}
+
+	for _, t := range ts {
+		if t.IsFinal(h, mtp) == false {
+			held[hex.EncodeToString(t.ID)] = true
+		}
+
+		for _, in := range t.Inputs {
+			if _, ok := gs[fmt.Sprintf("%x:%d", in.ID, in.Out)]; ok {
+				held[hex.EncodeToString(t.ID)] = true
+			}
+		}
+	}
+
+	held = withDescendants(ts, held)
+
+	var rt []*Transaction
+
+	for _, t := range ts {
+		if held[hex.EncodeToString(t.ID)] == false {
+			rt = append(rt, t)
+		}
+	}
+
+	return rt
+}
+
+// AcceptTransaction adds a transaction to the pending transactions to be mined later. It has to be valid on top of
+// the chain and the pending transactions before it, and a transaction locked past the next block is held until it
+// is final. A transaction spending an output
+// a pending one already spends replaces it, along with everything spending its outputs, but only if each one it
+// conflicts with signals replacement, and it pays a higher fee than everything it replaces put together and a
+// higher fee rate than each one it conflicts with.
+func (bc *BlockChain) AcceptTransaction(t *Transaction) error {
+	return bc.Database.Update(func(txn *badger.Txn) error {
+		return bc.acceptTransaction(txn, t)
+	})
+}
+
+// Adds a transaction to the pending transactions within a database transaction
+func (bc *BlockChain) acceptTransaction(txn *badger.Txn, t *Transaction) error {
+	tID := hex.EncodeToString(t.ID)
+
+	if t.IsCoinbase() {
+		return invalid(ErrInvalidTx, "coinbase %s can only be mined", tID)
+	}
+
+	ts, next, err := readMempool(txn)
+	if err != nil {
+		return err
+	}
+
+	// The transaction can't already be pending or in the chain
+	for _, p := range ts {
+		if bytes.Equal(p.ID, t.ID) {
+			return invalid(ErrInvalidTx, "transaction %s is already pending", tID)
+		}
+	}
+
+	if _, _, _, err := bc.findTransaction(txn, t.ID); err != ErrTxNotFound {
+		if err != nil {
+			return err
+		}
+
+		return invalid(ErrInvalidTx, "transaction %s is already in the chain", tID)
+	}
+
+	// It is checked as if it went in the next block, though it waits for a later one if it is locked until then
+	h, err := getHeight(txn, bc.LatestHash)
+	if err != nil {
+		return err
+	}
+
+	// Find the pending transactions spending the same outputs, which it would replace along with their descendants
+	ps := spentOutputs(ts)
+	cs := make(map[string]bool)
+
+	for _, in := range t.Inputs {
+		if id, ok := ps[fmt.Sprintf("%x:%d", in.ID, in.Out)]; ok {
+			cs[hex.EncodeToString(id)] = true
+		}
+	}
+
+	ev := withDescendants(ts, cs)
+
+	// Check it against the chain and the pending transactions that would be left
+	var rest []*Transaction
+
+	for _, p := range ts {
+		if ev[hex.EncodeToString(p.ID)] == false {
+			rest = append(rest, p)
+		}
+	}
+
+	spent := make(map[string]bool)
+
+	for k := range spentOutputs(rest) {
+		spent[k] = true
+	}
+
+	err = bc.validateTransaction(txn, t, rest, h+1, spent)
+	if err != nil {
+		return err
+	}
+
+	// A replacement has to be allowed by the transactions it conflicts with and pay its way past them
+	if len(cs) != 0 {
+		fee, err := bc.txFee(txn, t, rest)
+		if err != nil {
+			return err
+		}
+
+		size := len(t.Serialise())
+		var ef Amount
+
+		for _, p := range ts {
+			pID := hex.EncodeToString(p.ID)
+
+			if ev[pID] == false {
+				continue
+			}
+
+			pf, err := bc.txFee(txn, p, ts)
+			if err != nil {
+				return err
+			}
+
+			if cs[pID] && p.SignalsReplacement() == false {
+				return fmt.Errorf("%w: %s conflicts with %s, which doesn't signal replacement", ErrNotReplaceable, tID, pID)
+			}
+
+			if cs[pID] && feeRateAbove(fee, size, pf, len(p.Serialise())) == false {
+				return fmt.Errorf("%w: %s pays %d over %d bytes, no higher a rate than %d over %d bytes paid by %s", ErrReplacementFee, tID, fee, size, pf, len(p.Serialise()), pID)
+			}
+
+			ef, err = ef.Add(pf)
+			if err != nil {
+				return err
+			}
+		}
+
+		if fee <= ef {
+			return fmt.Errorf("%w: %s pays %d, no more than the %d paid by the %d transactions it replaces", ErrReplacementFee, tID, fee, ef, len(ev))
+		}
+	}
+
+	// Remove what it replaces, then add it after everything already pending
+	for k := range ev {
+		id, err := hex.DecodeString(k)
+		if err != nil {
+			return err
+		}
+
+		err = txn.Delete(mempoolKey(id))
+		if err != nil {
+			return err
+		}
+	}
+
+	v := make([]byte, 8)
+	binary.BigEndian.PutUint64(v, next)
+
+	return txn.Set(mempoolKey(t.ID), append(v, t.Serialise()...))
+}
+
+// CheckTransaction checks a transaction can be mined in the next block, after the pending transactions mined along
+// with it, as AppendBlock would mine it
+func (bc *BlockChain) CheckTransaction(t *Transaction) error {
+	return bc.Database.View(func(txn *badger.Txn) error {
+		tID := hex.EncodeToString(t.ID)
+
+		if t.IsCoinbase() {
+			return invalid(ErrInvalidTx, "coinbase %s can only be mined", tID)
+		}
+
+		if bt, _ := PackTransactions([]*Transaction{t}, bc.LatestHash); len(bt) == 0 {
+			return invalid(ErrInvalidTx, "transaction %s is too large for a block", tID)
+		}
+
+		if _, _, _, err := bc.findTransaction(txn, t.ID); err != ErrTxNotFound {
+			if err != nil {
+				return err
+			}
+
+			return invalid(ErrInvalidTx, "transaction %s is already in the chain", tID)
+		}
+
+		// It has to be final in the next block
+		h, err := getHeight(txn, bc.LatestHash)
+		if err != nil {
+			return err
+		}
+
+		m, err := medianTimePast(txn, bc.LatestHash)
+		if err != nil {
+			return err
+		}
+
+		if t.IsFinal(h+1, m) == false {
+			return invalid(ErrInvalidTx, "transaction %s is locked until %s", tID, DescribeLockTime(t.LockTime))
+		}
+
+		// Check it against the chain and the pending transactions that would be mined before it
+		ts, _, err := readMempool(txn)
+		if err != nil {
+			return err
+		}
+
+		rt := readyTransactions(ts, []*Transaction{t}, h+1, m)
+		spent := make(map[string]bool)
+
+		for k := range spentOutputs(rt) {
+			spent[k] = true
+		}
+
+		return bc.validateTransaction(txn, t, rt, h+1, spent)
+	})
+}
+
+// Removes the pending transactions a block mines, along with those spending the same outputs as its transactions
+// and everything spending their outputs, as they can never be mined now
+func removeMined(txn *badger.Txn, b *Block) error {
+	ts, _, err := readMempool(txn)
+	if err != nil || len(ts) == 0 {
+		return err
+	}
+
+	mined := make(map[string]bool)
+
+	for _, t := range b.Transactions {
+		mined[hex.EncodeToString(t.ID)] = true
+	}
+
+	bs := spentOutputs(b.Transactions)
+	cs := make(map[string]bool)
+
+	for _, t := range ts {
+		tID := hex.EncodeToString(t.ID)
+
+		if mined[tID] {
+			continue
+		}
+
+		for _, in := range t.Inputs {
+			if _, ok := bs[fmt.Sprintf("%x:%d", in.ID, in.Out)]; ok {
+				cs[tID] = true
+			}
+		}
+	}
+
+	ev := withDescendants(ts, cs)
+
+	for _, t := range ts {
+		tID := hex.EncodeToString(t.ID)
+
+		if mined[tID] || ev[tID] {
+			err := txn.Delete(mempoolKey(t.ID))
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// BumpFee returns a partially signed replacement for a pending transaction paying a higher fee, which is taken from
+// the change it pays back to the address it spends from. It has the same inputs, so it conflicts with the original,
+// and has to be signed as the original was before it can be accepted in its place.
+func (bc *BlockChain) BumpFee(id []byte, fee Amount) (*PSBT, error) {
+	var p *PSBT
+
+	err := bc.Database.View(func(txn *badger.Txn) error {
+		ts, _, err := readMempool(txn)
+		if err != nil {
+			return err
+		}
+
+		var t *Transaction
+
+		for _, pt := range ts {
+			if bytes.Equal(pt.ID, id) {
+				t = pt
+			}
+		}
+
+		if t == nil {
+			return fmt.Errorf("%w: %x", ErrNotPending, id)
+		}
+
+		if t.SignalsReplacement() == false {
+			return fmt.Errorf("%w: %x doesn't signal replacement", ErrNotReplaceable, id)
+		}
+
+		of, err := bc.txFee(txn, t, ts)
+		if err != nil {
+			return err
+		}
+
+		if fee <= of {
+			return fmt.Errorf("%w: the fee has to be more than the %d already paid", ErrReplacementFee, of)
+		}
+
+		dl, err := dustLimit(txn)
+		if err != nil {
+			return err
+		}
+
+		// Copy the transaction without its script sigs, taking the extra fee from the change
+		nt := Transaction{nil, make([]TxInput, len(t.Inputs)), append([]TxOutput{}, t.Outputs...), t.Version, t.LockTime}
+		f := t.Inputs[0].Sig
+		c := -1
+
+		for i, in := range t.Inputs {
+			in.ScriptSig = nil
+			nt.Inputs[i] = in
+		}
+
+		for i, o := range nt.Outputs {
+			if o.PubKey == f {
+				c = i
+			}
+		}
+
+		if c < 0 {
+			return fmt.Errorf("%w: %x pays no change back to %s to take the fee from", ErrReplacementFee, id, f)
+		}
+
+		if ch := nt.Outputs[c].Value; ch < fee-of || ch-(fee-of) < dl {
+			return fmt.Errorf("%w: the change of %d can't pay %d more without going below the dust limit of %d", ErrReplacementFee, ch, fee-of, dl)
+		}
+
+		nt.Outputs[c].Value -= fee - of
+		nt.SetID()
+
+		// Look up the output each input spends, and the script of any script hash output from the original's script
+		// sig, so it can be signed without the chain
+		p = &PSBT{&nt, make([]PSBTInput, len(nt.Inputs))}
+
+		for i, in := range t.Inputs {
+			pt, _, err := bc.findInputTransaction(txn, ts, in.ID)
+			if err != nil {
+				return err
+			}
+
+			po := pt.Outputs[in.Out]
+
+			var rds Script
+
+			if po.ScriptPubKey.isScriptHash() {
+				ops, err := in.ScriptSig.parse()
+				if err != nil || len(ops) == 0 {
+					return fmt.Errorf("%w: input %d of %x has no redeem script", ErrCannotFinalise, i, id)
+				}
+
+				rds = ops[len(ops)-1].data
+			}
+
+			p.Inputs[i] = PSBTInput{po, rds, make(map[string][]byte)}
+		}
+
+		return nil
+	})
+
+	return p, err
+}
@@ -0,0 +1,291 @@
+package blockchain
+
+import (
+	"errors"
+	"testing"
+)
+
+// Makes a transaction spending one output of a transaction as alice, paying what it doesn't leave as a fee to an
+// address. A sequence below MaxSequence-1 signals replacement.
+func spendOutput(t *Transaction, out int, fee Amount, to string, seq uint32) *Transaction {
+	tx := Transaction{nil, []TxInput{{t.ID, out, "alice", seq, nil}}, []TxOutput{{t.Outputs[out].Value - fee, to, nil}}, TxVersion, 0}
+	tx.SetID()
+
+	return &tx
+}
+
+func TestReplaceByFee(t *testing.T) {
+	// Alice's genesis output is the only thing spent, with a fee of 10 out of its 100 for the original
+	tests := []struct {
+		name    string
+		seq     uint32
+		fee     Amount
+		child   bool
+		err     error
+		pending int
+	}{
+		{"higher fee replaces", MaxSequence - 2, 11, false, nil, 1},
+		{"higher fee replaces the descendants too", MaxSequence - 2, 16, true, nil, 1},
+		{"same fee doesn't replace", MaxSequence - 2, 10, false, ErrReplacementFee, 1},
+		{"lower fee doesn't replace", MaxSequence - 2, 9, false, ErrReplacementFee, 1},
+		{"fee no more than the descendants' doesn't replace", MaxSequence - 2, 15, true, ErrReplacementFee, 2},
+		{"final sequence can't be replaced", MaxSequence, 50, false, ErrNotReplaceable, 1},
+		{"lock time sequence can't be replaced", MaxSequence - 1, 50, false, ErrNotReplaceable, 1},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			bc, done := newTestChain(t, "alice", true)
+			defer done()
+
+			g, err := bc.GetBlock(bc.LatestHash)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// Send the original, and a child spending it if asked, which pays a fee of 5
+			o := spendOutput(g.Transactions[0], 0, 10, "alice", tc.seq)
+
+			if err := bc.AcceptTransaction(o); err != nil {
+				t.Fatal(err)
+			}
+
+			if tc.child {
+				if err := bc.AcceptTransaction(spendOutput(o, 0, 5, "carol", MaxSequence)); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			// Then try to replace it with one paying someone else
+			r := spendOutput(g.Transactions[0], 0, tc.fee, "bob", tc.seq)
+
+			if err := bc.AcceptTransaction(r); errors.Is(err, tc.err) == false {
+				t.Fatalf("AcceptTransaction() = %v, want %v", err, tc.err)
+			}
+
+			if n := len(bc.Mempool()); n != tc.pending {
+				t.Fatalf("%d transactions pending, want %d", n, tc.pending)
+			}
+
+			// Whatever is left pending is mined, and the pool is emptied
+			if _, err := bc.Generate("miner", 1); err != nil {
+				t.Fatal(err)
+			}
+
+			if n := len(bc.Mempool()); n != 0 {
+				t.Errorf("%d transactions pending after mining, want 0", n)
+			}
+
+			w := o
+			if tc.err == nil {
+				w = r
+			}
+
+			if _, _, err := bc.FindTransaction(w.ID); err != nil {
+				t.Errorf("FindTransaction(%x) = %v", w.ID, err)
+			}
+		})
+	}
+}
+
+func TestMinedConflictsLeavePool(t *testing.T) {
+	bc, done := newTestChain(t, "alice", true)
+	defer done()
+
+	g, err := bc.GetBlock(bc.LatestHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A pending transaction and its child, then a block spending the same output another way
+	o := spendOutput(g.Transactions[0], 0, 10, "alice", MaxSequence)
+	c := spendOutput(o, 0, 5, "carol", MaxSequence)
+
+	for _, tx := range []*Transaction{o, c} {
+		if err := bc.AcceptTransaction(tx); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := mineTx(bc, spendOutput(g.Transactions[0], 0, 0, "bob", MaxSequence)); err != nil {
+		t.Fatal(err)
+	}
+
+	if n := len(bc.Mempool()); n != 0 {
+		t.Errorf("%d transactions pending after a conflict was mined, want 0", n)
+	}
+}
+
+func TestLockedTransactionsWait(t *testing.T) {
+	bc, done := newTestChain(t, "alice", true)
+	defer done()
+
+	g, err := bc.GetBlock(bc.LatestHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A transaction that can't be mined before height 4, and a child spending it, are both held
+	o := spendOutput(g.Transactions[0], 0, 10, "alice", MaxSequence-1)
+	o.LockTime = 3
+	o.SetID()
+
+	c := spendOutput(o, 0, 5, "carol", MaxSequence)
+
+	for _, tx := range []*Transaction{o, c} {
+		if err := bc.AcceptTransaction(tx); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Blocks before then leave them waiting, whether they are generated or appended
+	if _, err := bc.Generate("miner", 2); err != nil {
+		t.Fatal(err)
+	}
+
+	bc.AppendBlock([]*Transaction{CoinbaseTx("miner", "Appended block")})
+
+	if n := len(bc.Mempool()); n != 2 {
+		t.Fatalf("%d transactions pending at height %d, want 2", n, bc.Height())
+	}
+
+	if _, err := bc.Generate("miner", 1); err != nil {
+		t.Fatal(err)
+	}
+
+	if n := len(bc.Mempool()); n != 0 {
+		t.Errorf("%d transactions pending at height %d, want 0", n, bc.Height())
+	}
+
+	for _, tx := range []*Transaction{o, c} {
+		if _, l, err := bc.FindTransaction(tx.ID); err != nil || l.Height != 4 {
+			t.Errorf("FindTransaction(%x) = %v, %v, want it at height 4", tx.ID, l, err)
+		}
+	}
+}
+
+func TestAppendBlockMinesPending(t *testing.T) {
+	// Chains that can't generate blocks still mine pending transactions, when any block is appended
+	g := RegtestGenesis("alice", true)
+	g.Network = DefaultNetwork
+
+	bc, done := newTestChainFrom(t, g)
+	defer done()
+
+	gb, err := bc.GetBlock(bc.LatestHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := spendOutput(gb.Transactions[0], 0, 10, "bob", MaxSequence)
+
+	if err := bc.AcceptTransaction(p); err != nil {
+		t.Fatal(err)
+	}
+
+	// A transaction spending the pending one's output can be checked and appended, as it's mined after it
+	tx := Transaction{nil, []TxInput{{p.ID, 0, "bob", MaxSequence, nil}}, []TxOutput{{90, "carol", nil}}, TxVersion, 0}
+	tx.SetID()
+
+	if err := bc.CheckTransaction(&tx); err != nil {
+		t.Fatalf("CheckTransaction() = %v", err)
+	}
+
+	bc.AppendBlock([]*Transaction{&tx})
+
+	if n := len(bc.Mempool()); n != 0 {
+		t.Errorf("%d transactions pending after appending a block, want 0", n)
+	}
+
+	if b := bc.GetBalance("carol"); b != 90 {
+		t.Errorf("carol's balance is %d, want 90", b)
+	}
+}
+
+func TestAppendBlockSkipsConflicts(t *testing.T) {
+	bc, done := newTestChain(t, "alice", true)
+	defer done()
+
+	g, err := bc.GetBlock(bc.LatestHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The appended transaction spends the same output as the pending one, so it's mined and the pending one dropped
+	o := spendOutput(g.Transactions[0], 0, 10, "bob", MaxSequence)
+
+	if err := bc.AcceptTransaction(o); err != nil {
+		t.Fatal(err)
+	}
+
+	r := spendOutput(g.Transactions[0], 0, 0, "carol", MaxSequence)
+
+	if err := bc.CheckTransaction(r); err != nil {
+		t.Fatalf("CheckTransaction() = %v", err)
+	}
+
+	bc.AppendBlock([]*Transaction{r})
+
+	if n := len(bc.Mempool()); n != 0 {
+		t.Errorf("%d transactions pending after a conflict was appended, want 0", n)
+	}
+
+	if b := bc.GetBalance("carol"); b != Reward {
+		t.Errorf("carol's balance is %d, want %d", b, Reward)
+	}
+}
+
+func TestCheckTransaction(t *testing.T) {
+	bc, done := newTestChain(t, "alice", true)
+	defer done()
+
+	g, err := bc.GetBlock(bc.LatestHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Alice's output is moved on once, then each case spends where it went
+	m := spendOutput(g.Transactions[0], 0, 10, "alice", MaxSequence)
+
+	if err := mineTx(bc, m); err != nil {
+		t.Fatal(err)
+	}
+
+	locked := spendOutput(m, 0, 0, "bob", MaxSequence-1)
+	locked.LockTime = 5
+	locked.SetID()
+
+	missing := spendOutput(m, 0, 0, "bob", MaxSequence)
+	missing.Inputs[0].ID = make([]byte, 32)
+	missing.SetID()
+
+	over := spendOutput(m, 0, 0, "bob", MaxSequence)
+	over.Outputs[0].Value++
+	over.SetID()
+
+	tests := []struct {
+		name string
+		tx   *Transaction
+		err  error
+	}{
+		{"valid", spendOutput(m, 0, 0, "bob", MaxSequence), nil},
+		{"coinbase", CoinbaseTx("bob", "Not mined"), ErrInvalidTx},
+		{"already in the chain", m, ErrInvalidTx},
+		{"locked past the next block", locked, ErrInvalidTx},
+		{"spends an output that doesn't exist", missing, ErrInvalidTx},
+		{"spends more than it has", over, ErrInvalidTx},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := bc.CheckTransaction(tc.tx); errors.Is(err, tc.err) == false {
+				t.Errorf("CheckTransaction() = %v, want %v", err, tc.err)
+			}
+		})
+	}
+
+	// Nothing checked is added to the chain
+	if bc.Height() != 1 {
+		t.Errorf("checking transactions mined to height %d, want 1", bc.Height())
+	}
+}
@@ -0,0 +1,250 @@
+package blockchain
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/hex"
+	"fmt"
+	"runtime"
+
+	"github.com/dgraph-io/badger"
+)
+
+// The key recording which encoding the blocks in the database use, databases without it use the old gob encoding
+const encodingKey = "opt:encoding"
+
+// Checks the database uses the canonical encoding, exiting if it needs migrating first
+func checkEncoding(db *badger.DB) {
+	// Look for the encoding key
+	err := db.View(func(txn *badger.Txn) error {
+		_, err := txn.Get([]byte(encodingKey))
+
+		return err
+	})
+
+	// Without it the blocks are gob encoded and can't be read
+	if err == badger.ErrKeyNotFound {
+		fmt.Fprintln(Output, "This chain uses the old block encoding, run migrate first...")
+		db.Close()
+		runtime.Goexit()
+	}
+
+	HandleError(err)
+}
+
+// The layout of blocks, transactions, inputs and outputs stored with the old gob encoding. Gob matches fields by
+// name, so these have to keep the old field names and types, values were ints before the Amount type.
+type legacyBlock struct {
+	Hash         []byte
+	Transactions []*legacyTransaction
+	PreviousHash []byte
+	Counter      int
+}
+
+type legacyTransaction struct {
+	ID      []byte
+	Inputs  []legacyInput
+	Outputs []legacyOutput
+}
+
+type legacyInput struct {
+	ID  []byte
+	Out int
+	Sig string
+}
+
+type legacyOutput struct {
+	Value  int
+	PubKey string
+}
+
+// Decodes a block stored with the old gob encoding
+func deserialiseGob(d []byte) (*legacyBlock, error) {
+	// Create a storage variable for the block
+	var b legacyBlock
+
+	// Create a new decoder with the given data and decode it
+	dc := gob.NewDecoder(bytes.NewReader(d))
+
+	err := dc.Decode(&b)
+	if err != nil {
+		return nil, fmt.Errorf("decoding old block: %w", err)
+	}
+
+	return &b, nil
+}
+
+// Converts the outputs of an old transaction, which can't be negative as the old encoding didn't stop them
+func (t *legacyTransaction) outputs() ([]TxOutput, error) {
+	var outs []TxOutput
+
+	for i, o := range t.Outputs {
+		if o.Value < 0 {
+			return nil, fmt.Errorf("output %d of transaction %x has a negative value of %d", i, t.ID, o.Value)
+		}
+
+		outs = append(outs, TxOutput{Amount(o.Value), o.PubKey, nil})
+	}
+
+	return outs, nil
+}
+
+// MigrateBlockChain re-encodes a gob encoded database with the canonical encoding.
+// Transaction IDs are hashes of the encoding so they all change, and so every block is proved again on top of its
+// migrated previous block. It returns the number of blocks migrated and a map of old to new hex transaction IDs.
+func MigrateBlockChain() (int, map[string]string) {
+	// Check if the database hasn't been made...
+	if checkDB() == false {
+		fmt.Fprintln(Output, "No existing blockchain found, one needs to be made...")
+		runtime.Goexit()
+	}
+
+	// Create an instance of the database options and set the path to the data directory
+	o := badger.DefaultOptions("")
+	o.Dir = DataDir
+	o.ValueDir = DataDir
+
+	// Open the connection to the database with the options, handling any errors
+	db, err := badger.Open(o)
+	HandleError(err)
+
+	defer db.Close()
+
+	// Storage variables for the old blocks, from the latest back to the initial block
+	var old []*legacyBlock
+	migrated := false
+
+	// Read the old chain, unless the database has already been migrated
+	err = db.View(func(txn *badger.Txn) error {
+		if _, err := txn.Get([]byte(encodingKey)); err == nil {
+			migrated = true
+			return nil
+		}
+
+		h, err := getValue(txn, []byte("lh"))
+		if err != nil {
+			return err
+		}
+
+		for len(h) != 0 {
+			rb, err := getValue(txn, h)
+			if err != nil {
+				return err
+			}
+
+			b, err := deserialiseGob(rb)
+			if err != nil {
+				return fmt.Errorf("block %x: %w", h, err)
+			}
+
+			old = append(old, b)
+			h = b.PreviousHash
+		}
+
+		return nil
+	})
+	HandleError(err)
+
+	if migrated {
+		fmt.Fprintln(Output, "Blockchain already uses the canonical encoding...")
+		return 0, map[string]string{}
+	}
+
+	// Map of old to new transaction IDs, needed to point inputs at the migrated transactions
+	ids := make(map[string]string)
+
+	// Storage variables for the migrated blocks and the hash of the last one
+	var nbs []*Block
+	var ph []byte
+
+	// Old blocks have no timestamps, so they are given ones a second apart ending now. Each then comes after the
+	// median time past of the blocks before it, and none are ahead of the clock, so the migrated chain validates.
+	ts := Clock().Unix() - int64(len(old)) + 1
+
+	// Rebuild the chain from the initial block upwards
+	for i := len(old) - 1; i >= 0; i-- {
+		var txs []*Transaction
+
+		for _, t := range old[i].Transactions {
+			// Copy the inputs, pointing each at the migrated transaction it spends. Old inputs had no sequence, and
+			// old transactions no lock time, so they are all final.
+			var ins []TxInput
+
+			for _, in := range t.Inputs {
+				id := in.ID
+
+				if nid, ok := ids[hex.EncodeToString(in.ID)]; ok {
+					id, err = hex.DecodeString(nid)
+					HandleError(err)
+				}
+
+				ins = append(ins, TxInput{id, in.Out, in.Sig, MaxSequence, nil})
+			}
+
+			outs, err := t.outputs()
+			HandleError(err)
+
+			// Create the migrated transaction and record its new ID
+			nt := &Transaction{nil, ins, outs, TxVersion, 0}
+			nt.SetID()
+
+			ids[hex.EncodeToString(t.ID)] = hex.EncodeToString(nt.ID)
+			txs = append(txs, nt)
+		}
+
+		// Prove the migrated block on top of the previous migrated block
+		nb := CreateBlockAt(txs, ph, ts, Difficulty)
+		nbs = append(nbs, nb)
+		ph = nb.Hash
+		ts++
+	}
+
+	// Store the migrated blocks. Nothing points at them until the latest hash is moved below,
+	// so if the process is stopped before then the migration can simply be run again.
+	for _, nb := range nbs {
+		err = db.Update(func(txn *badger.Txn) error {
+			return txn.Set(nb.Hash, nb.Serialise())
+		})
+		HandleError(err)
+	}
+
+	// Move the latest hash to the migrated chain and record the new encoding and genesis hash in one transaction
+	err = db.Update(func(txn *badger.Txn) error {
+		err := txn.Set([]byte("lh"), ph)
+		if err != nil {
+			return err
+		}
+
+		// Old chains had no coinbase maturity and may already spend young rewards, so they keep having none
+		g := DefaultGenesis("")
+		g.Maturity = 0
+
+		err = writeGenesisOptions(txn, nbs[0].Hash, g.params())
+		if err != nil {
+			return err
+		}
+
+		return txn.Set([]byte(encodingKey), []byte{BlockVersion})
+	})
+	HandleError(err)
+
+	// Remove the old blocks and the indexes that point at them
+	for _, b := range old {
+		err = db.Update(func(txn *badger.Txn) error {
+			return txn.Delete(b.Hash)
+		})
+		HandleError(err)
+	}
+
+	// Pending transactions spend outputs by their old IDs so they go too
+	for _, p := range []string{txIndexPrefix, heightIndexPrefix, hashIndexPrefix, spentIndexPrefix, addressIndexPrefix, mempoolPrefix} {
+		err = db.DropPrefix([]byte(p))
+		HandleError(err)
+	}
+
+	// Rebuild the indexes for the migrated chain
+	bc := BlockChain{ph, db, loadTxIndexOption(db)}
+	bc.Reindex()
+
+	return len(nbs), ids
+}
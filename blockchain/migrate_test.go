@@ -0,0 +1,140 @@
+package blockchain
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/gob"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/dgraph-io/badger"
+)
+
+// Loads a database written by the code before the canonical encoding into a temporary data directory. The fixture
+// holds each key and value in hex, it was made with createblockchain -address alice, then sending 30 from alice to
+// bob, 10 from bob to carol and 5 from alice to carol.
+func loadBaselineChain(t *testing.T) func() {
+	t.Helper()
+
+	f, err := os.Open("testdata/baseline_chain.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer f.Close()
+
+	dir, err := ioutil.TempDir("", "blocks")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	od, oo := DataDir, Output
+	DataDir, Output = dir, ioutil.Discard
+
+	db, err := badger.Open(badger.DefaultOptions(dir).WithLogger(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := bufio.NewScanner(f)
+	s.Buffer(nil, 1<<20)
+
+	for s.Scan() {
+		kv := strings.Fields(s.Text())
+
+		k, kerr := hex.DecodeString(kv[0])
+		v, verr := hex.DecodeString(kv[1])
+
+		if kerr != nil || verr != nil {
+			t.Fatalf("bad fixture line %q", s.Text())
+		}
+
+		err = db.Update(func(txn *badger.Txn) error { return txn.Set(k, v) })
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	db.Close()
+
+	return func() {
+		os.RemoveAll(dir)
+		DataDir, Output = od, oo
+	}
+}
+
+func TestMigrateBaselineChain(t *testing.T) {
+	done := loadBaselineChain(t)
+	defer done()
+
+	n, ids := MigrateBlockChain()
+	if n != 4 || len(ids) != 4 {
+		t.Fatalf("MigrateBlockChain() migrated %d blocks and %d transactions, want 4 and 4", n, len(ids))
+	}
+
+	// Migrating again leaves the chain alone
+	if n, _ := MigrateBlockChain(); n != 0 {
+		t.Errorf("migrating twice migrated %d blocks, want 0", n)
+	}
+
+	bc := ContinueBlockChain("")
+	defer bc.Database.Close()
+
+	// Every transaction moved to its new ID, and the balances are the same as before
+	for o, nid := range ids {
+		id, _ := hex.DecodeString(nid)
+
+		if _, _, err := bc.FindTransaction(id); err != nil {
+			t.Errorf("transaction %s migrated to %s, FindTransaction() = %v", o, nid, err)
+		}
+	}
+
+	for a, w := range map[string]Amount{"alice": 65, "bob": 20, "carol": 15} {
+		if b, err := bc.GetBalances(a, 1).Total(); err != nil || b != w {
+			t.Errorf("balance of %s = %d, %v, want %d", a, b, err, w)
+		}
+	}
+
+	// The migrated chain validates, so it can be built on
+	if err := mineTx(bc, NewTransaction("bob", []Recipient{{Address: "alice", Amount: 20}}, LargestFirst{}, bc)); err != nil {
+		t.Errorf("mining on the migrated chain = %v", err)
+	}
+}
+
+func TestMigrateNegativeValue(t *testing.T) {
+	// The old encoding let values go negative, they can't be turned into amounts
+	tests := []struct {
+		name  string
+		value int
+		ok    bool
+	}{
+		{"positive", 5, true},
+		{"zero", 0, true},
+		{"negative", -5, false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var buf bytes.Buffer
+
+			lb := legacyBlock{[]byte{1}, []*legacyTransaction{{[]byte{2}, nil, []legacyOutput{{tc.value, "alice"}}}}, nil, 0}
+
+			if err := gob.NewEncoder(&buf).Encode(lb); err != nil {
+				t.Fatal(err)
+			}
+
+			b, err := deserialiseGob(buf.Bytes())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			outs, err := b.Transactions[0].outputs()
+			if (err == nil) != tc.ok || (tc.ok && outs[0].Value != Amount(tc.value)) {
+				t.Errorf("outputs() = %v, %v", outs, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package blockchain
+
+import (
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+)
+
+// ErrNotMultiSig is returned when a script isn't a valid multisig script
+var ErrNotMultiSig = errors.New("not a multisig script")
+
+// NewMultiSigScript returns the script needing m signatures from the given public keys, which is paid through its
+// script hash address. The keys must be distinct and the script must fit in a single push so it can be spent.
+func NewMultiSigScript(m int, pks [][]byte) (Script, error) {
+	if len(pks) == 0 || m < 1 || m > len(pks) {
+		return nil, fmt.Errorf("%w: %d of %d signatures", ErrNotMultiSig, m, len(pks))
+	}
+
+	seen := make(map[string]bool)
+
+	for _, pk := range pks {
+		if len(pk) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("%w: public key %x is not %d bytes", ErrNotMultiSig, pk, ed25519.PublicKeySize)
+		}
+
+		if seen[string(pk)] {
+			return nil, fmt.Errorf("%w: public key %x is given twice", ErrNotMultiSig, pk)
+		}
+
+		seen[string(pk)] = true
+	}
+
+	s := MultiSigScript(m, pks)
+
+	if len(pks) > MaxMultiSigKeys || len(s) > MaxScriptElementSize {
+		return nil, fmt.Errorf("%w: %d keys are too many to spend", ErrNotMultiSig, len(pks))
+	}
+
+	return s, nil
+}
+
+// ParseMultiSigScript reads the number of signatures needed and the public keys out of a multisig script
+func ParseMultiSigScript(s Script) (int, [][]byte, error) {
+	ops, err := s.parse()
+	if err != nil || len(ops) < 4 || ops[len(ops)-1].op != OpCheckMultiSig {
+		return 0, nil, ErrNotMultiSig
+	}
+
+	// The script is m, the keys, n then OP_CHECKMULTISIG
+	m, err := decodeScriptNum(pushedData(ops[0]), 4)
+	if err != nil {
+		return 0, nil, ErrNotMultiSig
+	}
+
+	n, err := decodeScriptNum(pushedData(ops[len(ops)-2]), 4)
+	if err != nil || n != int64(len(ops)-3) || m < 1 || m > n {
+		return 0, nil, ErrNotMultiSig
+	}
+
+	var pks [][]byte
+	for _, o := range ops[1 : len(ops)-2] {
+		if o.op == Op0 || o.op > OpPushData2 {
+			return 0, nil, ErrNotMultiSig
+		}
+
+		pks = append(pks, o.data)
+	}
+
+	return int(m), pks, nil
+}
+
+// Returns the data an opcode pushes, including the numbers pushed by OP_1 to OP_16
+func pushedData(o scriptOp) []byte {
+	if o.op >= Op1 && o.op <= Op16 {
+		return encodeScriptNum(int64(o.op-Op1) + 1)
+	}
+
+	return o.data
+}
@@ -0,0 +1,196 @@
+package blockchain
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"errors"
+	"testing"
+)
+
+// Returns the public keys of some private keys
+func publicKeys(ks ...ed25519.PrivateKey) [][]byte {
+	var pks [][]byte
+
+	for _, k := range ks {
+		pks = append(pks, k.Public().(ed25519.PublicKey))
+	}
+
+	return pks
+}
+
+// Returns n distinct public keys without generating keys
+func fakePublicKeys(n int) [][]byte {
+	var pks [][]byte
+
+	for i := 0; i < n; i++ {
+		pks = append(pks, bytes.Repeat([]byte{byte(i + 1)}, ed25519.PublicKeySize))
+	}
+
+	return pks
+}
+
+func TestNewMultiSigScript(t *testing.T) {
+	pks := fakePublicKeys(3)
+
+	tests := []struct {
+		name string
+		m    int
+		pks  [][]byte
+		err  error
+	}{
+		{"1 of 1", 1, pks[:1], nil},
+		{"2 of 3", 2, pks, nil},
+		{"3 of 3", 3, pks, nil},
+		{"0 of 3", 0, pks, ErrNotMultiSig},
+		{"4 of 3", 4, pks, ErrNotMultiSig},
+		{"no keys", 1, nil, ErrNotMultiSig},
+		{"the same key twice", 2, [][]byte{pks[0], pks[0]}, ErrNotMultiSig},
+		{"a short key", 1, [][]byte{pks[0][1:]}, ErrNotMultiSig},
+		{"as many keys as fit in a push", 1, fakePublicKeys(15), nil},
+		{"too many keys to fit in a push", 1, fakePublicKeys(16), ErrNotMultiSig},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			s, err := NewMultiSigScript(tc.m, tc.pks)
+			if errors.Is(err, tc.err) == false {
+				t.Fatalf("NewMultiSigScript() = %v, want %v", err, tc.err)
+			}
+
+			if err != nil {
+				return
+			}
+
+			// The script reads back as the keys and number of signatures it was made from
+			m, rpks, err := ParseMultiSigScript(s)
+			if err != nil || m != tc.m || len(rpks) != len(tc.pks) {
+				t.Fatalf("ParseMultiSigScript() = %d, %d keys, %v", m, len(rpks), err)
+			}
+
+			for i := range rpks {
+				if bytes.Equal(rpks[i], tc.pks[i]) == false {
+					t.Errorf("key %d = %x, want %x", i, rpks[i], tc.pks[i])
+				}
+			}
+		})
+	}
+}
+
+func TestParseMultiSigScriptRejects(t *testing.T) {
+	pks := fakePublicKeys(2)
+
+	tests := []struct {
+		name string
+		s    Script
+	}{
+		{"key hash script", PubKeyHashScript(make([]byte, 20))},
+		{"no checkmultisig", MultiSigScript(1, pks)[:len(MultiSigScript(1, pks))-1]},
+		{"key count that doesn't match", Script{}.AddInt(1).AddData(pks[0]).AddInt(2).AddOp(OpCheckMultiSig)},
+		{"more signatures than keys", Script{}.AddInt(3).AddData(pks[0]).AddData(pks[1]).AddInt(2).AddOp(OpCheckMultiSig)},
+		{"no signatures", Script{}.AddInt(0).AddData(pks[0]).AddInt(1).AddOp(OpCheckMultiSig)},
+		{"an opcode for a key", Script{}.AddInt(1).AddOp(OpDup).AddInt(1).AddOp(OpCheckMultiSig)},
+		{"malformed", Script{0x05}},
+		{"empty", Script{}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, _, err := ParseMultiSigScript(tc.s); errors.Is(err, ErrNotMultiSig) == false {
+				t.Errorf("ParseMultiSigScript(%s) = %v, want %v", tc.s, err, ErrNotMultiSig)
+			}
+		})
+	}
+}
+
+func TestMultiSigSpends(t *testing.T) {
+	tests := []struct {
+		name  string
+		signs []int
+		err   error
+	}{
+		{"2 of 3 signed by the first two", []int{0, 1}, nil},
+		{"2 of 3 signed by the last two", []int{2, 1}, nil},
+		{"2 of 3 signed by all three", []int{0, 1, 2}, nil},
+		{"2 of 3 signed by one", []int{1}, ErrNotEnoughSigs},
+		{"2 of 3 signed by nobody", nil, ErrNotEnoughSigs},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			bc, done := newTestChain(t, "alice", true)
+			defer done()
+
+			// Pay into a 2 of 3 multisig address
+			ks := []ed25519.PrivateKey{testKey(t), testKey(t), testKey(t)}
+
+			ms, err := NewMultiSigScript(2, publicKeys(ks...))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			a := ScriptHashAddress(ms)
+
+			if err := mineTx(bc, NewTransaction("alice", []Recipient{{Address: a, Amount: 40}}, LargestFirst{}, bc)); err != nil {
+				t.Fatal(err)
+			}
+
+			// Spend it back with the keys asked for
+			p, err := bc.CreatePSBT(a, []Recipient{{Address: "bob", Amount: 40}}, LargestFirst{}, ms)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, i := range tc.signs {
+				if _, err := p.Sign(ks[i]); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			st, err := p.Finalise()
+			if errors.Is(err, tc.err) == false {
+				t.Fatalf("Finalise() = %v, want %v", err, tc.err)
+			}
+
+			if err != nil {
+				return
+			}
+
+			if err := mineTx(bc, st); err != nil {
+				t.Fatalf("mining the spend = %v", err)
+			}
+
+			if b := bc.GetBalance("bob"); b != 40 {
+				t.Errorf("bob's balance is %d, want 40", b)
+			}
+		})
+	}
+}
+
+func TestMultiSigSpendWithoutSignatures(t *testing.T) {
+	bc, done := newTestChain(t, "alice", true)
+	defer done()
+
+	ms, err := NewMultiSigScript(1, fakePublicKeys(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a := ScriptHashAddress(ms)
+
+	if err := mineTx(bc, NewTransaction("alice", []Recipient{{Address: a, Amount: 40}}, LargestFirst{}, bc)); err != nil {
+		t.Fatal(err)
+	}
+
+	// Naming the address isn't enough, nor is giving the script without a signature
+	st := NewTransaction(a, []Recipient{{Address: "bob", Amount: 40}}, LargestFirst{}, bc)
+
+	if err := mineTx(bc, st); errors.Is(err, ErrInvalidTx) == false {
+		t.Errorf("mining a spend without a script sig = %v, want %v", err, ErrInvalidTx)
+	}
+
+	st.Inputs[0].ScriptSig = Script{}.AddData(ms)
+
+	if err := mineTx(bc, st); errors.Is(err, ErrInvalidTx) == false {
+		t.Errorf("mining a spend without signatures = %v, want %v", err, ErrInvalidTx)
+	}
+}
@@ -10,10 +10,13 @@ import (
 	"math/big"
 )
 
-// Difficulty is a constant that controls the difficult of hash generation.
+// Difficulty is the default difficulty of hash generation, used unless a chain's genesis sets its own.
 // The higher the difficulty the more computing power needed per hash
 const Difficulty = 18
 
+// MaxDifficulty is the highest difficulty a block can be proved at
+const MaxDifficulty = 255
+
 // ProofOfWork is a struct to hold a block and a target intiger for the hash
 type ProofOfWork struct {
 	Block  *Block
@@ -25,8 +28,8 @@ func NewProof(b *Block) *ProofOfWork {
 	// Creates a target intiger for the hash
 	t := big.NewInt(1)
 
-	// Use Lsh to retrun t << 256 - Difficulty constant
-	t.Lsh(t, uint(256-Difficulty))
+	// Use Lsh to retrun t << 256 - the blocks difficulty
+	t.Lsh(t, uint(256-b.Difficulty))
 
 	// Create a new ProofOfWork with the block and the target
 	pow := &ProofOfWork{b, t}
@@ -34,20 +37,22 @@ func NewProof(b *Block) *ProofOfWork {
 	return pow
 }
 
-// InitialiseData is a method on the ProofOfWork struct that takes the current counter and returns data
+// InitialiseData is a method on the ProofOfWork struct that takes the current counter and returns data.
+// Blocks from version 2 onwards also commit to their timestamp.
 func (pow *ProofOfWork) InitialiseData(c int) []byte {
 	// Create the data by concatting the elements below into a new byte slice
-	d := bytes.Join(
-		[][]byte{
-			pow.Block.PreviousHash,
-			pow.Block.HashTransactions(),
-			ToHex(int64(c)),
-			ToHex(int64(Difficulty)),
-		},
-		[]byte{},
-	)
-
-	return d
+	parts := [][]byte{
+		pow.Block.PreviousHash,
+		pow.Block.HashTransactions(),
+		ToHex(int64(c)),
+		ToHex(int64(pow.Block.Difficulty)),
+	}
+
+	if pow.Block.Version >= 2 {
+		parts = append(parts, ToHex(pow.Block.Timestamp))
+	}
+
+	return bytes.Join(parts, []byte{})
 }
 
 // ToHex takes an int and returns a hex as a slice of bytes
@@ -97,7 +102,7 @@ func (pow *ProofOfWork) Run() (int, []byte) {
 		// Create a sha256 hash
 		h = sha256.Sum256(d)
 
-		fmt.Printf("\r%x", h)
+		fmt.Fprintf(Output, "\r%x", h)
 
 		// Set the bytes of the hash to the initial hash
 		ih.SetBytes(h[:])
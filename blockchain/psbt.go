@@ -0,0 +1,397 @@
+package blockchain
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+)
+
+// Errors returned when building, signing and finalising partially signed transactions
+var (
+	ErrCannotSign     = errors.New("key can't sign any of the inputs")
+	ErrNotEnoughSigs  = errors.New("not enough signatures")
+	ErrPSBTMismatch   = errors.New("partially signed transactions are for different transactions")
+	ErrCannotFinalise = errors.New("input can't be finalised")
+)
+
+// The magic bytes and version a partially signed transaction's encoding starts with
+const (
+	psbtMagic   = "GBCP"
+	psbtVersion = 1
+)
+
+// PSBT is a partially signed transaction, an unsigned transaction along with what is needed to sign each of its
+// inputs without the chain. It can be saved to a file and passed between machines, each signing with the keys it
+// holds, until it can be finalised into a transaction ready to send.
+type PSBT struct {
+	Tx     *Transaction
+	Inputs []PSBTInput
+}
+
+// PSBTInput holds the output an input spends, the script that was hashed if the output is locked to a script
+// hash, and the signatures collected for the input so far keyed by their hex public keys
+type PSBTInput struct {
+	PrevOut      TxOutput
+	RedeemScript Script
+	Signatures   map[string][]byte
+}
+
+// CreatePSBT makes a partially signed transaction paying the recipients from an address, as NewTransaction does.
+// Spending from a script hash address needs the script that was hashed.
+func (bc *BlockChain) CreatePSBT(f string, rs []Recipient, cs CoinSelector, rds Script) (*PSBT, error) {
+	if IsScriptHashAddress(f) && ScriptHashAddress(rds) != f {
+		return nil, fmt.Errorf("the redeem script is not the script of %s", f)
+	}
+
+	if IsScriptHashAddress(f) == false {
+		rds = nil
+	}
+
+	tx := NewTransaction(f, rs, cs, bc)
+	p := &PSBT{tx, make([]PSBTInput, len(tx.Inputs))}
+
+	// Look up the output each input spends so it can be signed without the chain
+	for i, in := range tx.Inputs {
+		pt, _, err := bc.FindTransaction(in.ID)
+		if err != nil {
+			return nil, err
+		}
+
+		p.Inputs[i] = PSBTInput{pt.Outputs[in.Out], rds, make(map[string][]byte)}
+	}
+
+	return p, nil
+}
+
+// Returns the script whose keys sign an input, the redeem script of a script hash output or the output's own script
+func (in *PSBTInput) signingScript() Script {
+	if in.PrevOut.ScriptPubKey.isScriptHash() {
+		return in.RedeemScript
+	}
+
+	return in.PrevOut.ScriptPubKey
+}
+
+// Checks whether a public key is one of those an input can be signed with
+func (in *PSBTInput) canSign(pk []byte) bool {
+	s := in.signingScript()
+
+	if h := s.pubKeyHash(); h != nil {
+		return bytes.Equal(Hash160(pk), h)
+	}
+
+	_, pks, err := ParseMultiSigScript(s)
+	if err != nil {
+		return false
+	}
+
+	for _, k := range pks {
+		if bytes.Equal(k, pk) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Sign adds a signature with a private key to every input the key can sign, returning how many it signed.
+// It fails with ErrCannotSign if the key can't sign any of them.
+func (p *PSBT) Sign(k ed25519.PrivateKey) (int, error) {
+	pk := k.Public().(ed25519.PublicKey)
+	n := 0
+
+	for i := range p.Inputs {
+		in := &p.Inputs[i]
+
+		if in.canSign(pk) == false {
+			continue
+		}
+
+		if in.Signatures == nil {
+			in.Signatures = make(map[string][]byte)
+		}
+
+		in.Signatures[hex.EncodeToString(pk)] = p.Tx.Sign(i, k)
+		n++
+	}
+
+	if n == 0 {
+		return 0, fmt.Errorf("%w: %x", ErrCannotSign, []byte(pk))
+	}
+
+	return n, nil
+}
+
+// InputSignatures returns how many valid signatures an input has and how many it needs. Inputs that don't need
+// any signatures, or whose scripts aren't known, need 0.
+func (p *PSBT) InputSignatures(i int) (int, int) {
+	in := p.Inputs[i]
+	s := in.signingScript()
+
+	// Count the signatures that are valid for a key that can sign the input
+	n := 0
+
+	for k, sig := range in.Signatures {
+		pk, err := hex.DecodeString(k)
+
+		if err == nil && in.canSign(pk) && CheckSignature(p.Tx, i, pk, sig) {
+			n++
+		}
+	}
+
+	if s.pubKeyHash() != nil {
+		return n, 1
+	}
+
+	if m, _, err := ParseMultiSigScript(s); err == nil {
+		return n, m
+	}
+
+	return n, 0
+}
+
+// Complete checks whether every input has the signatures it needs
+func (p *PSBT) Complete() bool {
+	for i := range p.Inputs {
+		if n, m := p.InputSignatures(i); n < m {
+			return false
+		}
+	}
+
+	return true
+}
+
+// CombinePSBTs merges the signatures of partially signed transactions for the same transaction
+func CombinePSBTs(ps ...*PSBT) (*PSBT, error) {
+	if len(ps) == 0 {
+		return nil, errors.New("no partially signed transactions to combine")
+	}
+
+	// Start from a copy of the first one so none of them are changed
+	c := &PSBT{ps[0].Tx, make([]PSBTInput, len(ps[0].Inputs))}
+
+	for i, in := range ps[0].Inputs {
+		c.Inputs[i] = PSBTInput{in.PrevOut, in.RedeemScript, make(map[string][]byte)}
+	}
+
+	for _, p := range ps {
+		if bytes.Equal(p.Tx.ID, c.Tx.ID) == false {
+			return nil, fmt.Errorf("%w: %x and %x", ErrPSBTMismatch, c.Tx.ID, p.Tx.ID)
+		}
+
+		for i, in := range p.Inputs {
+			// Fill in a redeem script one of them is missing
+			if len(c.Inputs[i].RedeemScript) == 0 {
+				c.Inputs[i].RedeemScript = in.RedeemScript
+			}
+
+			for k, sig := range in.Signatures {
+				c.Inputs[i].Signatures[k] = sig
+			}
+		}
+	}
+
+	return c, nil
+}
+
+// Finalise returns the transaction with every ScriptSig filled in from the signatures collected, checking each
+// input's scripts pass. Inputs spending outputs without a script need nothing, key hash outputs take a signature
+// and the key, and multisig outputs take the signatures in key order, followed by the multisig script if it was
+// paid to its script hash.
+func (p *PSBT) Finalise() (*Transaction, error) {
+	// Copy the transaction so the unsigned one is left as it was
+	t := *p.Tx
+	t.Inputs = append([]TxInput{}, p.Tx.Inputs...)
+
+	for i, in := range p.Inputs {
+		s := in.signingScript()
+
+		if n, m := p.InputSignatures(i); n < m {
+			return nil, fmt.Errorf("%w: input %d has %d of the %d needed", ErrNotEnoughSigs, i, n, m)
+		}
+
+		var ss Script
+
+		switch {
+		case len(in.PrevOut.ScriptPubKey) == 0:
+			continue
+
+		case len(s) == 0:
+			return nil, fmt.Errorf("%w: input %d is missing the redeem script for %s", ErrCannotFinalise, i, in.PrevOut.PubKey)
+
+		case s.pubKeyHash() != nil:
+			for k, sig := range in.Signatures {
+				pk, err := hex.DecodeString(k)
+
+				if err == nil && in.canSign(pk) && CheckSignature(p.Tx, i, pk, sig) {
+					ss = Script{}.AddData(sig).AddData(pk)
+					break
+				}
+			}
+
+		default:
+			m, pks, err := ParseMultiSigScript(s)
+			if err != nil {
+				return nil, fmt.Errorf("%w: input %d is locked with a script that isn't a key hash or multisig", ErrCannotFinalise, i)
+			}
+
+			// Push the first m valid signatures in the same order as their keys
+			ss = Script{}
+
+			for _, pk := range pks {
+				sig := in.Signatures[hex.EncodeToString(pk)]
+
+				if m > 0 && CheckSignature(p.Tx, i, pk, sig) {
+					ss = ss.AddData(sig)
+					m--
+				}
+			}
+		}
+
+		if in.PrevOut.ScriptPubKey.isScriptHash() {
+			ss = ss.AddData(in.RedeemScript)
+		}
+
+		t.Inputs[i].ScriptSig = ss
+	}
+
+	// Make sure every script passes so a transaction that can't be sent is caught now
+	for i, in := range p.Inputs {
+		if len(in.PrevOut.ScriptPubKey) == 0 {
+			continue
+		}
+
+		if err := VerifyScript(t.Inputs[i].ScriptSig, in.PrevOut.ScriptPubKey, &t, i); err != nil {
+			return nil, fmt.Errorf("%w: input %d: %s", ErrCannotFinalise, i, err)
+		}
+	}
+
+	t.SetID()
+
+	return &t, nil
+}
+
+// The encoding of a partially signed transaction is made of the following, using the canonical encoding:
+//
+//	magic "GBCP", version byte, transaction bytes, uint32 input count, each input
+//	input:  previous output value uint64, public key bytes, script public key bytes, redeem script bytes,
+//	        uint32 signature count, each signature as public key bytes then signature bytes, ordered by key
+//
+// Files hold the encoding in base64 so they can be copied around as text.
+
+// Serialise returns the encoding of a partially signed transaction
+func (p *PSBT) Serialise() []byte {
+	var e encoder
+
+	e.buf.WriteString(psbtMagic)
+	e.writeByte(psbtVersion)
+	e.writeBytes(p.Tx.Serialise())
+
+	e.writeUint32(uint32(len(p.Inputs)))
+	for _, in := range p.Inputs {
+		e.writeUint64(uint64(in.PrevOut.Value))
+		e.writeBytes([]byte(in.PrevOut.PubKey))
+		e.writeBytes(in.PrevOut.ScriptPubKey)
+		e.writeBytes(in.RedeemScript)
+
+		// Write the signatures in key order so the same signatures are always encoded the same way
+		var ks []string
+		for k := range in.Signatures {
+			ks = append(ks, k)
+		}
+
+		sort.Strings(ks)
+
+		e.writeUint32(uint32(len(ks)))
+		for _, k := range ks {
+			pk, _ := hex.DecodeString(k)
+
+			e.writeBytes(pk)
+			e.writeBytes(in.Signatures[k])
+		}
+	}
+
+	return e.buf.Bytes()
+}
+
+// DeserialisePSBT takes the encoding of a partially signed transaction and returns it
+func DeserialisePSBT(data []byte) (*PSBT, error) {
+	d := decoder{data: data}
+
+	if string(d.take(len(psbtMagic))) != psbtMagic {
+		return nil, errors.New("not a partially signed transaction")
+	}
+
+	if v := d.readByte(); d.err == nil && v != psbtVersion {
+		return nil, fmt.Errorf("unknown partially signed transaction version %d", v)
+	}
+
+	tx, err := DeserialiseTransaction(d.readBytes())
+	if d.err != nil {
+		return nil, d.err
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	p := &PSBT{Tx: tx}
+
+	n := d.readUint32()
+	for i := uint32(0); i < n && d.err == nil; i++ {
+		in := PSBTInput{TxOutput{Amount(d.readUint64()), string(d.readBytes()), d.readBytes()}, d.readBytes(), make(map[string][]byte)}
+
+		sn := d.readUint32()
+		for j := uint32(0); j < sn && d.err == nil; j++ {
+			k := hex.EncodeToString(d.readBytes())
+			in.Signatures[k] = d.readBytes()
+		}
+
+		p.Inputs = append(p.Inputs, in)
+	}
+
+	if d.err == nil && len(d.data) != 0 {
+		d.err = errors.New("trailing data after partially signed transaction")
+	}
+
+	if d.err != nil {
+		return nil, d.err
+	}
+
+	if len(p.Inputs) != len(tx.Inputs) {
+		return nil, fmt.Errorf("partially signed transaction has %d inputs but its transaction has %d", len(p.Inputs), len(tx.Inputs))
+	}
+
+	return p, nil
+}
+
+// SavePSBT writes a partially signed transaction to a file in base64
+func SavePSBT(f string, p *PSBT) error {
+	return ioutil.WriteFile(f, []byte(base64.StdEncoding.EncodeToString(p.Serialise())+"\n"), 0644)
+}
+
+// LoadPSBT reads a partially signed transaction from a file written by SavePSBT
+func LoadPSBT(f string) (*PSBT, error) {
+	data, err := ioutil.ReadFile(f)
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", f, err)
+	}
+
+	p, err := DeserialisePSBT(b)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", f, err)
+	}
+
+	return p, nil
+}
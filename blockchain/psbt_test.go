@@ -0,0 +1,354 @@
+package blockchain
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/hex"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Pays an amount from alice to an address so it can be spent with a partially signed transaction
+func fundAddress(t *testing.T, bc *BlockChain, a string, v Amount) {
+	t.Helper()
+
+	if err := mineTx(bc, NewTransaction("alice", []Recipient{{Address: a, Amount: v}}, LargestFirst{}, bc)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Returns a copy of a partially signed transaction made through its encoding, as if it was passed to another signer
+func copyPSBT(t *testing.T, p *PSBT) *PSBT {
+	t.Helper()
+
+	c, err := DeserialisePSBT(p.Serialise())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return c
+}
+
+func TestPSBTKeyHash(t *testing.T) {
+	bc, done := newTestChain(t, "alice", true)
+	defer done()
+
+	k := testKey(t)
+	a := KeyHashAddress(k.Public().(ed25519.PublicKey))
+
+	fundAddress(t, bc, a, 40)
+
+	p, err := bc.CreatePSBT(a, []Recipient{{Address: "bob", Amount: 40}}, LargestFirst{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n, m := p.InputSignatures(0); n != 0 || m != 1 || p.Complete() {
+		t.Fatalf("unsigned input has %d of %d signatures, complete %t", n, m, p.Complete())
+	}
+
+	if _, err := p.Finalise(); errors.Is(err, ErrNotEnoughSigs) == false {
+		t.Errorf("Finalise() unsigned = %v, want %v", err, ErrNotEnoughSigs)
+	}
+
+	// A key that isn't the address's can't sign
+	if _, err := p.Sign(testKey(t)); errors.Is(err, ErrCannotSign) == false {
+		t.Errorf("Sign() with another key = %v, want %v", err, ErrCannotSign)
+	}
+
+	// Nor is a signature counted if it was made by another key
+	p.Inputs[0].Signatures[hex.EncodeToString(k.Public().(ed25519.PublicKey))] = p.Tx.Sign(0, testKey(t))
+
+	if n, _ := p.InputSignatures(0); n != 0 {
+		t.Errorf("a forged signature was counted")
+	}
+
+	if n, err := p.Sign(k); n != 1 || err != nil {
+		t.Fatalf("Sign() = %d, %v, want 1", n, err)
+	}
+
+	if n, m := p.InputSignatures(0); n != 1 || m != 1 || p.Complete() == false {
+		t.Fatalf("signed input has %d of %d signatures, complete %t", n, m, p.Complete())
+	}
+
+	st, err := p.Finalise()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Finalising leaves the partially signed transaction unsigned
+	if len(p.Tx.Inputs[0].ScriptSig) != 0 {
+		t.Errorf("Finalise() changed the unsigned transaction")
+	}
+
+	if err := mineTx(bc, st); err != nil {
+		t.Fatalf("mining the spend = %v", err)
+	}
+
+	if b := bc.GetBalance("bob"); b != 40 {
+		t.Errorf("bob's balance is %d, want 40", b)
+	}
+}
+
+func TestPSBTWithoutScripts(t *testing.T) {
+	bc, done := newTestChain(t, "alice", true)
+	defer done()
+
+	// Outputs paid to plain addresses need no signatures, so the transaction is complete from the start
+	p, err := bc.CreatePSBT("alice", []Recipient{{Address: "bob", Amount: 10}}, LargestFirst{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n, m := p.InputSignatures(0); n != 0 || m != 0 || p.Complete() == false {
+		t.Fatalf("input has %d of %d signatures, complete %t", n, m, p.Complete())
+	}
+
+	if _, err := p.Sign(testKey(t)); errors.Is(err, ErrCannotSign) == false {
+		t.Errorf("Sign() = %v, want %v", err, ErrCannotSign)
+	}
+
+	st, err := p.Finalise()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := mineTx(bc, st); err != nil {
+		t.Errorf("mining the spend = %v", err)
+	}
+}
+
+func TestCreatePSBTRedeemScript(t *testing.T) {
+	bc, done := newTestChain(t, "alice", true)
+	defer done()
+
+	ms, _ := NewMultiSigScript(1, fakePublicKeys(2))
+	other, _ := NewMultiSigScript(2, fakePublicKeys(2))
+
+	a := ScriptHashAddress(ms)
+	fundAddress(t, bc, a, 40)
+
+	// The redeem script has to be the one the address was made from
+	if _, err := bc.CreatePSBT(a, []Recipient{{Address: "bob", Amount: 40}}, LargestFirst{}, other); err == nil {
+		t.Errorf("CreatePSBT() with another redeem script didn't fail")
+	}
+
+	if _, err := bc.CreatePSBT(a, []Recipient{{Address: "bob", Amount: 40}}, LargestFirst{}, nil); err == nil {
+		t.Errorf("CreatePSBT() without a redeem script didn't fail")
+	}
+
+	// It's dropped when spending from an address that doesn't need one
+	p, err := bc.CreatePSBT("alice", []Recipient{{Address: "bob", Amount: 10}}, LargestFirst{}, ms)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(p.Inputs[0].RedeemScript) != 0 {
+		t.Errorf("the redeem script was kept for a plain address")
+	}
+}
+
+func TestCombinePSBTs(t *testing.T) {
+	bc, done := newTestChain(t, "alice", true)
+	defer done()
+
+	ks := []ed25519.PrivateKey{testKey(t), testKey(t), testKey(t)}
+
+	ms, err := NewMultiSigScript(2, publicKeys(ks...))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a := ScriptHashAddress(ms)
+	fundAddress(t, bc, a, 40)
+
+	p, err := bc.CreatePSBT(a, []Recipient{{Address: "bob", Amount: 40}}, LargestFirst{}, ms)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Two signers each sign their own copy, one of which lost its redeem script along the way
+	p1, p2 := copyPSBT(t, p), copyPSBT(t, p)
+
+	if _, err := p1.Sign(ks[0]); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := p2.Sign(ks[2]); err != nil {
+		t.Fatal(err)
+	}
+
+	if p1.Complete() || p2.Complete() {
+		t.Fatalf("a single signature completed the transaction")
+	}
+
+	p1.Inputs[0].RedeemScript = nil
+
+	c, err := CombinePSBTs(p1, p2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n, m := c.InputSignatures(0); n != 2 || m != 2 || c.Complete() == false {
+		t.Fatalf("combined input has %d of %d signatures, complete %t", n, m, c.Complete())
+	}
+
+	// Combining doesn't change what was combined
+	if n, _ := p1.InputSignatures(0); n != 0 || len(p1.Inputs[0].Signatures) != 1 || len(p1.Inputs[0].RedeemScript) != 0 {
+		t.Errorf("CombinePSBTs() changed its first argument")
+	}
+
+	st, err := c.Finalise()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := mineTx(bc, st); err != nil {
+		t.Fatalf("mining the spend = %v", err)
+	}
+
+	// Only copies of the same transaction can be combined
+	o, err := bc.CreatePSBT("alice", []Recipient{{Address: "bob", Amount: 10}}, LargestFirst{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := CombinePSBTs(p1, o); errors.Is(err, ErrPSBTMismatch) == false {
+		t.Errorf("CombinePSBTs() of different transactions = %v, want %v", err, ErrPSBTMismatch)
+	}
+
+	if _, err := CombinePSBTs(); err == nil {
+		t.Errorf("CombinePSBTs() of nothing didn't fail")
+	}
+}
+
+func TestFinaliseWithoutRedeemScript(t *testing.T) {
+	bc, done := newTestChain(t, "alice", true)
+	defer done()
+
+	k := testKey(t)
+
+	ms, err := NewMultiSigScript(1, publicKeys(k))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a := ScriptHashAddress(ms)
+	fundAddress(t, bc, a, 40)
+
+	p, err := bc.CreatePSBT(a, []Recipient{{Address: "bob", Amount: 40}}, LargestFirst{}, ms)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Without its redeem script nothing can sign the input, and it can't be finalised
+	p.Inputs[0].RedeemScript = nil
+
+	if _, err := p.Sign(k); errors.Is(err, ErrCannotSign) == false {
+		t.Errorf("Sign() = %v, want %v", err, ErrCannotSign)
+	}
+
+	if _, err := p.Finalise(); errors.Is(err, ErrCannotFinalise) == false {
+		t.Errorf("Finalise() = %v, want %v", err, ErrCannotFinalise)
+	}
+}
+
+func TestPSBTEncoding(t *testing.T) {
+	bc, done := newTestChain(t, "alice", true)
+	defer done()
+
+	k := testKey(t)
+
+	ms, err := NewMultiSigScript(1, publicKeys(k, testKey(t)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a := ScriptHashAddress(ms)
+	fundAddress(t, bc, a, 40)
+
+	p, err := bc.CreatePSBT(a, []Recipient{{Address: "bob", Amount: 40}}, LargestFirst{}, ms)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := p.Sign(k); err != nil {
+		t.Fatal(err)
+	}
+
+	// The encoding reads back to the same partially signed transaction
+	c := copyPSBT(t, p)
+
+	if bytes.Equal(c.Serialise(), p.Serialise()) == false {
+		t.Fatalf("the encoding changed after reading it back")
+	}
+
+	if bytes.Equal(c.Tx.ID, p.Tx.ID) == false || c.Complete() == false || bytes.Equal(c.Inputs[0].RedeemScript, ms) == false {
+		t.Errorf("DeserialisePSBT() = %+v, want %+v", c, p)
+	}
+
+	// So does a file
+	dir, err := ioutil.TempDir("", "psbt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	f := filepath.Join(dir, "tx.psbt")
+
+	if err := SavePSBT(f, p); err != nil {
+		t.Fatal(err)
+	}
+
+	l, err := LoadPSBT(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if bytes.Equal(l.Serialise(), p.Serialise()) == false {
+		t.Errorf("LoadPSBT() read back a different partially signed transaction")
+	}
+
+	if err := ioutil.WriteFile(f, []byte("not base64!"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := LoadPSBT(f); err == nil {
+		t.Errorf("LoadPSBT() of a file that isn't base64 didn't fail")
+	}
+
+	// Broken encodings are rejected
+	enc := p.Serialise()
+
+	version := append([]byte{}, enc...)
+	version[len(psbtMagic)] = psbtVersion + 1
+
+	var e encoder
+	e.buf.Write(enc[:len(psbtMagic)+1])
+	e.writeBytes(p.Tx.Serialise())
+	e.writeUint32(0)
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"empty", nil},
+		{"wrong magic", append([]byte("GBCX"), enc[len(psbtMagic):]...)},
+		{"unknown version", version},
+		{"truncated", enc[:len(enc)-1]},
+		{"trailing data", append(append([]byte{}, enc...), 0)},
+		{"fewer inputs than the transaction", e.buf.Bytes()},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := DeserialisePSBT(tc.data); err == nil {
+				t.Errorf("DeserialisePSBT() didn't fail")
+			}
+		})
+	}
+}
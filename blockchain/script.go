@@ -0,0 +1,403 @@
+package blockchain
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Script is a program in a small stack based language. An output can be locked with a script, its ScriptPubKey,
+// and the input spending it gives a script of its own, its ScriptSig, which pushes the data the locking script
+// needs. The ScriptSig is run first and the ScriptPubKey is then run on the stack it leaves, and the output can
+// be spent if that finishes with a true value on top of the stack.
+//
+// A script is a list of opcodes, each a single byte. The opcodes up to OpPushData2 push data onto the stack,
+// either the number of bytes given by the opcode itself or by the one or two bytes after it, and the rest work
+// on the stack.
+type Script []byte
+
+// Opcode is a single instruction in a script
+type Opcode byte
+
+// The opcodes scripts are made from, numbered as they are in bitcoin
+const (
+	Op0                   Opcode = 0x00
+	OpPushData1           Opcode = 0x4c
+	OpPushData2           Opcode = 0x4d
+	Op1                   Opcode = 0x51
+	Op16                  Opcode = 0x60
+	OpIf                  Opcode = 0x63
+	OpNotIf               Opcode = 0x64
+	OpElse                Opcode = 0x67
+	OpEndIf               Opcode = 0x68
+	OpVerify              Opcode = 0x69
+	OpReturn              Opcode = 0x6a
+	OpDrop                Opcode = 0x75
+	OpDup                 Opcode = 0x76
+	OpSize                Opcode = 0x82
+	OpEqual               Opcode = 0x87
+	OpEqualVerify         Opcode = 0x88
+	OpSha256              Opcode = 0xa8
+	OpHash160             Opcode = 0xa9
+	OpCheckSig            Opcode = 0xac
+	OpCheckSigVerify      Opcode = 0xad
+	OpCheckMultiSig       Opcode = 0xae
+	OpCheckMultiSigVerify Opcode = 0xaf
+	OpCheckLockTimeVerify Opcode = 0xb1
+)
+
+// The names of the opcodes that don't push data, as scripts are written out
+var opcodeNames = map[Opcode]string{
+	OpIf:                  "OP_IF",
+	OpNotIf:               "OP_NOTIF",
+	OpElse:                "OP_ELSE",
+	OpEndIf:               "OP_ENDIF",
+	OpVerify:              "OP_VERIFY",
+	OpReturn:              "OP_RETURN",
+	OpDrop:                "OP_DROP",
+	OpDup:                 "OP_DUP",
+	OpSize:                "OP_SIZE",
+	OpEqual:               "OP_EQUAL",
+	OpEqualVerify:         "OP_EQUALVERIFY",
+	OpSha256:              "OP_SHA256",
+	OpHash160:             "OP_HASH160",
+	OpCheckSig:            "OP_CHECKSIG",
+	OpCheckSigVerify:      "OP_CHECKSIGVERIFY",
+	OpCheckMultiSig:       "OP_CHECKMULTISIG",
+	OpCheckMultiSigVerify: "OP_CHECKMULTISIGVERIFY",
+	OpCheckLockTimeVerify: "OP_CHECKLOCKTIMEVERIFY",
+}
+
+// String returns the name of an opcode
+func (op Opcode) String() string {
+	switch {
+	case op == Op0:
+		return "OP_0"
+	case op >= Op1 && op <= Op16:
+		return fmt.Sprintf("OP_%d", op-Op1+1)
+	}
+
+	if n, ok := opcodeNames[op]; ok {
+		return n
+	}
+
+	return fmt.Sprintf("OP_UNKNOWN_%d", byte(op))
+}
+
+// The limits on scripts, so no script can take too much space or time to run
+const (
+	MaxScriptSize        = 10000
+	MaxScriptElementSize = 520
+	MaxScriptOps         = 201
+	MaxStackSize         = 1000
+	MaxMultiSigKeys      = 20
+	MaxBlockSigOps       = 20000
+)
+
+// ErrBadScript is returned when a script can't be read
+var ErrBadScript = errors.New("malformed script")
+
+// scriptOp is a single opcode read from a script, along with the data it pushes
+type scriptOp struct {
+	op   Opcode
+	data []byte
+}
+
+// Reads a script into its opcodes, failing if a push runs past the end of the script
+func (s Script) parse() ([]scriptOp, error) {
+	var ops []scriptOp
+
+	for i := 0; i < len(s); {
+		op := Opcode(s[i])
+		i++
+
+		// Work out how many bytes the opcode pushes, if any
+		n := 0
+
+		switch {
+		case op > Op0 && op < OpPushData1:
+			n = int(op)
+		case op == OpPushData1:
+			if i+1 > len(s) {
+				return nil, ErrBadScript
+			}
+
+			n = int(s[i])
+			i++
+		case op == OpPushData2:
+			if i+2 > len(s) {
+				return nil, ErrBadScript
+			}
+
+			n = int(s[i]) | int(s[i+1])<<8
+			i += 2
+		}
+
+		if i+n > len(s) {
+			return nil, ErrBadScript
+		}
+
+		ops = append(ops, scriptOp{op, s[i : i+n]})
+		i += n
+	}
+
+	return ops, nil
+}
+
+// Checks whether an opcode only pushes data
+func (op Opcode) isPush() bool {
+	return op <= OpPushData2 || op >= Op1 && op <= Op16
+}
+
+// IsPushOnly checks whether a script does nothing but push data, which every ScriptSig must
+func (s Script) IsPushOnly() bool {
+	ops, err := s.parse()
+	if err != nil {
+		return false
+	}
+
+	for _, o := range ops {
+		if o.op.isPush() == false {
+			return false
+		}
+	}
+
+	return true
+}
+
+// AddOp returns the script with an opcode added to the end
+func (s Script) AddOp(op Opcode) Script {
+	return append(s, byte(op))
+}
+
+// AddData returns the script with a push of some data added to the end, using the smallest push that fits
+func (s Script) AddData(d []byte) Script {
+	n := len(d)
+
+	switch {
+	case n == 0:
+		return append(s, byte(Op0))
+	case n < int(OpPushData1):
+		s = append(s, byte(n))
+	case n <= 0xff:
+		s = append(s, byte(OpPushData1), byte(n))
+	default:
+		s = append(s, byte(OpPushData2), byte(n), byte(n>>8))
+	}
+
+	return append(s, d...)
+}
+
+// AddInt returns the script with a push of a number added to the end, using OP_0 to OP_16 where it can
+func (s Script) AddInt(n int64) Script {
+	if n == 0 {
+		return s.AddOp(Op0)
+	}
+
+	if n >= 1 && n <= 16 {
+		return s.AddOp(Op1 + Opcode(n-1))
+	}
+
+	return s.AddData(encodeScriptNum(n))
+}
+
+// String writes a script out as its opcode names, with the data it pushes in hex
+func (s Script) String() string {
+	ops, err := s.parse()
+	if err != nil {
+		return "[malformed script " + hex.EncodeToString(s) + "]"
+	}
+
+	var w []string
+
+	for _, o := range ops {
+		if o.op > Op0 && o.op <= OpPushData2 {
+			w = append(w, hex.EncodeToString(o.data))
+			continue
+		}
+
+		w = append(w, o.op.String())
+	}
+
+	return strings.Join(w, " ")
+}
+
+// ParseScript reads a script written out as opcode names and hex data, the way String writes it
+func ParseScript(w string) (Script, error) {
+	s := Script{}
+
+	for _, f := range strings.Fields(w) {
+		if op, ok := opcodeByName(f); ok {
+			s = s.AddOp(op)
+			continue
+		}
+
+		d, err := hex.DecodeString(f)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %q is neither an opcode nor hex data", ErrBadScript, f)
+		}
+
+		s = s.AddData(d)
+	}
+
+	return s, nil
+}
+
+// Finds an opcode from its name
+func opcodeByName(n string) (Opcode, bool) {
+	for op := Op0; ; op++ {
+		// Data pushes are written as hex so they are never looked up by name
+		if (op == Op0 || op > OpPushData2) && op.String() == n {
+			return op, true
+		}
+
+		if op == 0xff {
+			return 0, false
+		}
+	}
+}
+
+// Counts the signature checks a script makes, which are limited for each block. A multisig check counts as its
+// number of keys when that is pushed just before it, and as the most keys it could have otherwise.
+func (s Script) sigOps() int {
+	ops, err := s.parse()
+	if err != nil {
+		return 0
+	}
+
+	n := 0
+
+	for i, o := range ops {
+		switch o.op {
+		case OpCheckSig, OpCheckSigVerify:
+			n++
+		case OpCheckMultiSig, OpCheckMultiSigVerify:
+			if i > 0 && ops[i-1].op >= Op1 && ops[i-1].op <= Op16 {
+				n += int(ops[i-1].op-Op1) + 1
+			} else {
+				n += MaxMultiSigKeys
+			}
+		}
+	}
+
+	return n
+}
+
+// SigOps counts the signature checks in a transaction's scripts
+func (t *Transaction) SigOps() int {
+	n := 0
+
+	for _, in := range t.Inputs {
+		n += in.ScriptSig.sigOps()
+	}
+
+	for _, o := range t.Outputs {
+		n += o.ScriptPubKey.sigOps()
+	}
+
+	return n
+}
+
+// Hash160 hashes data down to 20 bytes, as public keys and scripts are hashed in scripts and addresses.
+// It is the first 20 bytes of a double sha256 hash.
+func Hash160(d []byte) []byte {
+	h := sha256.Sum256(d)
+	h = sha256.Sum256(h[:])
+
+	return h[:20]
+}
+
+// PubKeyHashScript returns a script locking an output to the public key with the given Hash160 hash, spent with a
+// ScriptSig pushing a signature and the public key
+func PubKeyHashScript(h []byte) Script {
+	return Script{}.AddOp(OpDup).AddOp(OpHash160).AddData(h).AddOp(OpEqualVerify).AddOp(OpCheckSig)
+}
+
+// MultiSigScript returns a script locking an output to m signatures from the given public keys, spent with a
+// ScriptSig pushing the signatures in the same order as their keys
+func MultiSigScript(m int, pks [][]byte) Script {
+	s := Script{}.AddInt(int64(m))
+
+	for _, pk := range pks {
+		s = s.AddData(pk)
+	}
+
+	return s.AddInt(int64(len(pks))).AddOp(OpCheckMultiSig)
+}
+
+// HashLockScript returns a script needing the preimage of a sha256 hash to be pushed before the script it guards
+func HashLockScript(h []byte, s Script) Script {
+	return append(Script{}.AddOp(OpSha256).AddData(h).AddOp(OpEqualVerify), s...)
+}
+
+// LockTimeScript returns a script which can't be spent until the lock time given, a block height or unix time
+// as with a transaction's lock time, before running the script it guards
+func LockTimeScript(lt uint32, s Script) Script {
+	return append(Script{}.AddInt(int64(lt)).AddOp(OpCheckLockTimeVerify).AddOp(OpDrop), s...)
+}
+
+// Numbers in scripts are little endian with the sign in the top bit, using as few bytes as they can
+func encodeScriptNum(n int64) []byte {
+	if n == 0 {
+		return nil
+	}
+
+	neg := n < 0
+	if neg {
+		n = -n
+	}
+
+	var b []byte
+	for ; n > 0; n >>= 8 {
+		b = append(b, byte(n))
+	}
+
+	// If the top bit is taken by the number add a byte for the sign, otherwise set the sign in it
+	if b[len(b)-1]&0x80 != 0 {
+		if neg {
+			b = append(b, 0x80)
+		} else {
+			b = append(b, 0)
+		}
+	} else if neg {
+		b[len(b)-1] |= 0x80
+	}
+
+	return b
+}
+
+// Reads a number from the stack, failing if it takes more than l bytes
+func decodeScriptNum(b []byte, l int) (int64, error) {
+	if len(b) > l {
+		return 0, fmt.Errorf("%w: number of %d bytes is more than %d", ErrScriptFailed, len(b), l)
+	}
+
+	if len(b) == 0 {
+		return 0, nil
+	}
+
+	var n int64
+	for i, v := range b {
+		n |= int64(v) << (8 * uint(i))
+	}
+
+	// Take the sign back out of the top byte
+	if b[len(b)-1]&0x80 != 0 {
+		return -(n &^ (int64(0x80) << (8 * uint(len(b)-1)))), nil
+	}
+
+	return n, nil
+}
+
+// Checks whether some stack data counts as true, which is anything but zeros, allowing for a negative zero
+func castToBool(b []byte) bool {
+	for i, v := range b {
+		if v != 0 {
+			return i != len(b)-1 || v != 0x80
+		}
+	}
+
+	return false
+}
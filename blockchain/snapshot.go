@@ -0,0 +1,143 @@
+package blockchain
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+
+	"github.com/dgraph-io/badger"
+)
+
+// The magic bytes a snapshot file starts with, the version of the snapshot format, and the number of writes the
+// database can have pending while a snapshot is being restored
+const (
+	snapshotMagic        = "GBCS"
+	snapshotVersion      = 1
+	restorePendingWrites = 256
+)
+
+// A snapshot file is made of the following:
+//
+//	header:   the magic bytes and the snapshot version byte
+//	backup:   the database backup written by badger
+//	checksum: the sha256 hash of everything before it
+
+// ErrBadSnapshot is returned when a snapshot file is not in the snapshot format or is corrupted
+var ErrBadSnapshot = errors.New("not a valid snapshot file")
+
+// Snapshot writes a consistent backup of everything in the database, the blocks, the latest hash and the indexes,
+// to w. It reads from a single point in time so it is safe to take while blocks are being added.
+func (bc *BlockChain) Snapshot(w io.Writer) error {
+	// Hash everything written so the checksum can be added at the end
+	h := sha256.New()
+	bw := bufio.NewWriter(io.MultiWriter(w, h))
+
+	// Write the header then the backup
+	bw.WriteString(snapshotMagic)
+	bw.WriteByte(snapshotVersion)
+
+	_, err := bc.Database.Backup(bw, 0)
+	if err != nil {
+		return err
+	}
+
+	// Flush everything through the hash before writing the checksum itself
+	err = bw.Flush()
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(h.Sum(nil))
+
+	return err
+}
+
+// RestoreBlockChain creates a new database from a snapshot and returns the chain it holds.
+// The snapshot's checksum is checked first, and if it can't be loaded the new database is removed.
+func RestoreBlockChain(r io.ReadSeeker) (*BlockChain, error) {
+	// Check if the database already exists...
+	if checkDB() {
+		fmt.Fprintln(Output, "Blockchain already exists...")
+		runtime.Goexit()
+	}
+
+	// Check the whole file is intact before touching the database
+	err := verifyChecksum(r)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrBadSnapshot, err)
+	}
+
+	// Work out how long the backup is, it sits between the header and the checksum
+	size, err := r.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = r.Seek(0, io.SeekStart)
+	if err != nil {
+		return nil, err
+	}
+
+	// Read and check the header
+	hd := make([]byte, len(snapshotMagic)+1)
+
+	_, err = io.ReadFull(r, hd)
+	if err != nil || string(hd[:len(snapshotMagic)]) != snapshotMagic {
+		return nil, ErrBadSnapshot
+	}
+
+	if v := hd[len(snapshotMagic)]; v != snapshotVersion {
+		return nil, fmt.Errorf("%w: unknown snapshot version %d", ErrBadSnapshot, v)
+	}
+
+	backup := io.LimitReader(r, size-int64(len(hd))-sha256.Size)
+
+	// Create an instance of the database options and set the path to the data directory
+	o := badger.DefaultOptions("")
+	o.Dir = DataDir
+	o.ValueDir = DataDir
+
+	// Make sure the directory exists, then open the connection to the database with the options
+	err = os.MkdirAll(DataDir, 0755)
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := badger.Open(o)
+	if err != nil {
+		return nil, err
+	}
+
+	// Load the backup, then make sure it held a chain
+	err = db.Load(backup, restorePendingWrites)
+	if err == nil {
+		err = db.View(func(txn *badger.Txn) error {
+			_, err := txn.Get([]byte("lh"))
+			if err == badger.ErrKeyNotFound {
+				return fmt.Errorf("%w: it does not hold a blockchain", ErrBadSnapshot)
+			}
+
+			return err
+		})
+	}
+
+	// Remove the new database if anything went wrong
+	if err != nil {
+		db.Close()
+		os.RemoveAll(DataDir)
+
+		return nil, err
+	}
+
+	// Close the database and open it again as a chain
+	err = db.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	return ContinueBlockChain(""), nil
+}
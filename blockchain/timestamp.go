@@ -0,0 +1,82 @@
+package blockchain
+
+import (
+	"sort"
+	"time"
+
+	"github.com/dgraph-io/badger"
+)
+
+// The number of blocks the median time past is taken over, and how far ahead of the clock a block can be
+const (
+	MedianTimeSpan = 11
+	MaxFutureDrift = 2 * time.Hour
+)
+
+// Clock returns the current time, it can be replaced to control the time the chain sees
+var Clock = time.Now
+
+// Returns the median timestamp of the block with the given hash and the blocks before it, up to MedianTimeSpan
+// blocks in all
+func medianTimePast(txn *badger.Txn, h []byte) (int64, error) {
+	var ts []int64
+
+	// Walk back from the block collecting timestamps until enough are found or the initial block is passed
+	for len(ts) < MedianTimeSpan && len(h) != 0 {
+		rb, err := getValue(txn, h)
+		if err != nil {
+			return 0, err
+		}
+
+		b, err := DeserialiseBlock(rb)
+		if err != nil {
+			return 0, err
+		}
+
+		ts = append(ts, b.Timestamp)
+		h = b.PreviousHash
+	}
+
+	if len(ts) == 0 {
+		return 0, nil
+	}
+
+	sort.Slice(ts, func(i, j int) bool { return ts[i] < ts[j] })
+
+	return ts[len(ts)/2], nil
+}
+
+// MedianTimePast returns the median timestamp of the latest MedianTimeSpan blocks, a new block must be
+// timestamped after it
+func (bc *BlockChain) MedianTimePast() int64 {
+	// Storage variable for the median
+	var m int64
+
+	// Work out the median from the latest block, handling any errors
+	err := bc.Database.View(func(txn *badger.Txn) error {
+		var err error
+		m, err = medianTimePast(txn, bc.LatestHash)
+
+		return err
+	})
+	HandleError(err)
+
+	return m
+}
+
+// Returns the timestamp for a new block on top of the given block. Deterministic chains count up one second a
+// block, otherwise it is the time from the clock, moved on past the median time past if blocks are being made
+// faster than the clock ticks.
+func (bc *BlockChain) nextTimestamp(pb *Block) int64 {
+	if bc.Deterministic() {
+		return pb.Timestamp + 1
+	}
+
+	ts := Clock().Unix()
+
+	if m := bc.MedianTimePast(); ts <= m {
+		ts = m + 1
+	}
+
+	return ts
+}
@@ -0,0 +1,132 @@
+package blockchain
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+// Creates a regtest chain paying the genesis reward to a in a temporary data directory, with its messages
+// discarded. The returned function closes the chain and removes the directory.
+func newTestChain(t *testing.T, a string, d bool) (*BlockChain, func()) {
+	t.Helper()
+
+	return newTestChainFrom(t, RegtestGenesis(a, d))
+}
+
+// Creates a chain from a genesis in a temporary data directory, in the same way as newTestChain
+func newTestChainFrom(t *testing.T, g *Genesis) (*BlockChain, func()) {
+	t.Helper()
+
+	dir, err := ioutil.TempDir("", "blocks")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	od, oo := DataDir, Output
+	DataDir, Output = dir, ioutil.Discard
+
+	bc := InitialiseBlockChain(g, true)
+
+	return bc, func() {
+		bc.Database.Close()
+		os.RemoveAll(dir)
+		DataDir, Output = od, oo
+	}
+}
+
+// Sets the clock the chain sees to the given unix time, the returned function puts the real clock back
+func setClock(ts int64) func() {
+	oc := Clock
+	Clock = func() time.Time { return time.Unix(ts, 0) }
+
+	return func() { Clock = oc }
+}
+
+func TestBlockTimestamps(t *testing.T) {
+	// The clock is well past the chain so only the cases that mean to can break the drift limit
+	now := int64(RegtestTimestamp + 100000)
+	drift := int64(MaxFutureDrift / time.Second)
+
+	tests := []struct {
+		name string
+		ts   func(m int64) int64
+		err  error
+	}{
+		{"after the median time past", func(m int64) int64 { return m + 1 }, nil},
+		{"at the median time past", func(m int64) int64 { return m }, ErrInvalidBlock},
+		{"before the median time past", func(m int64) int64 { return m - 3 }, ErrInvalidBlock},
+		{"at the clock", func(m int64) int64 { return now }, nil},
+		{"at the drift limit", func(m int64) int64 { return now + drift }, nil},
+		{"past the drift limit", func(m int64) int64 { return now + drift + 1 }, ErrInvalidBlock},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			defer setClock(now)()
+
+			// Deterministic timestamps count up a second a block, so the median is in the middle of the chain
+			bc, done := newTestChain(t, "miner", true)
+			defer done()
+
+			if _, err := bc.Generate("miner", MedianTimeSpan-1); err != nil {
+				t.Fatal(err)
+			}
+
+			m := bc.MedianTimePast()
+			if w := int64(RegtestTimestamp + MedianTimeSpan/2); m != w {
+				t.Fatalf("median time past is %d, want %d", m, w)
+			}
+
+			pb, err := bc.GetBlock(bc.LatestHash)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			c := CoinbaseTx("miner", "timestamp test")
+			nb := CreateBlockAt([]*Transaction{c}, pb.Hash, tc.ts(m), pb.Difficulty)
+
+			if err := bc.ValidateBlock(nb); errors.Is(err, tc.err) == false {
+				t.Errorf("ValidateBlock() = %v, want %v", err, tc.err)
+			}
+		})
+	}
+}
+
+func TestNextTimestamp(t *testing.T) {
+	now := int64(RegtestTimestamp + 100000)
+
+	tests := []struct {
+		name   string
+		blocks int
+		want   func(m int64) int64
+	}{
+		{"clock ahead of the median time past", 0, func(m int64) int64 { return now }},
+		{"blocks made faster than the clock ticks", 3, func(m int64) int64 { return m + 1 }},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			defer setClock(now)()
+
+			// Without deterministic timestamps new blocks take the time from the clock
+			bc, done := newTestChain(t, "miner", false)
+			defer done()
+
+			if _, err := bc.Generate("miner", tc.blocks); err != nil {
+				t.Fatal(err)
+			}
+
+			pb, err := bc.GetBlock(bc.LatestHash)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if ts, w := bc.nextTimestamp(pb), tc.want(bc.MedianTimePast()); ts != w {
+				t.Errorf("nextTimestamp() = %d, want %d", ts, w)
+			}
+		})
+	}
+}
@@ -3,36 +3,58 @@ package blockchain
 import (
 	"bytes"
 	"crypto/sha256"
-	"encoding/gob"
-	"encoding/hex"
 	"fmt"
 	"log"
 )
 
 // Transaction stores the relevant parts of a blockchain transaction, containing multiple inputs and outputs
+// along with the version it is encoded with and the lock time before which it can't be added to a block
 type Transaction struct {
-	ID      []byte
-	Inputs  []TxInput
-	Outputs []TxOutput
+	ID       []byte
+	Inputs   []TxInput
+	Outputs  []TxOutput
+	Version  byte
+	LockTime uint32
 }
 
-// SetID creates a sha256 hash ID for a transaction
+// Serialise returns the canonical encoding of a transaction, which doesn't include its ID
+func (t *Transaction) Serialise() []byte {
+	// Create a new encoder and encode the transaction with it
+	var e encoder
+	t.encode(&e)
+
+	return e.buf.Bytes()
+}
+
+// SetID creates a sha256 hash ID for a transaction from its canonical encoding. From version 4 the script sigs are
+// left out, as they are when signing, so changing how an output is unlocked can't change the ID of a transaction,
+// and transactions signed spending it before it was sent stay valid.
 func (t *Transaction) SetID() {
-	// Create some data as a buffer and a hash variable
-	var d bytes.Buffer
-	var h [32]byte
+	if t.Version < 4 {
+		t.ID = txHash(t.Serialise())
+		return
+	}
 
-	// Create a new encoder, passing the data to it
-	var e = gob.NewEncoder(&d)
+	t.ID = txHash(t.withoutScriptSigs().Serialise())
+}
 
-	// Encode the transaction, handling any errors
-	err := e.Encode(t)
-	HandleError(err)
+// Returns a copy of a transaction with every script sig cleared, without touching the original inputs
+func (t *Transaction) withoutScriptSigs() *Transaction {
+	c := *t
+	c.Inputs = append([]TxInput{}, t.Inputs...)
 
-	// Create a hash with the datas bytes and assign to the transaction
-	h = sha256.Sum256(d.Bytes())
-	t.ID = h[:]
+	for i := range c.Inputs {
+		c.Inputs[i].ScriptSig = nil
+	}
 
+	return &c
+}
+
+// Creates the ID for a transaction from its canonical encoding
+func txHash(d []byte) []byte {
+	h := sha256.Sum256(d)
+
+	return h[:]
 }
 
 // CoinbaseTx handles the coinbase (the original transaction)
@@ -42,12 +64,16 @@ func CoinbaseTx(r, d string) *Transaction {
 		d = fmt.Sprintf("Coins to %s", r)
 	}
 
+	// Get the script the recepient's outputs are locked with, if any
+	s, err := AddressScript(r)
+	HandleError(err)
+
 	// Create a transaction input and output with the given data and recepient
-	tIn := TxInput{[]byte{}, -1, d}
-	tOut := TxOutput{100, r}
+	tIn := TxInput{[]byte{}, -1, d, MaxSequence, nil}
+	tOut := TxOutput{100, r, s}
 
 	// Use the above to construct a new transaction
-	t := Transaction{nil, []TxInput{tIn}, []TxOutput{tOut}}
+	t := Transaction{nil, []TxInput{tIn}, []TxOutput{tOut}, TxVersion, 0}
 
 	// Call the SetID method
 	t.SetID()
@@ -62,47 +88,142 @@ func (t *Transaction) IsCoinbase() bool {
 	return len(t.Inputs) == 1 && len(t.Inputs[0].ID) == 0 && t.Inputs[0].Out == -1
 }
 
-// NewTransaction takes a from and to address, an amount and a block chain and makes a transaction to return
-func NewTransaction(f, t string, a int, bc *BlockChain) *Transaction {
+// Recipient is an address and the amount a transaction pays to it
+type Recipient struct {
+	Address string `json:"address"`
+	Amount  Amount `json:"amount"`
+}
+
+// NewTransaction takes a from address, the recipients to pay, a coin selector and a block chain and makes a
+// transaction to return, with an output for each recipient in order followed by any change.
+// The coin selector picks which of the from address's outputs are spent, largest first is used if it is nil.
+// Change below the chain's dust limit isn't worth an output so it is left as the fee instead.
+// Spending from a script hash address leaves every ScriptSig empty, they have to be filled in before it is sent.
+// Outputs already spent by pending transactions are left alone.
+func NewTransaction(f string, rs []Recipient, cs CoinSelector, bc *BlockChain) *Transaction {
+	return NewTransactionWithFee(f, rs, 0, cs, bc)
+}
+
+// NewTransactionWithFee makes a transaction as NewTransaction does, spending enough more to leave the given fee
+func NewTransactionWithFee(f string, rs []Recipient, fee Amount, cs CoinSelector, bc *BlockChain) *Transaction {
 	// Create two holding variables for the inputs and outputs
 	var i []TxInput
 	var o []TxOutput
 
-	// Get the accumulated value and the unspent outputs for the from address, up to the specified amount
-	acc, uo := bc.GetSpendableOutputs(f, a)
+	// There must be someone to pay, and each of them must be paid something
+	if len(rs) == 0 {
+		log.Panic("Error : No recipients!")
+	}
+
+	var a Amount
+	dl := bc.DustLimit()
+
+	// Get the script the from address's outputs are locked with, and the script for each recipient
+	fs, err := AddressScript(f)
+	if err != nil {
+		log.Panicf("Error : %s!", err)
+	}
+
+	var ss []Script
+
+	for _, r := range rs {
+		s, err := AddressScript(r.Address)
+		if r.Address == "" || r.Amount == 0 || err != nil {
+			log.Panicf("Error : Invalid recipient %q with amount %d!", r.Address, r.Amount)
+		}
+
+		ss = append(ss, s)
 
-	// If the accumulator does not reach the amount then the account does not have enough funds
-	if acc < a {
+		if r.Amount < dl {
+			log.Panicf("Error : Amount %d to %q is below the dust limit of %d!", r.Amount, r.Address, dl)
+		}
+
+		// Add up the total, which can't be more than there could ever be
+		a, err = a.Add(r.Amount)
+		if err != nil {
+			log.Panicf("Error : %s!", err)
+		}
+	}
+
+	// The outputs recipients are paid plus the fee have to be covered
+	v, err := a.Add(fee)
+	if err != nil {
+		log.Panicf("Error : %s!", err)
+	}
+
+	if cs == nil {
+		cs = LargestFirst{}
+	}
+
+	// Only outputs locked the way the from address's own outputs are can be spent, the rest need other script sigs,
+	// and coinbase outputs can't be spent until they are mature
+	var fu []UnspentOutput
+
+	var im Amount
+	h, m := bc.Height()+1, bc.CoinbaseMaturity()
+	ps := spentOutputs(bc.Mempool())
+
+	for _, u := range bc.FindUnspentOutputs(f) {
+		if _, ok := ps[fmt.Sprintf("%x:%d", u.TxID, u.Out)]; ok || bytes.Equal(u.ScriptPubKey, fs) == false {
+			continue
+		}
+
+		if u.Mature(h, m) == false {
+			im, err = im.Add(u.Value)
+			if err != nil {
+				log.Panicf("Error : %s!", err)
+			}
+
+			continue
+		}
+
+		fu = append(fu, u)
+	}
+
+	// Select unspent outputs of the from address worth at least the total amount
+	uo, err := cs.Select(fu, v)
+
+	// If there aren't enough outputs to select from then the account does not have enough funds
+	if err == ErrInsufficientFunds && im > 0 {
+		log.Panicf("Error : Not enough funds, %d more can't be spent until the coinbase outputs holding it mature!", im)
+	}
+
+	if err == ErrInsufficientFunds {
 		log.Panic("Error : Not enough funds!")
 	}
 
-	// If the funds are available, loop through the unspent outputs
-	for id, outs := range uo {
-		// Decode the transaction ID, handling any errors
-		tID, err := hex.DecodeString(id)
-		HandleError(err)
+	HandleError(err)
+
+	// If the funds are available, loop through the selected outputs
+	var acc Amount
+
+	for _, u := range uo {
+		// Create a new transcation input from the ID, the output and the from address
+		in := TxInput{u.TxID, u.Out, f, MaxSequence, nil}
 
-		// Loop through the outputs
-		for _, out := range outs {
-			// Create a new transcation input from the ID, the output and the from address
-			in := TxInput{tID, out, f}
+		// Append the input to the holding variable and add up its value
+		i = append(i, in)
 
-			// Append the input to the holding variable
-			i = append(i, in)
+		acc, err = acc.Add(u.Value)
+		if err != nil {
+			log.Panicf("Error : %s!", err)
 		}
 	}
 
-	// Append a new transaction output, with the given amount and the to address
-	o = append(o, TxOutput{a, t})
+	// Append a new transaction output for each recipient, with their amount and address
+	for ri, r := range rs {
+		o = append(o, TxOutput{r.Amount, r.Address, ss[ri]})
+	}
 
-	// If the accumulated ammount is more than the given ammount then trim the ouput
-	if acc > a {
+	// If the accumulated ammount is more than the given ammount and the fee, and the change isn't dust, then trim
+	// the ouput
+	if acc > v && acc-v >= dl {
 		// Append a new transaction output with some money sent back to the from address
-		o = append(o, TxOutput{acc - a, f})
+		o = append(o, TxOutput{acc - v, f, fs})
 	}
 
 	// Create a new transaction with the inputs and outputs and set its ID
-	tx := Transaction{nil, i, o}
+	tx := Transaction{nil, i, o, TxVersion, 0}
 	tx.SetID()
 
 	// Return the transaction
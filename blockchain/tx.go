@@ -1,16 +1,22 @@
 package blockchain
 
-// TxOutput is the output part of a transaction, containing a value and a public key
+// TxOutput is the output part of a transaction, containing a value, the address it belongs to as its public key,
+// and the script locking it if it needs more than the address to be spent
 type TxOutput struct {
-	Value  int
-	PubKey string
+	Value        Amount
+	PubKey       string
+	ScriptPubKey Script
 }
 
-// TxInput is the input part of a transaction, containing an ID, an out value and a signature
+// TxInput is the input part of a transaction, containing an ID, an out value, a signature and a sequence number,
+// along with the script unlocking the output it spends if that output has a script.
+// A transaction's lock time only applies while at least one of its inputs has a sequence below MaxSequence.
 type TxInput struct {
-	ID  []byte
-	Out int
-	Sig string
+	ID        []byte
+	Out       int
+	Sig       string
+	Sequence  uint32
+	ScriptSig Script
 }
 
 // CanUnlock checks whether an input can unlock some given data
@@ -0,0 +1,334 @@
+package blockchain
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/dgraph-io/badger"
+)
+
+// Reward is the most a coinbase transaction can pay out in any block after the initial block
+const Reward Amount = 100
+
+// The most a block can hold, as the size of its canonical encoding in bytes and as a number of transactions
+const (
+	MaxBlockSize         = 1 << 20
+	MaxBlockTransactions = 4096
+)
+
+// Errors returned when a block or transaction breaks the rules of the chain
+var (
+	ErrInvalidBlock = errors.New("invalid block")
+	ErrInvalidTx    = errors.New("invalid transaction")
+)
+
+// Wraps one of the errors above with the reason for it
+func invalid(err error, format string, a ...interface{}) error {
+	return fmt.Errorf("%w: %s", err, fmt.Sprintf(format, a...))
+}
+
+// ValidateBlock checks that a block can be connected on top of the current latest block
+func (bc *BlockChain) ValidateBlock(b *Block) error {
+	return bc.Database.View(func(txn *badger.Txn) error {
+		return bc.validateBlock(txn, b)
+	})
+}
+
+// Checks a block against the rules of the chain within a database transaction
+func (bc *BlockChain) validateBlock(txn *badger.Txn, b *Block) error {
+	// The block must sit on top of the current latest block
+	if bytes.Equal(b.PreviousHash, bc.LatestHash) == false {
+		return invalid(ErrInvalidBlock, "previous hash %x is not the latest block %x", b.PreviousHash, bc.LatestHash)
+	}
+
+	// The block can't be from too far in the future, and after the initial block it must come after the median
+	// time past of the blocks before it
+	if l := Clock().Add(MaxFutureDrift).Unix(); b.Timestamp > l {
+		return invalid(ErrInvalidBlock, "block %x is timestamped %d, more than %s ahead of the clock", b.Hash, b.Timestamp, MaxFutureDrift)
+	}
+
+	// The height and median time past of the block are kept to check transaction lock times against
+	height := 0
+	m := int64(0)
+
+	if len(b.PreviousHash) != 0 {
+		ph, err := getHeight(txn, b.PreviousHash)
+		if err != nil {
+			return err
+		}
+
+		height = ph + 1
+
+		m, err = medianTimePast(txn, b.PreviousHash)
+		if err != nil {
+			return err
+		}
+
+		if b.Timestamp <= m {
+			return invalid(ErrInvalidBlock, "block %x is timestamped %d, not after the median time past of %d", b.Hash, b.Timestamp, m)
+		}
+	}
+
+	// The difficulty must be usable, and after the initial block it must match the difficulty of the latest block
+	if b.Difficulty < 1 || b.Difficulty > MaxDifficulty {
+		return invalid(ErrInvalidBlock, "block %x has a difficulty of %d", b.Hash, b.Difficulty)
+	}
+
+	if len(b.PreviousHash) != 0 {
+		rb, err := getValue(txn, b.PreviousHash)
+		if err != nil {
+			return err
+		}
+
+		pb, err := DeserialiseBlock(rb)
+		if err != nil {
+			return err
+		}
+
+		if b.Difficulty != pb.Difficulty {
+			return invalid(ErrInvalidBlock, "block %x has a difficulty of %d but the latest block has %d", b.Hash, b.Difficulty, pb.Difficulty)
+		}
+	}
+
+	// The block's hash must be the hash of its proof of work, and must meet the target
+	pow := NewProof(b)
+	h := sha256.Sum256(pow.InitialiseData(b.Counter))
+
+	if bytes.Equal(h[:], b.Hash) == false || pow.ValidateProof() == false {
+		return invalid(ErrInvalidBlock, "block %x does not have a valid proof of work", b.Hash)
+	}
+
+	// The block must hold at least one transaction, and can't go over the block limits
+	if len(b.Transactions) == 0 {
+		return invalid(ErrInvalidBlock, "block %x has no transactions", b.Hash)
+	}
+
+	if len(b.Transactions) > MaxBlockTransactions {
+		return invalid(ErrInvalidBlock, "block %x has %d transactions, more than the limit of %d", b.Hash, len(b.Transactions), MaxBlockTransactions)
+	}
+
+	if s := len(b.Serialise()); s > MaxBlockSize {
+		return invalid(ErrInvalidBlock, "block %x is %d bytes, more than the limit of %d", b.Hash, s, MaxBlockSize)
+	}
+
+	if s := blockSigOps(b.Transactions); s > MaxBlockSigOps {
+		return invalid(ErrInvalidBlock, "block %x makes %d signature checks, more than the limit of %d", b.Hash, s, MaxBlockSigOps)
+	}
+
+	// Keep track of the transactions and outputs seen in this block so they can't be repeated
+	ids := make(map[string]bool)
+	spent := make(map[string]bool)
+
+	for i, t := range b.Transactions {
+		// No transaction can appear twice, either in this block or earlier in the chain
+		tID := hex.EncodeToString(t.ID)
+
+		if ids[tID] {
+			return invalid(ErrInvalidTx, "transaction %s appears twice in block %x", tID, b.Hash)
+		}
+
+		ids[tID] = true
+
+		// Older blocks only commit to transaction IDs, which don't cover the script sigs from version 4
+		if b.Version < 3 && t.Version >= 4 {
+			return invalid(ErrInvalidTx, "transaction %s is version %d but block %x is version %d", tID, t.Version, b.Hash, b.Version)
+		}
+
+		if _, _, _, err := bc.findTransaction(txn, t.ID); err != ErrTxNotFound {
+			if err != nil {
+				return err
+			}
+
+			return invalid(ErrInvalidTx, "transaction %s is already in the chain", tID)
+		}
+
+		// Every transaction must be final at the block's height and median time past
+		if t.IsFinal(height, m) == false {
+			return invalid(ErrInvalidTx, "transaction %s is locked until %s", tID, DescribeLockTime(t.LockTime))
+		}
+
+		// A coinbase can only be the first transaction and can only pay out the reward, except in the initial block
+		if t.IsCoinbase() {
+			if i != 0 {
+				return invalid(ErrInvalidTx, "coinbase %s is not the first transaction in the block", tID)
+			}
+
+			out, err := outputTotal(t)
+			if err != nil {
+				return invalid(ErrInvalidTx, "coinbase %s: %s", tID, err)
+			}
+
+			for oi, o := range t.Outputs {
+				if o.hasAddressScript() == false {
+					return invalid(ErrInvalidTx, "output %d of coinbase %s isn't locked the way %q needs", oi, tID, o.PubKey)
+				}
+			}
+
+			if len(b.PreviousHash) != 0 && out > Reward {
+				return invalid(ErrInvalidTx, "coinbase %s pays out more than the reward of %d", tID, Reward)
+			}
+
+			continue
+		}
+
+		err := bc.validateTransaction(txn, t, b.Transactions[:i], height, spent)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Checks a transaction, which isn't a coinbase, against the chain. Whatever the inputs are worth beyond the
+// outputs is the transaction's fee, which nothing collects yet so it leaves circulation. Bt holds the transactions
+// before it in the block it is in, whose outputs it can spend, and spent holds the outputs they already spend,
+// updated with the outputs the transaction spends. Height is the height of the block the transaction is in.
+func (bc *BlockChain) validateTransaction(txn *badger.Txn, t *Transaction, bt []*Transaction, height int, spent map[string]bool) error {
+	tID := hex.EncodeToString(t.ID)
+
+	// The transaction must have inputs and outputs
+	if len(t.Inputs) == 0 || len(t.Outputs) == 0 {
+		return invalid(ErrInvalidTx, "transaction %s must have inputs and outputs", tID)
+	}
+
+	// Every output must be worth something, and can't be dust
+	dl, err := dustLimit(txn)
+	if err != nil {
+		return err
+	}
+
+	for i, o := range t.Outputs {
+		if o.Value == 0 || o.Value.Valid() == false {
+			return invalid(ErrInvalidTx, "output %d of transaction %s has a value of %d", i, tID, o.Value)
+		}
+
+		if o.Value < dl {
+			return invalid(ErrInvalidTx, "output %d of transaction %s is dust, %d is below the limit of %d", i, tID, o.Value, dl)
+		}
+
+		if len(o.ScriptPubKey) > MaxScriptSize {
+			return invalid(ErrInvalidTx, "output %d of transaction %s has a script of %d bytes, more than the limit of %d", i, tID, len(o.ScriptPubKey), MaxScriptSize)
+		}
+
+		if o.hasAddressScript() == false {
+			return invalid(ErrInvalidTx, "output %d of transaction %s isn't locked the way %q needs", i, tID, o.PubKey)
+		}
+	}
+
+	// The outputs together can't be worth more than there could ever be
+	out, err := outputTotal(t)
+	if err != nil {
+		return invalid(ErrInvalidTx, "transaction %s: %s", tID, err)
+	}
+
+	// Coinbase outputs need this many confirmations before they can be spent
+	m, err := coinbaseMaturity(txn)
+	if err != nil {
+		return err
+	}
+
+	// Add up the value of the outputs the inputs spend
+	var in Amount
+
+	for ii, i := range t.Inputs {
+		// The output being spent must exist, earlier in the chain or in this block
+		pt, pb, err := bc.findInputTransaction(txn, bt, i.ID)
+		if err == ErrTxNotFound {
+			return invalid(ErrInvalidTx, "transaction %s spends %x which is not in the chain", tID, i.ID)
+		}
+
+		if err != nil {
+			return err
+		}
+
+		// A coinbase output must have enough confirmations before this block to be spent, one in this block has none
+		if pt.IsCoinbase() {
+			ph := height
+
+			if pb != nil {
+				ph, err = getHeight(txn, pb.Hash)
+				if err != nil {
+					return err
+				}
+			}
+
+			if c := height - ph; c < m {
+				return invalid(ErrInvalidTx, "transaction %s spends coinbase %x which has %d confirmations, it needs %d", tID, i.ID, c, m)
+			}
+		}
+
+		if i.Out < 0 || i.Out >= len(pt.Outputs) {
+			return invalid(ErrInvalidTx, "transaction %s spends output %d of %x which doesn't exist", tID, i.Out, i.ID)
+		}
+
+		o := pt.Outputs[i.Out]
+
+		// The input must be able to unlock the output, and meet its script if it has one
+		if o.CanBeUnlocked(i.Sig) == false {
+			return invalid(ErrInvalidTx, "transaction %s can't unlock output %d of %x", tID, i.Out, i.ID)
+		}
+
+		if len(o.ScriptPubKey) != 0 {
+			if err := VerifyScript(i.ScriptSig, o.ScriptPubKey, t, ii); err != nil {
+				return invalid(ErrInvalidTx, "transaction %s can't unlock output %d of %x: %s", tID, i.Out, i.ID, err)
+			}
+		}
+
+		// The output can't already be spent, either earlier in the chain or in this block
+		k := fmt.Sprintf("%x:%d", i.ID, i.Out)
+
+		s, err := isSpent(txn, i.ID, i.Out)
+		if err != nil {
+			return err
+		}
+
+		if s || spent[k] {
+			return invalid(ErrInvalidTx, "transaction %s spends output %d of %x which is already spent", tID, i.Out, i.ID)
+		}
+
+		spent[k] = true
+
+		in, err = in.Add(o.Value)
+		if err != nil {
+			return invalid(ErrInvalidTx, "transaction %s: %s", tID, err)
+		}
+	}
+
+	// The transaction can't pay out more than it spends
+	if out > in {
+		return invalid(ErrInvalidTx, "transaction %s pays out %d but only spends %d", tID, out, in)
+	}
+
+	return nil
+}
+
+// Counts the signature checks made by some transactions
+func blockSigOps(t []*Transaction) int {
+	n := 0
+
+	for _, tx := range t {
+		n += tx.SigOps()
+	}
+
+	return n
+}
+
+// Adds up the value of a transaction's outputs, failing if any of them or the total is more than MaxMoney
+func outputTotal(t *Transaction) (Amount, error) {
+	var v Amount
+
+	for _, o := range t.Outputs {
+		var err error
+
+		v, err = v.Add(o.Value)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return v, nil
+}
@@ -0,0 +1,205 @@
+package cli
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/liamcf44/go-blockchain.git/blockchain"
+)
+
+// openChannel funds a payment channel from an address to the payee's public key with an amount, refundable to the
+// key in the key file after the lock time, and writes the channel to a file. Sending from a key hash address needs
+// it to be the address of the key file's key, which signs the funding.
+func (cli *CLI) openChannel(f string, a blockchain.Amount, ppk, kf string, lt uint32, o string) {
+	// Decode the payee's key and load the payer's, exiting if either can't be read
+	pk, err := hex.DecodeString(ppk)
+	if err != nil {
+		cli.fail("Payee public key is not hex encoded")
+	}
+
+	k := cli.loadKey(kf)
+
+	// Create the blockchain with ContinueBlockChain and the from address
+	bc := blockchain.ContinueBlockChain(f)
+
+	// Defer the closing of the database
+	defer bc.Database.Close()
+
+	c, err := blockchain.NewChannel(k.Public().(ed25519.PublicKey), pk, lt, bc.DustLimit())
+	if err != nil {
+		cli.fail(err.Error())
+	}
+
+	// Pay the channel's address and record the output as its funding
+	tx := cli.payFrom(bc, f, c.Address(), a, k)
+
+	err = c.SetFunding(tx)
+	blockchain.HandleError(err)
+
+	// Save the channel before sending anything to it, so the payer can always get the funds back
+	err = blockchain.SaveChannel(o, c)
+	if err != nil {
+		cli.fail(fmt.Sprintf("Could not save the channel: %s", err))
+	}
+
+	// Append the transaction to the chain
+	bc.AppendBlock([]*blockchain.Transaction{tx})
+
+	cli.printChannel(o, c)
+}
+
+// payChannel pays an amount more through a channel with the payer's key file, writing the new commitment back to
+// the channel file to be handed to the payee. It doesn't need the chain.
+func (cli *CLI) payChannel(f string, a blockchain.Amount, kf string) {
+	c := cli.loadChannel(f)
+
+	err := c.Pay(a, cli.loadKey(kf))
+	if err != nil {
+		cli.fail(err.Error())
+	}
+
+	cli.saveChannel(f, c)
+}
+
+// channelInfo prints what a channel file holds, checking its funding in the chain and the payer's signature so the
+// payee knows what they can close it for
+func (cli *CLI) channelInfo(f string) {
+	c := cli.loadChannel(f)
+
+	// Open the chain read only, it is only looked at
+	bc := blockchain.ContinueBlockChainReadOnly()
+
+	// Defer the closing of the database
+	defer bc.Database.Close()
+
+	cli.checkChannelFunding(bc, c)
+
+	cli.printChannel(f, c)
+}
+
+// closeChannel closes a channel with the payee's key file, sending the latest commitment
+func (cli *CLI) closeChannel(f, kf string) {
+	c := cli.loadChannel(f)
+
+	tx, err := c.Close(cli.loadKey(kf))
+	if err != nil {
+		cli.fail(err.Error())
+	}
+
+	// Create the blockchain with ContinueBlockChain and the channel's address
+	bc := blockchain.ContinueBlockChain(c.Address())
+
+	// Defer the closing of the database
+	defer bc.Database.Close()
+
+	cli.checkChannelFunding(bc, c)
+
+	// Append the transaction to the chain
+	bc.AppendBlock([]*blockchain.Transaction{tx})
+
+	cli.printSpend(bc, tx, "closed the channel paying", blockchain.KeyHashAddress(c.Payee))
+}
+
+// refundChannel takes a channel's whole capacity back to an address with the payer's key file, once the channel's
+// lock time has passed
+func (cli *CLI) refundChannel(f, kf, t string) {
+	c := cli.loadChannel(f)
+	k := cli.loadKey(kf)
+
+	// Refund to the payer's own key hash address unless told otherwise
+	if t == "" {
+		t = blockchain.KeyHashAddress(c.Payer)
+	}
+
+	tx, err := c.Refund(k, t)
+	if err != nil {
+		cli.fail(err.Error())
+	}
+
+	// Create the blockchain with ContinueBlockChain and the channel's address
+	bc := blockchain.ContinueBlockChain(c.Address())
+
+	// Defer the closing of the database
+	defer bc.Database.Close()
+
+	cli.checkChannelFunding(bc, c)
+
+	// Refunding early is refused rather than held pending, so the other side can still close before the lock time
+	if bc.IsFinal(tx) == false {
+		cli.fail(fmt.Sprintf("Channel can't be refunded until %s", blockchain.DescribeLockTime(c.LockTime)))
+	}
+
+	// Append the transaction to the chain
+	bc.AppendBlock([]*blockchain.Transaction{tx})
+
+	cli.printSpend(bc, tx, "refunded", t)
+}
+
+// Exits unless a channel's funding output is in the chain as the channel file says and is still unspent, so it
+// hasn't been closed or refunded
+func (cli *CLI) checkChannelFunding(bc *blockchain.BlockChain, c *blockchain.Channel) {
+	err := c.VerifyFunding(bc)
+	if err != nil {
+		cli.fail(fmt.Sprintf("Channel %s can't be used: %s", c.Address(), err))
+	}
+}
+
+// Loads a key file, exiting if it can't be read
+func (cli *CLI) loadKey(f string) ed25519.PrivateKey {
+	k, err := blockchain.LoadKey(f)
+	if err != nil {
+		cli.fail(fmt.Sprintf("Could not load the key: %s", err))
+	}
+
+	return k
+}
+
+// Loads a channel from a file, exiting if it can't be read
+func (cli *CLI) loadChannel(f string) *blockchain.Channel {
+	c, err := blockchain.LoadChannel(f)
+	if err != nil {
+		cli.fail(fmt.Sprintf("Could not load the channel: %s", err))
+	}
+
+	return c
+}
+
+// Saves a channel to a file and prints it
+func (cli *CLI) saveChannel(f string, c *blockchain.Channel) {
+	err := blockchain.SaveChannel(f, c)
+	if err != nil {
+		cli.fail(fmt.Sprintf("Could not save the channel: %s", err))
+	}
+
+	cli.printChannel(f, c)
+}
+
+// Prints a channel's details, and whether its commitment is signed by the payer
+func (cli *CLI) printChannel(f string, c *blockchain.Channel) {
+	co := ChannelOutput{
+		f,
+		c.Address(),
+		hex.EncodeToString(c.FundingID),
+		c.Capacity,
+		c.Paid,
+		c.Capacity - c.Paid,
+		c.LockTime,
+		c.VerifyPayment() == nil,
+	}
+
+	if cli.isJSON() {
+		cli.printJSON(co)
+		return
+	}
+
+	fmt.Printf("Channel: %s\n", co.Address)
+	fmt.Printf("File: %s\n", f)
+	fmt.Printf("Funded by transaction %s\n", co.FundingTxID)
+	fmt.Printf("Paid %s of %s, %s left\n", c.Paid.Format(cli.Decimals), c.Capacity.Format(cli.Decimals), co.Remaining.Format(cli.Decimals))
+	fmt.Printf("Must be closed before %s, when the payer can take it all back\n", blockchain.DescribeLockTime(c.LockTime))
+
+	if c.Paid > 0 && co.Signed == false {
+		fmt.Println("Warning: the commitment is not signed by the payer, it can't be closed")
+	}
+}
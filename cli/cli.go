@@ -1,31 +1,84 @@
 package cli
 
 import (
+	"encoding/hex"
+	"encoding/json"
 	"flag"
 	"fmt"
+	"io/ioutil"
 	"os"
+	"os/signal"
 	"runtime"
+	"sort"
 	"strconv"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/liamcf44/go-blockchain.git/blockchain"
+	"github.com/liamcf44/go-blockchain.git/explorer"
+	"github.com/liamcf44/go-blockchain.git/rpc"
 )
 
-// CLI stores a blockchain to allow the Command Line Interface to interact with it
-type CLI struct{}
+// CLI stores the options for the Command Line Interface, Output is the format it prints in, text or json,
+// and Decimals is the number of decimal places amounts are read and printed with
+type CLI struct {
+	Output   string
+	Decimals int
+}
 
 // Prints out the different CLI options available
 func (cli *CLI) printUsage() {
 	fmt.Println("/* Usage /*")
-	fmt.Println(" getbalance -address ADDRESS - get the balance for an address")
-	fmt.Println(" createblockchain -address ADDRESS creates a blockchain and sends genesis reward to address")
+	fmt.Println(" Any command can be prefixed with -output json to print a JSON document instead of text")
+	fmt.Println(" Any command can be prefixed with -decimals N to read and print amounts with N decimal places, JSON documents always hold base units")
+	fmt.Println(" Any command can be prefixed with -datadir DIR to use the chain kept in DIR instead of ./tmp/blocks")
+	fmt.Println(" getbalance -address ADDRESS [-minconf N] - get the balance for an address, split into immature and spendable coins, spendable coins with fewer than N confirmations or paid by pending transactions are unconfirmed")
+	fmt.Println(" listunspent -address ADDRESS [-minconf N] - Lists the unspent outputs of an address with their confirmations, leaving out those pending transactions spend, -minconf 0 lists the outputs of pending transactions too")
+	fmt.Println(" createblockchain -address ADDRESS [-dustlimit N] [-coinbasematurity N] [-txindex=false] creates a blockchain and sends genesis reward to address")
+	fmt.Println(" createblockchain -genesis FILE [-txindex=false] creates a blockchain from a genesis JSON file")
+	fmt.Println(" createblockchain -regtest -address ADDRESS [-deterministic] [-dustlimit N] [-coinbasematurity N] [-txindex=false] creates a regtest blockchain, its blocks are mined instantly")
+	fmt.Println("  coinbase outputs can be spent after -coinbasematurity confirmations, 100 by default or none on regtest")
+	fmt.Println(" generate -blocks N -address ADDRESS - Mines N blocks on a regtest chain paying the reward to address, along with any pending transactions")
 	fmt.Println(" print - Prints the blocks in the chain")
-	fmt.Println(" send -from FROM -to TO -amount AMOUNT - Send amount of coins")
+	fmt.Println(" send -from FROM -to TO -amount AMOUNT [-coinselect largest|smallest|bnb|random] [-locktime HEIGHT|TIME] [-fee FEE] [-pending [-replaceable]] - Send amount of coins, a transaction locked past the next block is held pending until it is final")
+	fmt.Println("  -pending holds the transaction until the next block is mined instead of mining it now, -replaceable lets bumpfee replace it until then")
+	fmt.Println(" sendmany -from FROM (-to ADDRESS:AMOUNT,ADDRESS:AMOUNT... | -file PAYOUTS.json) [-coinselect SELECTOR] - Send amounts to several addresses in one transaction")
+	fmt.Println(" createkey -file FILE - Creates a key pair, saving it to a key file and printing the public key")
+	fmt.Println(" createmultisig -required M -keys PUBKEY,PUBKEY... - Prints the address and redeem script needing M signatures from the keys")
+	fmt.Println(" createpsbt -from FROM -to TO -amount AMOUNT -out FILE [-redeemscript SCRIPT] [-coinselect SELECTOR] [-locktime HEIGHT|TIME] [-replaceable] - Writes an unsigned transaction to a file to be signed elsewhere")
+	fmt.Println(" signpsbt -in FILE -key KEYFILE [-out FILE] - Signs every input of a partially signed transaction the key can, without the chain")
+	fmt.Println(" combinepsbt -in FILE,FILE... -out FILE - Merges the signatures of partially signed transactions")
+	fmt.Println(" finalizepsbt -in FILE [-out FILE] - Fills in the script sigs of a fully signed transaction, printing it in hex")
+	fmt.Println(" broadcast (-tx HEX | -file FILE) [-pending] - Sends a finalised transaction, -pending or a lock time past the next block holds it until a later block is mined")
+	fmt.Println(" initiateswap -from FROM -amount AMOUNT -recipient PUBKEY -key KEYFILE -locktime HEIGHT|TIME [-secrethash HASH] - Pays into a hash time locked contract, making a secret unless its hash is given")
+	fmt.Println(" auditswap -contract CONTRACT - Prints the details of a contract and how much is locked to it")
+	fmt.Println(" redeemswap -contract CONTRACT -secret SECRET -key KEYFILE -to ADDRESS - Spends a contract with its secret and the recipient's key")
+	fmt.Println(" refundswap -contract CONTRACT -key KEYFILE -to ADDRESS - Spends a contract back with the sender's key once its lock time has passed")
+	fmt.Println(" extractsecret -contract CONTRACT [-txid ID] - Prints the secret revealed by the transaction redeeming a contract")
+	fmt.Println(" openchannel -from FROM -amount AMOUNT -payee PUBKEY -key KEYFILE -locktime HEIGHT|TIME -out FILE - Funds a payment channel to the payee, writing it to a channel file")
+	fmt.Println(" paychannel -channel FILE -amount AMOUNT -key KEYFILE - Pays an amount more through a channel with the payer's key, without the chain")
+	fmt.Println(" channelinfo -channel FILE - Prints what a channel file holds and whether the payer has signed it")
+	fmt.Println(" closechannel -channel FILE -key KEYFILE - Closes a channel with the payee's key, sending the latest payment")
+	fmt.Println(" refundchannel -channel FILE -key KEYFILE [-to ADDRESS] - Takes a channel back with the payer's key once its lock time has passed")
+	fmt.Println(" gettransaction -id ID - Prints the transaction with the given ID")
+	fmt.Println(" bumpfee -txid ID -fee FEE [-key KEYFILE] - Replaces a pending transaction that signalled it is replaceable with one paying a higher fee out of its change, signing it with the key if it spends from a key hash address")
+	fmt.Println(" reindex [-txindex=false] - Rebuilds the indexes for the chain")
+	fmt.Println(" history -address ADDRESS [-cursor CURSOR] [-limit LIMIT] - Lists the transactions for an address")
+	fmt.Println(" serve -rpcuser USER -rpcpassword PASSWORD [-rpcport PORT] [-walletdir DIR] [-backupdir DIR] - Serves the chain over JSON-RPC")
+	fmt.Println(" exportchain -file FILE - Writes every block in the chain to a portable file")
+	fmt.Println(" importchain -file FILE [-txindex=false] - Creates a chain from a file written by exportchain")
+	fmt.Println(" snapshot -out FILE [-rpcuser USER -rpcpassword PASSWORD [-rpchost HOST] [-rpcport PORT]] - Backs up the database, asking a running node to if credentials are given, which writes FILE in its backup directory")
+	fmt.Println(" restore -in FILE - Creates the database from a snapshot")
+	fmt.Println(" migrate - Re-encodes a chain made with the old block encoding, changing every transaction ID")
+	fmt.Println(" explorer [-port PORT] - Serves a read only REST API for browsing the chain")
+	fmt.Println(" rpc -rpcuser USER -rpcpassword PASSWORD -method METHOD [-params PARAMS] [-rpchost HOST] [-rpcport PORT] - Calls a JSON-RPC method on a running node")
 }
 
-// Validates the given CLI arguments
-func (cli *CLI) validateArgs() {
-	// If there are less than two arguments, print usage and exit
-	if len(os.Args) < 2 {
+// Validates the given CLI arguments, which are what remains after the global flags
+func (cli *CLI) validateArgs(args []string) {
+	// If there is no command, print usage and exit
+	if len(args) < 1 {
 		cli.printUsage()
 		runtime.Goexit()
 	}
@@ -39,177 +92,1635 @@ func (cli *CLI) printChain() {
 	// Defer the closing of the chain's database
 	defer bc.Database.Close()
 
-	// Create a new iterator to cycle through the blockchain
+	// Create a new iterator to cycle through the blockchain, along with the height of the latest block
 	it := bc.CreateIterator()
+	h := bc.Height()
+
+	// Holding variable for the JSON document
+	co := ChainOutput{[]BlockOutput{}}
 
 	// Set up a loop...
 	for {
 		// Get the next block in the chain
 		b := it.Next()
 
-		// Print out the various parts of the block
-		fmt.Printf("Hash ==> %x\n", b.Hash)
-		fmt.Printf("PreviousHash ==> %x\n", b.PreviousHash)
-
-		// Create a Proof of Work for the block and print if it is valid
+		// Create a Proof of Work for the block to check if it is valid
 		pow := blockchain.NewProof(b)
+		v := pow.ValidateProof()
 
-		fmt.Printf("Proof of Work ==> %s\n", strconv.FormatBool(pow.ValidateProof()))
-		fmt.Println()
+		// In JSON mode collect the block, otherwise print out the various parts of the block
+		if cli.isJSON() {
+			co.Blocks = append(co.Blocks, BlockOutput{b, h, v})
+		} else {
+			fmt.Printf("Hash ==> %x\n", b.Hash)
+			fmt.Printf("PreviousHash ==> %x\n", b.PreviousHash)
+			fmt.Printf("Timestamp ==> %s\n", time.Unix(b.Timestamp, 0).UTC().Format(time.RFC3339))
+			fmt.Printf("Proof of Work ==> %s\n", strconv.FormatBool(v))
+			fmt.Println()
+		}
 
 		// If there is no previous block then the end of the chain has been reached, break.
 		if len(b.PreviousHash) == 0 {
 			break
 		}
+
+		h--
+	}
+
+	if cli.isJSON() {
+		cli.printJSON(co)
 	}
 }
 
-// createBlockChain creates a new blockchain with a given address
-func (cli *CLI) createBlockChain(a string) {
+// createBlockChain creates a new blockchain from a genesis paying the reward to a given address,
+// or from a genesis file if given
+func (cli *CLI) createBlockChain(a string, g *blockchain.Genesis, gf string, ti bool) {
+	// Use the genesis file if one was given, otherwise check the genesis made from the flags
+	if gf != "" {
+		var err error
+
+		g, err = blockchain.LoadGenesis(gf)
+		if err != nil {
+			cli.fail(fmt.Sprintf("Invalid genesis file: %s", err))
+		}
+	} else if err := g.Validate(); err != nil {
+		cli.fail(fmt.Sprintf("Invalid genesis: %s", err))
+	}
+
 	// Create the new chain with InitialiseBlockChain
-	bc := blockchain.InitialiseBlockChain(a)
+	bc := blockchain.InitialiseBlockChain(g, ti)
 
 	// Close the database connection
 	bc.Database.Close()
 
+	if cli.isJSON() {
+		cli.printJSON(CreateOutput{a, hex.EncodeToString(bc.LatestHash), g.Network})
+		return
+	}
+
 	fmt.Println("New blockchain created!")
+	fmt.Printf("Genesis hash: %x\n", bc.LatestHash)
+	fmt.Printf("Network: %s\n", g.Network)
 }
 
-// getBalance returns the balance for a given address
-func (cli *CLI) getBalance(a string) {
+// getBalance returns the balance for a given address, counting pending outputs and outputs with fewer than mc
+// confirmations as unconfirmed
+func (cli *CLI) getBalance(a string, mc int) {
 	// Create the chain with ContinueBlockChain
 	bc := blockchain.ContinueBlockChain(a)
 
 	// Defer the closing of the chains database
 	defer bc.Database.Close()
 
-	// Holding variable for the balance
-	b := 0
+	// Get the balance for the address, split by whether it can be spent yet
+	b := bc.GetBalances(a, mc)
 
-	// Get the unspent transaction outputs for the address
-	uto := bc.GetUnspentTransactionOutputs(a)
+	// Add up what can be spent and the total, exiting if they are more than there could ever be
+	s, err := b.Spendable()
+	if err != nil {
+		cli.fail(err.Error())
+	}
 
-	// Loop through the unspent ouputs
-	for _, o := range uto {
-		// Add each outputs value to the balance
-		b += o.Value
+	t, err := b.Total()
+	if err != nil {
+		cli.fail(err.Error())
 	}
 
 	// Print out the balance
-	fmt.Printf("Balance for %s: %d\n", a, b)
+	if cli.isJSON() {
+		cli.printJSON(BalanceOutput{a, t, s, b.Immature, mc, b.Confirmed, b.Unconfirmed})
+		return
+	}
+
+	fmt.Printf("Balance for %s: %s\n", a, t.Format(cli.Decimals))
+	fmt.Printf("Spendable ==> %s\n", s.Format(cli.Decimals))
+	fmt.Printf("Confirmed ==> %s with at least %d confirmations\n", b.Confirmed.Format(cli.Decimals), mc)
+	fmt.Printf("Unconfirmed ==> %s with fewer or pending\n", b.Unconfirmed.Format(cli.Decimals))
+	fmt.Printf("Immature ==> %s\n", b.Immature.Format(cli.Decimals))
+}
+
+// listUnspent prints each unspent output of an address with at least mc confirmations
+func (cli *CLI) listUnspent(a string, mc int) {
+	// Create the chain with ContinueBlockChain
+	bc := blockchain.ContinueBlockChain(a)
+
+	// Defer the closing of the chains database
+	defer bc.Database.Close()
+
+	es := bc.ListUnspent(a, mc)
+
+	if cli.isJSON() {
+		// Always print a list, even if it is empty
+		if es == nil {
+			es = []blockchain.UnspentEntry{}
+		}
+
+		cli.printJSON(ListUnspentOutput{a, es})
+		return
+	}
+
+	// Print out each output, saying why it can't be spent if it can't
+	for _, e := range es {
+		fmt.Printf("%x:%d ==> %s with %d confirmations", e.TxID, e.Out, e.Value.Format(cli.Decimals), e.Confirmations)
+
+		if e.Spendable == false {
+			fmt.Print(", immature coinbase")
+		}
 
+		if e.Pending {
+			fmt.Print(", pending")
+		}
+
+		fmt.Println()
+	}
 }
 
-// send is a function to send an amount from one address to another
-func (cli *CLI) send(f, t string, a int) {
+// send is a function to send an amount from one address to another, cs names the coin selector to use and lt is
+// the lock time, 0 for none. The fee is paid on top of the amount, and a pending transaction is held until the
+// next block is mined rather than mined now, replaceable by bumpfee if asked. A transaction locked past the next
+// block is held pending until it is final.
+func (cli *CLI) send(f, t string, a blockchain.Amount, cs string, lt uint32, fee blockchain.Amount, p, r bool) {
 	// Create the blockchain with ContinueBlockChain and the from address
 	bc := blockchain.ContinueBlockChain(f)
 
 	// Defer the closing of the database
 	defer bc.Database.Close()
 
-	// Create a new transaction with the address, the amount and the chain
-	tx := blockchain.NewTransaction(f, t, a, bc)
+	// Outputs of key hash and script hash addresses can't be spent by naming the address
+	if blockchain.NeedsSignatures(f) {
+		cli.fail(fmt.Sprintf("Sending from %s needs signatures, use createpsbt", f))
+	}
+
+	// Create a new transaction with the address, the amount, the coin selector and the chain
+	tx := blockchain.NewTransactionWithFee(f, []blockchain.Recipient{{Address: t, Amount: a}}, fee, cli.coinSelector(cs), bc)
+
+	// Lock the transaction if asked, then signal it can be replaced if asked, which keeps the lock time applying
+	if lt != 0 {
+		tx.SetLockTime(lt)
+	}
+
+	if r {
+		tx.SetReplaceable()
+	}
+
+	// Add a pending transaction, or one that can't go in the next block, to the pool, exiting if it isn't valid
+	if p || bc.IsFinal(tx) == false {
+		err := bc.AcceptTransaction(tx)
+		if err != nil {
+			cli.fail(err.Error())
+		}
+
+		if cli.isJSON() {
+			cli.printJSON(PendingOutput{hex.EncodeToString(tx.ID), f, t, a, fee, tx.SignalsReplacement()})
+			return
+		}
+
+		fmt.Printf("Transaction %x from %s to %s for %s is pending\n", tx.ID, f, t, a.Format(cli.Decimals))
+		return
+	}
 
 	// Append the transaction to the chain
 	bc.AppendBlock([]*blockchain.Transaction{tx})
 
-	fmt.Printf("Successfully sent %d, from %s to %s\n", a, f, t)
+	if cli.isJSON() {
+		cli.printJSON(SendOutput{hex.EncodeToString(tx.ID), hex.EncodeToString(bc.LatestHash), f, t, a})
+		return
+	}
+
+	fmt.Printf("Successfully sent %s, from %s to %s\n", a.Format(cli.Decimals), f, t)
 }
 
-// Run is the function to run the CLI process
-func (cli *CLI) Run() {
-	// Make a call to validate the given arguments
-	cli.validateArgs()
+// sendMany is a function to send amounts from one address to several others in a single transaction,
+// cs names the coin selector to use
+func (cli *CLI) sendMany(f string, rs []blockchain.Recipient, cs string) {
+	// Create the blockchain with ContinueBlockChain and the from address
+	bc := blockchain.ContinueBlockChain(f)
 
-	// Set the flags for each option
-	getBalanceCmd := flag.NewFlagSet("getbalance", flag.ExitOnError)
-	createBlockchainCmd := flag.NewFlagSet("createblockchain", flag.ExitOnError)
-	sendCmd := flag.NewFlagSet("send", flag.ExitOnError)
-	printCmd := flag.NewFlagSet("print", flag.ExitOnError)
+	// Defer the closing of the database
+	defer bc.Database.Close()
 
-	// Extract the information for each command
-	getBalanceAddress := getBalanceCmd.String("address", "", "The address to get balance for")
-	createBlockchainAddress := createBlockchainCmd.String("address", "", "The address to send genesis block reward to")
-	sendFrom := sendCmd.String("from", "", "Source wallet address")
-	sendTo := sendCmd.String("to", "", "Destination wallet address")
-	sendAmount := sendCmd.Int("amount", 0, "Amount to send")
+	// Outputs of key hash and script hash addresses can't be spent by naming the address
+	if blockchain.NeedsSignatures(f) {
+		cli.fail(fmt.Sprintf("Sending from %s needs signatures, use createpsbt", f))
+	}
 
-	// Check which argument has been provided
-	switch os.Args[1] {
-	// For getbalance...
-	case "getbalance":
-		// Parse the arguemnts through addBlockCmd, handling any errors.
-		err := getBalanceCmd.Parse(os.Args[2:])
-		blockchain.HandleError(err)
+	// Create a new transaction paying each of the recipients
+	tx := blockchain.NewTransaction(f, rs, cli.coinSelector(cs), bc)
 
-	// For createblockchain...
-	case "createblockchain":
-		// Parse the arguemnts through printChainCmd, handling any errors.
-		err := createBlockchainCmd.Parse(os.Args[2:])
-		blockchain.HandleError(err)
+	// Append the transaction to the chain
+	bc.AppendBlock([]*blockchain.Transaction{tx})
 
-	// For send...
-	case "send":
-		// Parse the arguemnts through printChainCmd, handling any errors.
-		err := sendCmd.Parse(os.Args[2:])
-		blockchain.HandleError(err)
+	if cli.isJSON() {
+		cli.printJSON(SendManyOutput{hex.EncodeToString(tx.ID), hex.EncodeToString(bc.LatestHash), f, rs})
+		return
+	}
 
-	// For print...
-	case "print":
-		// Parse the arguemnts through printChainCmd, handling any errors.
-		err := printCmd.Parse(os.Args[2:])
-		blockchain.HandleError(err)
+	for _, r := range rs {
+		fmt.Printf("Successfully sent %s, from %s to %s\n", r.Amount.Format(cli.Decimals), f, r.Address)
+	}
+}
 
-	// In any other scenario...
-	default:
-		// Print the chain and exit
-		cli.printChain()
-		runtime.Goexit()
+// Returns the coin selector with the given name, exiting if there isn't one
+func (cli *CLI) coinSelector(n string) blockchain.CoinSelector {
+	cs, err := blockchain.NewCoinSelector(n)
+	if err != nil {
+		cli.fail(err.Error())
 	}
 
-	// If arguments have been parsed through getBalanceCmd do the following...
-	if getBalanceCmd.Parsed() {
+	return cs
+}
 
-		// Check if the address passed is a blank string, if so print the usage and exit
-		if *getBalanceAddress == "" {
-			getBalanceCmd.Usage()
-			runtime.Goexit()
+// Reads an amount with the CLI's decimal places, exiting if it isn't a positive amount
+func (cli *CLI) parseAmount(s string) blockchain.Amount {
+	a, err := blockchain.ParseAmount(s, cli.Decimals)
+	if err != nil {
+		cli.fail(err.Error())
+	}
+
+	if a == 0 {
+		cli.fail("Amount must be positive")
+	}
+
+	return a
+}
+
+// Parses recipients given as ADDRESS:AMOUNT pairs separated by commas, with amounts written with d decimal places
+func parseRecipients(s string, d int) ([]blockchain.Recipient, error) {
+	var rs []blockchain.Recipient
+
+	for _, p := range strings.Split(s, ",") {
+		// Split on the last colon so the amount is always what follows it
+		i := strings.LastIndex(p, ":")
+		if i <= 0 {
+			return nil, fmt.Errorf("%q is not ADDRESS:AMOUNT", p)
 		}
 
-		// Otherwise make a call to getBalance with the address
-		cli.getBalance(*getBalanceAddress)
+		a, err := blockchain.ParseAmount(p[i+1:], d)
+		if err != nil || a == 0 {
+			return nil, fmt.Errorf("%q does not have a positive amount", p)
+		}
+
+		rs = append(rs, blockchain.Recipient{Address: p[:i], Amount: a})
 	}
 
-	// If arguments have been parsed through createBlockchainCmd do the following...
-	if createBlockchainCmd.Parsed() {
+	return rs, nil
+}
 
-		// Check if the address passed is a blank string, if so print the usage and exit
-		if *createBlockchainAddress == "" {
-			createBlockchainCmd.Usage()
-			runtime.Goexit()
+// Reads recipients from a JSON file holding a list of {"address": ADDRESS, "amount": AMOUNT} payouts,
+// with amounts written with d decimal places
+func loadRecipients(f string, d int) ([]blockchain.Recipient, error) {
+	data, err := ioutil.ReadFile(f)
+	if err != nil {
+		return nil, err
+	}
+
+	// Keep the amounts as they were written so they can be read with the decimal places
+	var ps []struct {
+		Address string      `json:"address"`
+		Amount  json.Number `json:"amount"`
+	}
+
+	err = json.Unmarshal(data, &ps)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(ps) == 0 {
+		return nil, fmt.Errorf("%s holds no payouts", f)
+	}
+
+	var rs []blockchain.Recipient
+
+	for _, p := range ps {
+		a, err := blockchain.ParseAmount(p.Amount.String(), d)
+		if p.Address == "" || err != nil || a == 0 {
+			return nil, fmt.Errorf("payout to %q needs an address and a positive amount", p.Address)
 		}
 
-		// Otherwise make a call to getBalance with the address
-		cli.createBlockChain(*createBlockchainAddress)
+		rs = append(rs, blockchain.Recipient{Address: p.Address, Amount: a})
 	}
 
-	// If arguments have been parsed through sendCmd do the following...
-	if sendCmd.Parsed() {
-		// Check if any of the given address are blank, or if there i no amount
-		if *sendFrom == "" || *sendTo == "" || *sendAmount <= 0 {
-			sendCmd.Usage()
-			runtime.Goexit()
+	return rs, nil
+}
+
+// getTransaction prints the transaction with the given hex ID
+func (cli *CLI) getTransaction(id string) {
+	// Decode the ID, exiting if it isn't valid hex
+	tID, err := hex.DecodeString(id)
+	if err != nil {
+		cli.fail("Transaction ID must be hex encoded")
+	}
+
+	// Create the chain with ContinueBlockChain and a blank address
+	bc := blockchain.ContinueBlockChain("")
+
+	// Defer the closing of the chain's database
+	defer bc.Database.Close()
+
+	// Find the transaction, exiting if it doesn't exist
+	t, l, err := bc.FindTransaction(tID)
+	if err == blockchain.ErrTxNotFound {
+		cli.fail(fmt.Sprintf("No transaction found with ID %s", id))
+	}
+	blockchain.HandleError(err)
+
+	if cli.isJSON() {
+		cli.printJSON(TransactionOutput{t, hex.EncodeToString(l.BlockHash), l.Height, bc.Confirmations(l)})
+		return
+	}
+
+	// Print out where the transaction lives
+	fmt.Printf("Transaction ==> %x\n", t.ID)
+	fmt.Printf("Block ==> %x\n", l.BlockHash)
+	fmt.Printf("Height ==> %d\n", l.Height)
+	fmt.Printf("Confirmations ==> %d\n", bc.Confirmations(l))
+
+	if t.LockTime != 0 {
+		fmt.Printf("Locked until ==> %s\n", blockchain.DescribeLockTime(t.LockTime))
+	}
+
+	// Print out each of the inputs
+	for i, in := range t.Inputs {
+		fmt.Printf("Input %d ==> %x:%d from %s sequence %d\n", i, in.ID, in.Out, in.Sig, in.Sequence)
+
+		if len(in.ScriptSig) != 0 {
+			fmt.Printf("        script sig ==> %s\n", in.ScriptSig)
 		}
+	}
 
-		// Otherwise make a call to send with the details
-		cli.send(*sendFrom, *sendTo, *sendAmount)
+	// Print out each of the outputs
+	for i, o := range t.Outputs {
+		fmt.Printf("Output %d ==> %s to %s\n", i, o.Value.Format(cli.Decimals), o.PubKey)
+
+		if len(o.ScriptPubKey) != 0 {
+			fmt.Printf("         locked by ==> %s\n", o.ScriptPubKey)
+		}
 	}
+}
 
-	// If arguments have been parsed through printCmd do the following...
-	if printCmd.Parsed() {
-		// Make a call to printChain
-		cli.printChain()
+// bumpFee replaces a pending transaction that signalled it is replaceable with one paying a higher fee, taken out
+// of the change it pays back. A transaction spending from a key hash address is signed again with the key file.
+func (cli *CLI) bumpFee(id string, fee blockchain.Amount, kf string) {
+	// Decode the ID, exiting if it isn't valid hex
+	tID, err := hex.DecodeString(id)
+	if err != nil {
+		cli.fail("Transaction ID must be hex encoded")
+	}
+
+	// Create the chain with ContinueBlockChain and a blank address
+	bc := blockchain.ContinueBlockChain("")
+
+	// Defer the closing of the chain's database
+	defer bc.Database.Close()
+
+	// Find the pending transaction, explaining why it can't be replaced if it has already been mined
+	t, err := bc.PendingTransaction(tID)
+	if err != nil {
+		if _, l, ferr := bc.FindTransaction(tID); ferr == nil {
+			cli.fail(fmt.Sprintf("Transaction %s is already in block %d with %d confirmations so it can't be replaced", id, l.Height, bc.Confirmations(l)))
+		}
+
+		cli.fail(fmt.Sprintf("No pending transaction found with ID %s", id))
+	}
+
+	of, err := bc.PendingFee(t)
+	blockchain.HandleError(err)
+
+	// Build the replacement, exiting if it can't pay the fee
+	p, err := bc.BumpFee(tID, fee)
+	if err != nil {
+		cli.fail(err.Error())
+	}
+
+	// Sign it again if it needs signatures, then fill in its script sigs
+	if kf != "" {
+		_, err = p.Sign(cli.loadKey(kf))
+		if err != nil {
+			cli.fail(err.Error())
+		}
+	}
+
+	tx, err := p.Finalise()
+	if err != nil {
+		cli.fail(err.Error())
+	}
+
+	// Put it in the original's place
+	err = bc.AcceptTransaction(tx)
+	if err != nil {
+		cli.fail(err.Error())
+	}
+
+	if cli.isJSON() {
+		cli.printJSON(BumpFeeOutput{id, hex.EncodeToString(tx.ID), of, fee})
+		return
+	}
+
+	fmt.Printf("Replaced transaction %s paying a fee of %s with %x paying %s\n", id, of.Format(cli.Decimals), tx.ID, fee.Format(cli.Decimals))
+}
+
+// history prints a page of the transactions that credited or debited an address
+func (cli *CLI) history(a, c string, l int) {
+	// Create the chain with ContinueBlockChain and the address
+	bc := blockchain.ContinueBlockChain(a)
+
+	// Defer the closing of the chain's database
+	defer bc.Database.Close()
+
+	// Get the page of history, exiting if the cursor isn't valid
+	es, nc, err := bc.AddressHistory(a, c, l)
+	if err != nil {
+		cli.fail("Cursor is not valid")
+	}
+
+	if cli.isJSON() {
+		// Always print a list, even if it is empty
+		if es == nil {
+			es = []blockchain.HistoryEntry{}
+		}
+
+		cli.printJSON(HistoryOutput{a, es, nc})
+		return
+	}
+
+	// Print out each entry
+	for _, e := range es {
+		fmt.Printf("Height %d ==> %x received %s sent %s\n", e.Height, e.TxID, e.Received.Format(cli.Decimals), e.Sent.Format(cli.Decimals))
+	}
+
+	// If there are more entries print the cursor to fetch them with
+	if nc != "" {
+		fmt.Printf("Next cursor ==> %s\n", nc)
+	}
+}
+
+// serve serves the chain over JSON-RPC on the given port until the process is stopped, saving the key files of
+// wallets created over RPC in the wallet directory and snapshots taken over RPC in the backup directory
+func (cli *CLI) serve(port int, u, p, wd, bd string) {
+	// Create the chain with ContinueBlockChain and a blank address
+	bc := blockchain.ContinueBlockChain("")
+
+	// Defer the closing of the chain's database
+	defer bc.Database.Close()
+
+	// Close the database cleanly if the process is stopped, otherwise it can't be opened read only
+	cli.closeOnSignal(bc)
+
+	// Create the server and serve requests, handling any errors
+	s := rpc.NewServer(bc, u, p, wd, bd)
+
+	if cli.isJSON() {
+		cli.printJSON(ServeOutput{"jsonrpc", port})
+	} else {
+		fmt.Printf("Serving JSON-RPC on port %d\n", port)
+	}
+
+	err := s.ListenAndServe(fmt.Sprintf(":%d", port))
+	blockchain.HandleError(err)
+}
+
+// closeOnSignal closes the chain's database and exits when the process is interrupted or terminated
+func (cli *CLI) closeOnSignal(bc *blockchain.BlockChain) {
+	// Create a channel to be notified of the signals on
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
+
+	// Wait for a signal in the background, then close the database and exit
+	go func() {
+		<-c
+
+		bc.Database.Close()
+		os.Exit(0)
+	}()
+}
+
+// explore serves the read only REST API on the given port until the process is stopped
+func (cli *CLI) explore(port int) {
+	// Open the chain read only
+	bc := blockchain.ContinueBlockChainReadOnly()
+
+	// Defer the closing of the chain's database
+	defer bc.Database.Close()
+
+	// Close the database if the process is stopped
+	cli.closeOnSignal(bc)
+
+	// Create the explorer and serve requests, handling any errors
+	e := explorer.New(bc)
+
+	if cli.isJSON() {
+		cli.printJSON(ServeOutput{"explorer", port})
+	} else {
+		fmt.Printf("Serving explorer on port %d\n", port)
+	}
+
+	err := e.ListenAndServe(fmt.Sprintf(":%d", port))
+	blockchain.HandleError(err)
+}
+
+// callRPC calls a method on a running node and prints the result
+func (cli *CLI) callRPC(host string, port int, u, p, m, params string) {
+	// Create the client for the node
+	c := rpc.NewClient(fmt.Sprintf("http://%s:%d", host, port), u, p)
+
+	// Use no params if none were given
+	var rp json.RawMessage
+	if params != "" {
+		rp = json.RawMessage(params)
+	}
+
+	// Call the method, printing any error and exiting
+	res, err := c.Call(m, rp)
+	if err != nil {
+		cli.fail(err.Error())
+	}
+
+	// Print out the result
+	fmt.Println(string(res))
+}
+
+// exportChain writes every block in the chain to a file
+func (cli *CLI) exportChain(f string) {
+	// Create the chain with ContinueBlockChain and a blank address
+	bc := blockchain.ContinueBlockChain("")
+
+	// Defer the closing of the chain's database
+	defer bc.Database.Close()
+
+	// Create the file, handling any errors
+	file, err := os.Create(f)
+	blockchain.HandleError(err)
+
+	defer file.Close()
+
+	// Write the chain to the file, handling any errors
+	n, err := bc.Export(file)
+	blockchain.HandleError(err)
+
+	if cli.isJSON() {
+		cli.printJSON(ExportOutput{f, n, hex.EncodeToString(bc.LatestHash)})
+		return
+	}
+
+	fmt.Printf("Exported %d blocks to %s\n", n, f)
+}
+
+// generate mines a number of blocks paying the rewards to an address, only regtest chains can generate blocks
+func (cli *CLI) generate(a string, n int) {
+	// Create the chain with ContinueBlockChain
+	bc := blockchain.ContinueBlockChain(a)
+
+	// Defer the closing of the chain's database
+	defer bc.Database.Close()
+
+	// Mine the blocks, exiting if any of them can't be added
+	hs, err := bc.Generate(a, n)
+	if err != nil {
+		cli.fail(fmt.Sprintf("Generate failed: %s", err))
+	}
+
+	if cli.isJSON() {
+		gen := GenerateOutput{a, []string{}, bc.Height()}
+		for _, h := range hs {
+			gen.Blocks = append(gen.Blocks, hex.EncodeToString(h))
+		}
+
+		cli.printJSON(gen)
+		return
+	}
+
+	fmt.Printf("Generated %d blocks for %s, height is now %d\n", len(hs), a, bc.Height())
+}
+
+// importChain creates a new chain from a file written by exportChain
+func (cli *CLI) importChain(f string, ti bool) {
+	// Open the file, exiting if it can't be read
+	file, err := os.Open(f)
+	if err != nil {
+		cli.fail(err.Error())
+	}
+
+	defer file.Close()
+
+	// Import the chain, exiting if the file isn't valid
+	bc, err := blockchain.ImportBlockChain(file, ti)
+	if err != nil {
+		cli.fail(fmt.Sprintf("Import failed: %s", err))
+	}
+
+	// Close the database connection
+	n := bc.Height() + 1
+	bc.Database.Close()
+
+	if cli.isJSON() {
+		cli.printJSON(ExportOutput{f, n, hex.EncodeToString(bc.LatestHash)})
+		return
+	}
+
+	fmt.Printf("Imported %d blocks from %s\n", n, f)
+}
+
+// snapshot backs up the database to a file
+func (cli *CLI) snapshot(f string) {
+	// Create the chain with ContinueBlockChain and a blank address
+	bc := blockchain.ContinueBlockChain("")
+
+	// Defer the closing of the chain's database
+	defer bc.Database.Close()
+
+	// Create the file, handling any errors
+	file, err := os.Create(f)
+	blockchain.HandleError(err)
+
+	defer file.Close()
+
+	// Write the snapshot to the file, handling any errors
+	err = bc.Snapshot(file)
+	blockchain.HandleError(err)
+
+	if cli.isJSON() {
+		cli.printJSON(rpc.SnapshotResult{File: f, LatestHash: hex.EncodeToString(bc.LatestHash), Height: bc.Height()})
+		return
+	}
+
+	fmt.Printf("Snapshot of %d blocks written to %s\n", bc.Height()+1, f)
+}
+
+// snapshotRPC asks a running node to back up its database to a file with the given name in its backup directory
+func (cli *CLI) snapshotRPC(host string, port int, u, p, f string) {
+	// Create the client for the node
+	c := rpc.NewClient(fmt.Sprintf("http://%s:%d", host, port), u, p)
+
+	// Encode the params, handling any errors
+	params, err := json.Marshal(map[string]string{"file": f})
+	blockchain.HandleError(err)
+
+	// Call snapshot, printing any error and exiting
+	res, err := c.Call("snapshot", params)
+	if err != nil {
+		cli.fail(err.Error())
+	}
+
+	// Decode the result, handling any errors
+	var sr rpc.SnapshotResult
+
+	err = json.Unmarshal(res, &sr)
+	blockchain.HandleError(err)
+
+	if cli.isJSON() {
+		cli.printJSON(sr)
+		return
+	}
+
+	fmt.Printf("Snapshot of %d blocks written to %s by the node\n", sr.Height+1, sr.File)
+}
+
+// restore creates the database from a snapshot
+func (cli *CLI) restore(f string) {
+	// Open the file, exiting if it can't be read
+	file, err := os.Open(f)
+	if err != nil {
+		cli.fail(err.Error())
+	}
+
+	defer file.Close()
+
+	// Restore the chain, exiting if the snapshot isn't valid
+	bc, err := blockchain.RestoreBlockChain(file)
+	if err != nil {
+		cli.fail(fmt.Sprintf("Restore failed: %s", err))
+	}
+
+	// Close the database connection
+	h := bc.Height()
+	bc.Database.Close()
+
+	if cli.isJSON() {
+		cli.printJSON(rpc.SnapshotResult{File: f, LatestHash: hex.EncodeToString(bc.LatestHash), Height: h})
+		return
+	}
+
+	fmt.Printf("Restored %d blocks from %s\n", h+1, f)
+}
+
+// migrate re-encodes a chain made with the old block encoding
+func (cli *CLI) migrate() {
+	// Migrate the chain, which prints its own message if there is nothing to do
+	n, ids := blockchain.MigrateBlockChain()
+
+	if cli.isJSON() {
+		cli.printJSON(MigrateOutput{n, ids})
+		return
+	}
+
+	// If nothing was migrated there is nothing more to print
+	if n == 0 {
+		return
+	}
+
+	// Print out the number of blocks and each transaction's new ID, sorted by old ID
+	fmt.Printf("Migrated %d blocks\n", n)
+
+	var olds []string
+	for o := range ids {
+		olds = append(olds, o)
+	}
+
+	sort.Strings(olds)
+
+	for _, o := range olds {
+		fmt.Printf("Transaction %s ==> %s\n", o, ids[o])
+	}
+}
+
+// reindex rebuilds the indexes for the chain
+func (cli *CLI) reindex(ti bool) {
+	// Create the chain with ContinueBlockChain and a blank address
+	bc := blockchain.ContinueBlockChain("")
+
+	// Defer the closing of the chain's database
+	defer bc.Database.Close()
+
+	// Set the transaction index option and rebuild
+	bc.TxIndex = ti
+	bc.Reindex()
+
+	if cli.isJSON() {
+		cli.printJSON(ReindexOutput{ti})
+		return
+	}
+
+	fmt.Println("Indexes rebuilt!")
+}
+
+// Run is the function to run the CLI process
+func (cli *CLI) Run() {
+	// Parse the global flags which come before the command, handling any errors
+	globalCmd := flag.NewFlagSet("global", flag.ExitOnError)
+	output := globalCmd.String("output", textOutput, "The format to print in, text or json")
+	decimals := globalCmd.Int("decimals", 0, "The number of decimal places amounts are read and printed with")
+	dataDir := globalCmd.String("datadir", blockchain.DataDir, "The directory the chain's database is kept in")
+
+	err := globalCmd.Parse(os.Args[1:])
+	blockchain.HandleError(err)
+
+	cli.setOutput(*output)
+	cli.setDecimals(*decimals)
+
+	// Use the chain in the data directory
+	blockchain.DataDir = *dataDir
+
+	// Print any panic from here on as an ErrorOutput in JSON mode
+	defer cli.recoverJSON()
+
+	// Make a call to validate the arguments left after the global flags
+	args := globalCmd.Args()
+	cli.validateArgs(args)
+
+	// Set the flags for each option
+	getBalanceCmd := flag.NewFlagSet("getbalance", flag.ExitOnError)
+	listUnspentCmd := flag.NewFlagSet("listunspent", flag.ExitOnError)
+	createBlockchainCmd := flag.NewFlagSet("createblockchain", flag.ExitOnError)
+	sendCmd := flag.NewFlagSet("send", flag.ExitOnError)
+	printCmd := flag.NewFlagSet("print", flag.ExitOnError)
+	getTransactionCmd := flag.NewFlagSet("gettransaction", flag.ExitOnError)
+	bumpFeeCmd := flag.NewFlagSet("bumpfee", flag.ExitOnError)
+	reindexCmd := flag.NewFlagSet("reindex", flag.ExitOnError)
+	historyCmd := flag.NewFlagSet("history", flag.ExitOnError)
+	serveCmd := flag.NewFlagSet("serve", flag.ExitOnError)
+	rpcCmd := flag.NewFlagSet("rpc", flag.ExitOnError)
+	explorerCmd := flag.NewFlagSet("explorer", flag.ExitOnError)
+	migrateCmd := flag.NewFlagSet("migrate", flag.ExitOnError)
+	exportChainCmd := flag.NewFlagSet("exportchain", flag.ExitOnError)
+	importChainCmd := flag.NewFlagSet("importchain", flag.ExitOnError)
+	snapshotCmd := flag.NewFlagSet("snapshot", flag.ExitOnError)
+	restoreCmd := flag.NewFlagSet("restore", flag.ExitOnError)
+	generateCmd := flag.NewFlagSet("generate", flag.ExitOnError)
+	sendManyCmd := flag.NewFlagSet("sendmany", flag.ExitOnError)
+	createKeyCmd := flag.NewFlagSet("createkey", flag.ExitOnError)
+	createMultiSigCmd := flag.NewFlagSet("createmultisig", flag.ExitOnError)
+	createPSBTCmd := flag.NewFlagSet("createpsbt", flag.ExitOnError)
+	signPSBTCmd := flag.NewFlagSet("signpsbt", flag.ExitOnError)
+	combinePSBTCmd := flag.NewFlagSet("combinepsbt", flag.ExitOnError)
+	finalisePSBTCmd := flag.NewFlagSet("finalizepsbt", flag.ExitOnError)
+	broadcastCmd := flag.NewFlagSet("broadcast", flag.ExitOnError)
+	initiateSwapCmd := flag.NewFlagSet("initiateswap", flag.ExitOnError)
+	auditSwapCmd := flag.NewFlagSet("auditswap", flag.ExitOnError)
+	redeemSwapCmd := flag.NewFlagSet("redeemswap", flag.ExitOnError)
+	refundSwapCmd := flag.NewFlagSet("refundswap", flag.ExitOnError)
+	extractSecretCmd := flag.NewFlagSet("extractsecret", flag.ExitOnError)
+	openChannelCmd := flag.NewFlagSet("openchannel", flag.ExitOnError)
+	payChannelCmd := flag.NewFlagSet("paychannel", flag.ExitOnError)
+	channelInfoCmd := flag.NewFlagSet("channelinfo", flag.ExitOnError)
+	closeChannelCmd := flag.NewFlagSet("closechannel", flag.ExitOnError)
+	refundChannelCmd := flag.NewFlagSet("refundchannel", flag.ExitOnError)
+
+	// Extract the information for each command
+	getBalanceAddress := getBalanceCmd.String("address", "", "The address to get balance for")
+	getBalanceMinConf := getBalanceCmd.Int("minconf", 1, "The confirmations outputs need to count as confirmed")
+	listUnspentAddress := listUnspentCmd.String("address", "", "The address to list unspent outputs for")
+	listUnspentMinConf := listUnspentCmd.Int("minconf", 1, "The confirmations outputs need to be listed")
+	createBlockchainAddress := createBlockchainCmd.String("address", "", "The address to send genesis block reward to")
+	createBlockchainTxIndex := createBlockchainCmd.Bool("txindex", true, "Index transactions by ID")
+	createBlockchainGenesis := createBlockchainCmd.String("genesis", "", "A genesis JSON file to create the initial block from")
+	createBlockchainRegtest := createBlockchainCmd.Bool("regtest", false, "Create a regtest chain with the lowest difficulty")
+	createBlockchainDustLimit := createBlockchainCmd.String("dustlimit", "", "Outputs worth less than this are dust and are rejected")
+	createBlockchainMaturity := createBlockchainCmd.Int("coinbasematurity", -1, "The confirmations coinbase outputs need before they can be spent, 100 by default or 0 on regtest")
+	createBlockchainDeterministic := createBlockchainCmd.Bool("deterministic", false, "Timestamp each regtest block one second after the last")
+	generateBlocks := generateCmd.Int("blocks", 0, "The number of blocks to mine")
+	generateAddress := generateCmd.String("address", "", "The address to send the block rewards to")
+	sendFrom := sendCmd.String("from", "", "Source wallet address")
+	sendTo := sendCmd.String("to", "", "Destination wallet address")
+	sendAmount := sendCmd.String("amount", "", "Amount to send")
+	sendLockTime := sendCmd.Uint("locktime", 0, "A block height, or unix time from 500000000, before which the transaction can't be added")
+	sendFee := sendCmd.String("fee", "", "The fee to pay on top of the amount")
+	sendPending := sendCmd.Bool("pending", false, "Hold the transaction until the next block is mined")
+	sendReplaceable := sendCmd.Bool("replaceable", false, "Let bumpfee replace the transaction while it is pending")
+	sendCoinSelect := sendCmd.String("coinselect", blockchain.LargestFirstSelector, "How to pick the outputs to spend, largest, smallest, bnb or random")
+	sendManyFrom := sendManyCmd.String("from", "", "Source wallet address")
+	sendManyTo := sendManyCmd.String("to", "", "Recipients as ADDRESS:AMOUNT pairs separated by commas")
+	sendManyCoinSelect := sendManyCmd.String("coinselect", blockchain.LargestFirstSelector, "How to pick the outputs to spend, largest, smallest, bnb or random")
+	sendManyFile := sendManyCmd.String("file", "", "A JSON file of payouts, [{\"address\": ADDRESS, \"amount\": AMOUNT}, ...]")
+	createKeyFile := createKeyCmd.String("file", "", "The file to save the key to")
+	createMultiSigRequired := createMultiSigCmd.Int("required", 0, "The number of signatures needed to spend")
+	createMultiSigKeys := createMultiSigCmd.String("keys", "", "The hex public keys that can sign, separated by commas")
+	createPSBTFrom := createPSBTCmd.String("from", "", "Source wallet address")
+	createPSBTTo := createPSBTCmd.String("to", "", "Destination wallet address")
+	createPSBTAmount := createPSBTCmd.String("amount", "", "Amount to send")
+	createPSBTOut := createPSBTCmd.String("out", "", "The file to write the partially signed transaction to")
+	createPSBTRedeemScript := createPSBTCmd.String("redeemscript", "", "The hex script of the script hash address to send from")
+	createPSBTCoinSelect := createPSBTCmd.String("coinselect", blockchain.LargestFirstSelector, "How to pick the outputs to spend, largest, smallest, bnb or random")
+	createPSBTReplaceable := createPSBTCmd.Bool("replaceable", false, "Let bumpfee replace the transaction while it is pending")
+	createPSBTLockTime := createPSBTCmd.Uint("locktime", 0, "A block height, or unix time from 500000000, before which the transaction can't be added")
+	signPSBTIn := signPSBTCmd.String("in", "", "The file holding the partially signed transaction")
+	signPSBTKey := signPSBTCmd.String("key", "", "The key file to sign with")
+	signPSBTOut := signPSBTCmd.String("out", "", "The file to write the signed transaction to, the input file if not given")
+	combinePSBTIn := combinePSBTCmd.String("in", "", "The files holding the partially signed transactions, separated by commas")
+	combinePSBTOut := combinePSBTCmd.String("out", "", "The file to write the combined transaction to")
+	finalisePSBTIn := finalisePSBTCmd.String("in", "", "The file holding the signed transaction")
+	finalisePSBTOut := finalisePSBTCmd.String("out", "", "A file to write the finalised transaction to in hex")
+	broadcastTx := broadcastCmd.String("tx", "", "The finalised transaction in hex")
+	broadcastFile := broadcastCmd.String("file", "", "A file holding the finalised transaction in hex")
+	broadcastPending := broadcastCmd.Bool("pending", false, "Hold the transaction until the next block is mined")
+	initiateSwapFrom := initiateSwapCmd.String("from", "", "Source wallet address")
+	initiateSwapAmount := initiateSwapCmd.String("amount", "", "Amount to lock in the contract")
+	initiateSwapRecipient := initiateSwapCmd.String("recipient", "", "The hex public key that can redeem the contract with the secret")
+	initiateSwapKey := initiateSwapCmd.String("key", "", "The key file of the key the contract is refunded to, which signs for a key hash from address")
+	initiateSwapLockTime := initiateSwapCmd.Uint("locktime", 0, "A block height, or unix time from 500000000, from which the contract can be refunded")
+	initiateSwapSecretHash := initiateSwapCmd.String("secrethash", "", "The hex sha256 hash of the other side's secret, a new secret is made if not given")
+	auditSwapContract := auditSwapCmd.String("contract", "", "The hex script of the contract")
+	redeemSwapContract := redeemSwapCmd.String("contract", "", "The hex script of the contract")
+	redeemSwapSecret := redeemSwapCmd.String("secret", "", "The hex secret the contract is locked with")
+	redeemSwapKey := redeemSwapCmd.String("key", "", "The key file of the contract's recipient")
+	redeemSwapTo := redeemSwapCmd.String("to", "", "Destination wallet address")
+	refundSwapContract := refundSwapCmd.String("contract", "", "The hex script of the contract")
+	refundSwapKey := refundSwapCmd.String("key", "", "The key file of the contract's sender")
+	refundSwapTo := refundSwapCmd.String("to", "", "Destination wallet address")
+	extractSecretContract := extractSecretCmd.String("contract", "", "The hex script of the contract")
+	extractSecretTxID := extractSecretCmd.String("txid", "", "The hex ID of the transaction redeeming the contract, the chain is searched if not given")
+	openChannelFrom := openChannelCmd.String("from", "", "Source wallet address")
+	openChannelAmount := openChannelCmd.String("amount", "", "Amount to lock in the channel")
+	openChannelPayee := openChannelCmd.String("payee", "", "The hex public key of who is paid through the channel")
+	openChannelKey := openChannelCmd.String("key", "", "The payer's key file, which signs for a key hash from address")
+	openChannelLockTime := openChannelCmd.Uint("locktime", 0, "A block height, or unix time from 500000000, from which the payer can take the channel back")
+	openChannelOut := openChannelCmd.String("out", "", "The file to write the channel to")
+	payChannelFile := payChannelCmd.String("channel", "", "The channel file")
+	payChannelAmount := payChannelCmd.String("amount", "", "Amount to pay")
+	payChannelKey := payChannelCmd.String("key", "", "The payer's key file")
+	channelInfoFile := channelInfoCmd.String("channel", "", "The channel file")
+	closeChannelFile := closeChannelCmd.String("channel", "", "The channel file")
+	closeChannelKey := closeChannelCmd.String("key", "", "The payee's key file")
+	refundChannelFile := refundChannelCmd.String("channel", "", "The channel file")
+	refundChannelKey := refundChannelCmd.String("key", "", "The payer's key file")
+	refundChannelTo := refundChannelCmd.String("to", "", "Destination wallet address, the payer's key hash address if not given")
+	getTransactionID := getTransactionCmd.String("id", "", "The hex ID of the transaction")
+	bumpFeeTxID := bumpFeeCmd.String("txid", "", "The hex ID of the transaction to replace")
+	bumpFeeFee := bumpFeeCmd.String("fee", "", "The whole fee the replacement pays, more than the transaction pays")
+	bumpFeeKey := bumpFeeCmd.String("key", "", "The key file to sign the replacement with, when it spends from a key hash address")
+	reindexTxIndex := reindexCmd.Bool("txindex", true, "Index transactions by ID")
+	historyAddress := historyCmd.String("address", "", "The address to list transactions for")
+	historyCursor := historyCmd.String("cursor", "", "The cursor returned by the previous page")
+	historyLimit := historyCmd.Int("limit", 20, "The number of transactions per page")
+	servePort := serveCmd.Int("rpcport", 8332, "The port to serve JSON-RPC on")
+	serveUser := serveCmd.String("rpcuser", "", "The basic auth username clients must use")
+	servePassword := serveCmd.String("rpcpassword", "", "The basic auth password clients must use")
+	serveWalletDir := serveCmd.String("walletdir", "wallets", "The directory createwallet saves key files in")
+	serveBackupDir := serveCmd.String("backupdir", "backups", "The directory snapshots taken over RPC are written to")
+	rpcHost := rpcCmd.String("rpchost", "localhost", "The host of the node to call")
+	rpcPort := rpcCmd.Int("rpcport", 8332, "The JSON-RPC port of the node to call")
+	rpcUser := rpcCmd.String("rpcuser", "", "The basic auth username for the node")
+	rpcPassword := rpcCmd.String("rpcpassword", "", "The basic auth password for the node")
+	rpcMethod := rpcCmd.String("method", "", "The method to call")
+	rpcParams := rpcCmd.String("params", "", "The params to call the method with as a JSON object")
+	explorerPort := explorerCmd.Int("port", 8080, "The port to serve the explorer on")
+	exportChainFile := exportChainCmd.String("file", "", "The file to export the chain to")
+	importChainFile := importChainCmd.String("file", "", "The file to import the chain from")
+	importChainTxIndex := importChainCmd.Bool("txindex", true, "Index transactions by ID")
+	snapshotOut := snapshotCmd.String("out", "", "The file to write the snapshot to")
+	snapshotHost := snapshotCmd.String("rpchost", "localhost", "The host of a running node to take the snapshot")
+	snapshotPort := snapshotCmd.Int("rpcport", 8332, "The JSON-RPC port of a running node to take the snapshot")
+	snapshotUser := snapshotCmd.String("rpcuser", "", "The basic auth username for a running node")
+	snapshotPassword := snapshotCmd.String("rpcpassword", "", "The basic auth password for a running node")
+	restoreIn := restoreCmd.String("in", "", "The snapshot file to restore from")
+
+	// Check which argument has been provided
+	switch args[0] {
+	// For getbalance...
+	case "getbalance":
+		// Parse the arguemnts through addBlockCmd, handling any errors.
+		err = getBalanceCmd.Parse(args[1:])
+		blockchain.HandleError(err)
+
+	// For listunspent...
+	case "listunspent":
+		// Parse the arguemnts through listUnspentCmd, handling any errors.
+		err = listUnspentCmd.Parse(args[1:])
+		blockchain.HandleError(err)
+
+	// For createblockchain...
+	case "createblockchain":
+		// Parse the arguemnts through printChainCmd, handling any errors.
+		err = createBlockchainCmd.Parse(args[1:])
+		blockchain.HandleError(err)
+
+	// For send...
+	case "send":
+		// Parse the arguemnts through printChainCmd, handling any errors.
+		err = sendCmd.Parse(args[1:])
+		blockchain.HandleError(err)
+
+	// For sendmany...
+	case "sendmany":
+		// Parse the arguemnts through sendManyCmd, handling any errors.
+		err = sendManyCmd.Parse(args[1:])
+		blockchain.HandleError(err)
+
+	// For createkey...
+	case "createkey":
+		// Parse the arguemnts through createKeyCmd, handling any errors.
+		err = createKeyCmd.Parse(args[1:])
+		blockchain.HandleError(err)
+
+	// For createmultisig...
+	case "createmultisig":
+		// Parse the arguemnts through createMultiSigCmd, handling any errors.
+		err = createMultiSigCmd.Parse(args[1:])
+		blockchain.HandleError(err)
+
+	// For createpsbt...
+	case "createpsbt":
+		// Parse the arguemnts through createPSBTCmd, handling any errors.
+		err = createPSBTCmd.Parse(args[1:])
+		blockchain.HandleError(err)
+
+	// For signpsbt...
+	case "signpsbt":
+		// Parse the arguemnts through signPSBTCmd, handling any errors.
+		err = signPSBTCmd.Parse(args[1:])
+		blockchain.HandleError(err)
+
+	// For combinepsbt...
+	case "combinepsbt":
+		// Parse the arguemnts through combinePSBTCmd, handling any errors.
+		err = combinePSBTCmd.Parse(args[1:])
+		blockchain.HandleError(err)
+
+	// For finalizepsbt...
+	case "finalizepsbt":
+		// Parse the arguemnts through finalisePSBTCmd, handling any errors.
+		err = finalisePSBTCmd.Parse(args[1:])
+		blockchain.HandleError(err)
+
+	// For broadcast...
+	case "broadcast":
+		// Parse the arguemnts through broadcastCmd, handling any errors.
+		err = broadcastCmd.Parse(args[1:])
+		blockchain.HandleError(err)
+
+	// For initiateswap...
+	case "initiateswap":
+		// Parse the arguemnts through initiateSwapCmd, handling any errors.
+		err = initiateSwapCmd.Parse(args[1:])
+		blockchain.HandleError(err)
+
+	// For auditswap...
+	case "auditswap":
+		// Parse the arguemnts through auditSwapCmd, handling any errors.
+		err = auditSwapCmd.Parse(args[1:])
+		blockchain.HandleError(err)
+
+	// For redeemswap...
+	case "redeemswap":
+		// Parse the arguemnts through redeemSwapCmd, handling any errors.
+		err = redeemSwapCmd.Parse(args[1:])
+		blockchain.HandleError(err)
+
+	// For refundswap...
+	case "refundswap":
+		// Parse the arguemnts through refundSwapCmd, handling any errors.
+		err = refundSwapCmd.Parse(args[1:])
+		blockchain.HandleError(err)
+
+	// For extractsecret...
+	case "extractsecret":
+		// Parse the arguemnts through extractSecretCmd, handling any errors.
+		err = extractSecretCmd.Parse(args[1:])
+		blockchain.HandleError(err)
+
+	// For openchannel...
+	case "openchannel":
+		// Parse the arguemnts through openChannelCmd, handling any errors.
+		err = openChannelCmd.Parse(args[1:])
+		blockchain.HandleError(err)
+
+	// For paychannel...
+	case "paychannel":
+		// Parse the arguemnts through payChannelCmd, handling any errors.
+		err = payChannelCmd.Parse(args[1:])
+		blockchain.HandleError(err)
+
+	// For channelinfo...
+	case "channelinfo":
+		// Parse the arguemnts through channelInfoCmd, handling any errors.
+		err = channelInfoCmd.Parse(args[1:])
+		blockchain.HandleError(err)
+
+	// For closechannel...
+	case "closechannel":
+		// Parse the arguemnts through closeChannelCmd, handling any errors.
+		err = closeChannelCmd.Parse(args[1:])
+		blockchain.HandleError(err)
+
+	// For refundchannel...
+	case "refundchannel":
+		// Parse the arguemnts through refundChannelCmd, handling any errors.
+		err = refundChannelCmd.Parse(args[1:])
+		blockchain.HandleError(err)
+
+	// For print...
+	case "print":
+		// Parse the arguemnts through printChainCmd, handling any errors.
+		err = printCmd.Parse(args[1:])
+		blockchain.HandleError(err)
+
+	// For gettransaction...
+	case "gettransaction":
+		// Parse the arguemnts through getTransactionCmd, handling any errors.
+		err = getTransactionCmd.Parse(args[1:])
+		blockchain.HandleError(err)
+
+	// For bumpfee...
+	case "bumpfee":
+		// Parse the arguemnts through bumpFeeCmd, handling any errors.
+		err = bumpFeeCmd.Parse(args[1:])
+		blockchain.HandleError(err)
+
+	// For reindex...
+	case "reindex":
+		// Parse the arguemnts through reindexCmd, handling any errors.
+		err = reindexCmd.Parse(args[1:])
+		blockchain.HandleError(err)
+
+	// For history...
+	case "history":
+		// Parse the arguemnts through historyCmd, handling any errors.
+		err = historyCmd.Parse(args[1:])
+		blockchain.HandleError(err)
+
+	// For serve...
+	case "serve":
+		// Parse the arguemnts through serveCmd, handling any errors.
+		err = serveCmd.Parse(args[1:])
+		blockchain.HandleError(err)
+
+	// For rpc...
+	case "rpc":
+		// Parse the arguemnts through rpcCmd, handling any errors.
+		err = rpcCmd.Parse(args[1:])
+		blockchain.HandleError(err)
+
+	// For explorer...
+	case "explorer":
+		// Parse the arguemnts through explorerCmd, handling any errors.
+		err = explorerCmd.Parse(args[1:])
+		blockchain.HandleError(err)
+
+	// For migrate...
+	case "migrate":
+		// Parse the arguemnts through migrateCmd, handling any errors.
+		err = migrateCmd.Parse(args[1:])
+		blockchain.HandleError(err)
+
+	// For exportchain...
+	case "exportchain":
+		// Parse the arguemnts through exportChainCmd, handling any errors.
+		err = exportChainCmd.Parse(args[1:])
+		blockchain.HandleError(err)
+
+	// For generate...
+	case "generate":
+		// Parse the arguemnts through generateCmd, handling any errors.
+		err = generateCmd.Parse(args[1:])
+		blockchain.HandleError(err)
+
+	// For importchain...
+	case "importchain":
+		// Parse the arguemnts through importChainCmd, handling any errors.
+		err = importChainCmd.Parse(args[1:])
+		blockchain.HandleError(err)
+
+	// For snapshot...
+	case "snapshot":
+		// Parse the arguemnts through snapshotCmd, handling any errors.
+		err = snapshotCmd.Parse(args[1:])
+		blockchain.HandleError(err)
+
+	// For restore...
+	case "restore":
+		// Parse the arguemnts through restoreCmd, handling any errors.
+		err = restoreCmd.Parse(args[1:])
+		blockchain.HandleError(err)
+
+	// In any other scenario...
+	default:
+		// Print the chain and exit
+		cli.printChain()
+		runtime.Goexit()
+	}
+
+	// If arguments have been parsed through getBalanceCmd do the following...
+	if getBalanceCmd.Parsed() {
+
+		// Check if the address passed is a blank string or the confirmations are negative, if so print the usage and exit
+		if *getBalanceAddress == "" || *getBalanceMinConf < 0 {
+			getBalanceCmd.Usage()
+			runtime.Goexit()
+		}
+
+		// Otherwise make a call to getBalance with the address
+		cli.getBalance(*getBalanceAddress, *getBalanceMinConf)
+	}
+
+	// If arguments have been parsed through listUnspentCmd do the following...
+	if listUnspentCmd.Parsed() {
+		// Check if the address passed is a blank string or the confirmations are negative, if so print the usage and exit
+		if *listUnspentAddress == "" || *listUnspentMinConf < 0 {
+			listUnspentCmd.Usage()
+			runtime.Goexit()
+		}
+
+		// Otherwise make a call to listUnspent with the address
+		cli.listUnspent(*listUnspentAddress, *listUnspentMinConf)
+	}
+
+	// If arguments have been parsed through createBlockchainCmd do the following...
+	if createBlockchainCmd.Parsed() {
+
+		// Exactly one of an address or a genesis file is needed, regtest chains need an address, only regtest chains
+		// can have deterministic timestamps, and genesis files set their own dust limit and coinbase maturity.
+		// If not print the usage and exit
+		if (*createBlockchainAddress == "") == (*createBlockchainGenesis == "") ||
+			(*createBlockchainRegtest && *createBlockchainGenesis != "") ||
+			(*createBlockchainDeterministic && *createBlockchainRegtest == false) ||
+			(*createBlockchainDustLimit != "" && *createBlockchainGenesis != "") ||
+			(*createBlockchainMaturity != -1 && *createBlockchainGenesis != "") {
+			createBlockchainCmd.Usage()
+			runtime.Goexit()
+		}
+
+		// Otherwise make a call to createBlockChain with the chosen genesis
+		g := blockchain.DefaultGenesis(*createBlockchainAddress)
+
+		if *createBlockchainRegtest {
+			g = blockchain.RegtestGenesis(*createBlockchainAddress, *createBlockchainDeterministic)
+		}
+
+		if *createBlockchainDustLimit != "" {
+			g.DustLimit = cli.parseAmount(*createBlockchainDustLimit)
+		}
+
+		if *createBlockchainMaturity != -1 {
+			g.Maturity = *createBlockchainMaturity
+		}
+
+		cli.createBlockChain(*createBlockchainAddress, g, *createBlockchainGenesis, *createBlockchainTxIndex)
+	}
+
+	// If arguments have been parsed through sendCmd do the following...
+	if sendCmd.Parsed() {
+		// Check if any of the given address are blank, or if there i no amount
+		if *sendFrom == "" || *sendTo == "" || *sendAmount == "" {
+			sendCmd.Usage()
+			runtime.Goexit()
+		}
+
+		// Otherwise make a call to send with the details
+		// Lock times are 32 bits
+		if *sendLockTime > uint(^uint32(0)) {
+			sendCmd.Usage()
+			runtime.Goexit()
+		}
+
+		// Only pending transactions can be replaced
+		if *sendReplaceable && *sendPending == false {
+			sendCmd.Usage()
+			runtime.Goexit()
+		}
+
+		// The fee is optional
+		var fee blockchain.Amount
+
+		if *sendFee != "" {
+			fee = cli.parseAmount(*sendFee)
+		}
+
+		cli.send(*sendFrom, *sendTo, cli.parseAmount(*sendAmount), *sendCoinSelect, uint32(*sendLockTime), fee, *sendPending, *sendReplaceable)
+	}
+
+	// If arguments have been parsed through sendManyCmd do the following...
+	if sendManyCmd.Parsed() {
+		// Check the from address and exactly one of the recipients or a payout file have been given,
+		// if not print the usage and exit
+		if *sendManyFrom == "" || (*sendManyTo == "") == (*sendManyFile == "") {
+			sendManyCmd.Usage()
+			runtime.Goexit()
+		}
+
+		// Get the recipients from whichever was given, exiting if they aren't valid
+		var rs []blockchain.Recipient
+
+		if *sendManyFile != "" {
+			rs, err = loadRecipients(*sendManyFile, cli.Decimals)
+		} else {
+			rs, err = parseRecipients(*sendManyTo, cli.Decimals)
+		}
+
+		if err != nil {
+			cli.fail(fmt.Sprintf("Invalid recipients: %s", err))
+		}
+
+		// Otherwise make a call to sendMany with the details
+		cli.sendMany(*sendManyFrom, rs, *sendManyCoinSelect)
+	}
+
+	// If arguments have been parsed through createKeyCmd do the following...
+	if createKeyCmd.Parsed() {
+		// Check a file to save the key to has been given, if not print the usage and exit
+		if *createKeyFile == "" {
+			createKeyCmd.Usage()
+			runtime.Goexit()
+		}
+
+		// Otherwise make a call to createKey with the file
+		cli.createKey(*createKeyFile)
+	}
+
+	// If arguments have been parsed through createMultiSigCmd do the following...
+	if createMultiSigCmd.Parsed() {
+		// Check the keys have been given, if not print the usage and exit
+		if *createMultiSigKeys == "" {
+			createMultiSigCmd.Usage()
+			runtime.Goexit()
+		}
+
+		// Otherwise make a call to createMultiSig with the details
+		cli.createMultiSig(*createMultiSigRequired, *createMultiSigKeys)
+	}
+
+	// If arguments have been parsed through createPSBTCmd do the following...
+	if createPSBTCmd.Parsed() {
+		// Check if any of the details are blank or the lock time is more than 32 bits, if so print the usage and exit
+		if *createPSBTFrom == "" || *createPSBTTo == "" || *createPSBTAmount == "" || *createPSBTOut == "" ||
+			*createPSBTLockTime > uint(^uint32(0)) {
+			createPSBTCmd.Usage()
+			runtime.Goexit()
+		}
+
+		// Otherwise make a call to createPSBT with the details
+		cli.createPSBT(*createPSBTFrom, *createPSBTTo, cli.parseAmount(*createPSBTAmount), *createPSBTRedeemScript, *createPSBTCoinSelect, uint32(*createPSBTLockTime), *createPSBTReplaceable, *createPSBTOut)
+	}
+
+	// If arguments have been parsed through signPSBTCmd do the following...
+	if signPSBTCmd.Parsed() {
+		// Check the transaction and key files have been given, if not print the usage and exit
+		if *signPSBTIn == "" || *signPSBTKey == "" {
+			signPSBTCmd.Usage()
+			runtime.Goexit()
+		}
+
+		// Write back to the input file unless told otherwise
+		if *signPSBTOut == "" {
+			*signPSBTOut = *signPSBTIn
+		}
+
+		// Otherwise make a call to signPSBT with the files
+		cli.signPSBT(*signPSBTIn, *signPSBTKey, *signPSBTOut)
+	}
+
+	// If arguments have been parsed through combinePSBTCmd do the following...
+	if combinePSBTCmd.Parsed() {
+		// Check the input and output files have been given, if not print the usage and exit
+		if *combinePSBTIn == "" || *combinePSBTOut == "" {
+			combinePSBTCmd.Usage()
+			runtime.Goexit()
+		}
+
+		// Otherwise make a call to combinePSBT with the files
+		cli.combinePSBT(strings.Split(*combinePSBTIn, ","), *combinePSBTOut)
+	}
+
+	// If arguments have been parsed through finalisePSBTCmd do the following...
+	if finalisePSBTCmd.Parsed() {
+		// Check the input file has been given, if not print the usage and exit
+		if *finalisePSBTIn == "" {
+			finalisePSBTCmd.Usage()
+			runtime.Goexit()
+		}
+
+		// Otherwise make a call to finalisePSBT with the files
+		cli.finalisePSBT(*finalisePSBTIn, *finalisePSBTOut)
+	}
+
+	// If arguments have been parsed through broadcastCmd do the following...
+	if broadcastCmd.Parsed() {
+		// Check exactly one of the transaction or a file holding it has been given, if not print the usage and exit
+		if (*broadcastTx == "") == (*broadcastFile == "") {
+			broadcastCmd.Usage()
+			runtime.Goexit()
+		}
+
+		// Otherwise make a call to broadcast with the transaction
+		cli.broadcast(*broadcastTx, *broadcastFile, *broadcastPending)
+	}
+
+	// If arguments have been parsed through initiateSwapCmd do the following...
+	if initiateSwapCmd.Parsed() {
+		// Check if any of the details are blank or the lock time is more than 32 bits, if so print the usage and exit
+		if *initiateSwapFrom == "" || *initiateSwapAmount == "" || *initiateSwapRecipient == "" || *initiateSwapKey == "" ||
+			*initiateSwapLockTime == 0 || *initiateSwapLockTime > uint(^uint32(0)) {
+			initiateSwapCmd.Usage()
+			runtime.Goexit()
+		}
+
+		// Otherwise make a call to initiateSwap with the details
+		cli.initiateSwap(*initiateSwapFrom, cli.parseAmount(*initiateSwapAmount), *initiateSwapRecipient, *initiateSwapKey, uint32(*initiateSwapLockTime), *initiateSwapSecretHash)
+	}
+
+	// If arguments have been parsed through auditSwapCmd do the following...
+	if auditSwapCmd.Parsed() {
+		// Check the contract has been given, if not print the usage and exit
+		if *auditSwapContract == "" {
+			auditSwapCmd.Usage()
+			runtime.Goexit()
+		}
+
+		// Otherwise make a call to auditSwap with the contract
+		cli.auditSwap(*auditSwapContract)
+	}
+
+	// If arguments have been parsed through redeemSwapCmd do the following...
+	if redeemSwapCmd.Parsed() {
+		// Check if any of the details are blank, if so print the usage and exit
+		if *redeemSwapContract == "" || *redeemSwapSecret == "" || *redeemSwapKey == "" || *redeemSwapTo == "" {
+			redeemSwapCmd.Usage()
+			runtime.Goexit()
+		}
+
+		// Otherwise make a call to redeemSwap with the details
+		cli.redeemSwap(*redeemSwapContract, *redeemSwapSecret, *redeemSwapKey, *redeemSwapTo)
+	}
+
+	// If arguments have been parsed through refundSwapCmd do the following...
+	if refundSwapCmd.Parsed() {
+		// Check if any of the details are blank, if so print the usage and exit
+		if *refundSwapContract == "" || *refundSwapKey == "" || *refundSwapTo == "" {
+			refundSwapCmd.Usage()
+			runtime.Goexit()
+		}
+
+		// Otherwise make a call to refundSwap with the details
+		cli.refundSwap(*refundSwapContract, *refundSwapKey, *refundSwapTo)
+	}
+
+	// If arguments have been parsed through extractSecretCmd do the following...
+	if extractSecretCmd.Parsed() {
+		// Check the contract has been given, if not print the usage and exit
+		if *extractSecretContract == "" {
+			extractSecretCmd.Usage()
+			runtime.Goexit()
+		}
+
+		// Otherwise make a call to extractSecret with the contract and transaction ID
+		cli.extractSecret(*extractSecretContract, *extractSecretTxID)
+	}
+
+	// If arguments have been parsed through openChannelCmd do the following...
+	if openChannelCmd.Parsed() {
+		// Check if any of the details are blank or the lock time is more than 32 bits, if so print the usage and exit
+		if *openChannelFrom == "" || *openChannelAmount == "" || *openChannelPayee == "" || *openChannelKey == "" ||
+			*openChannelOut == "" || *openChannelLockTime == 0 || *openChannelLockTime > uint(^uint32(0)) {
+			openChannelCmd.Usage()
+			runtime.Goexit()
+		}
+
+		// Otherwise make a call to openChannel with the details
+		cli.openChannel(*openChannelFrom, cli.parseAmount(*openChannelAmount), *openChannelPayee, *openChannelKey, uint32(*openChannelLockTime), *openChannelOut)
+	}
+
+	// If arguments have been parsed through payChannelCmd do the following...
+	if payChannelCmd.Parsed() {
+		// Check if any of the details are blank, if so print the usage and exit
+		if *payChannelFile == "" || *payChannelAmount == "" || *payChannelKey == "" {
+			payChannelCmd.Usage()
+			runtime.Goexit()
+		}
+
+		// Otherwise make a call to payChannel with the details
+		cli.payChannel(*payChannelFile, cli.parseAmount(*payChannelAmount), *payChannelKey)
+	}
+
+	// If arguments have been parsed through channelInfoCmd do the following...
+	if channelInfoCmd.Parsed() {
+		// Check the channel file has been given, if not print the usage and exit
+		if *channelInfoFile == "" {
+			channelInfoCmd.Usage()
+			runtime.Goexit()
+		}
+
+		// Otherwise make a call to channelInfo with the file
+		cli.channelInfo(*channelInfoFile)
+	}
+
+	// If arguments have been parsed through closeChannelCmd do the following...
+	if closeChannelCmd.Parsed() {
+		// Check the channel and key files have been given, if not print the usage and exit
+		if *closeChannelFile == "" || *closeChannelKey == "" {
+			closeChannelCmd.Usage()
+			runtime.Goexit()
+		}
+
+		// Otherwise make a call to closeChannel with the files
+		cli.closeChannel(*closeChannelFile, *closeChannelKey)
+	}
+
+	// If arguments have been parsed through refundChannelCmd do the following...
+	if refundChannelCmd.Parsed() {
+		// Check the channel and key files have been given, if not print the usage and exit
+		if *refundChannelFile == "" || *refundChannelKey == "" {
+			refundChannelCmd.Usage()
+			runtime.Goexit()
+		}
+
+		// Otherwise make a call to refundChannel with the details
+		cli.refundChannel(*refundChannelFile, *refundChannelKey, *refundChannelTo)
+	}
+
+	// If arguments have been parsed through printCmd do the following...
+	if printCmd.Parsed() {
+		// Make a call to printChain
+		cli.printChain()
+	}
+
+	// If arguments have been parsed through getTransactionCmd do the following...
+	if getTransactionCmd.Parsed() {
+		// Check if the ID passed is a blank string, if so print the usage and exit
+		if *getTransactionID == "" {
+			getTransactionCmd.Usage()
+			runtime.Goexit()
+		}
+
+		// Otherwise make a call to getTransaction with the ID
+		cli.getTransaction(*getTransactionID)
+	}
+
+	// If arguments have been parsed through bumpFeeCmd do the following...
+	if bumpFeeCmd.Parsed() {
+		// Check if the ID or fee passed is a blank string, if so print the usage and exit
+		if *bumpFeeTxID == "" || *bumpFeeFee == "" {
+			bumpFeeCmd.Usage()
+			runtime.Goexit()
+		}
+
+		// Otherwise make a call to bumpFee with the ID, the fee and the key file
+		cli.bumpFee(*bumpFeeTxID, cli.parseAmount(*bumpFeeFee), *bumpFeeKey)
+	}
+
+	// If arguments have been parsed through reindexCmd do the following...
+	if reindexCmd.Parsed() {
+		// Make a call to reindex with the transaction index option
+		cli.reindex(*reindexTxIndex)
+	}
+
+	// If arguments have been parsed through historyCmd do the following...
+	if historyCmd.Parsed() {
+		// Check if the address passed is a blank string or the limit isn't positive, if so print the usage and exit
+		if *historyAddress == "" || *historyLimit <= 0 {
+			historyCmd.Usage()
+			runtime.Goexit()
+		}
+
+		// Otherwise make a call to history with the details
+		cli.history(*historyAddress, *historyCursor, *historyLimit)
+	}
+
+	// If arguments have been parsed through serveCmd do the following...
+	if serveCmd.Parsed() {
+		// Check the credentials have been given, if not print the usage and exit
+		if *serveUser == "" || *servePassword == "" {
+			serveCmd.Usage()
+			runtime.Goexit()
+		}
+
+		// Otherwise make a call to serve with the details
+		cli.serve(*servePort, *serveUser, *servePassword, *serveWalletDir, *serveBackupDir)
+	}
+
+	// If arguments have been parsed through rpcCmd do the following...
+	if rpcCmd.Parsed() {
+		// Check the method has been given, if not print the usage and exit
+		if *rpcMethod == "" {
+			rpcCmd.Usage()
+			runtime.Goexit()
+		}
+
+		// Otherwise make a call to callRPC with the details
+		cli.callRPC(*rpcHost, *rpcPort, *rpcUser, *rpcPassword, *rpcMethod, *rpcParams)
+	}
+
+	// If arguments have been parsed through explorerCmd do the following...
+	if explorerCmd.Parsed() {
+		// Make a call to explore with the port
+		cli.explore(*explorerPort)
+	}
+
+	// If arguments have been parsed through migrateCmd do the following...
+	if migrateCmd.Parsed() {
+		// Make a call to migrate
+		cli.migrate()
+	}
+
+	// If arguments have been parsed through exportChainCmd do the following...
+	if exportChainCmd.Parsed() {
+		// Check the file has been given, if not print the usage and exit
+		if *exportChainFile == "" {
+			exportChainCmd.Usage()
+			runtime.Goexit()
+		}
+
+		// Otherwise make a call to exportChain with the file
+		cli.exportChain(*exportChainFile)
+	}
+
+	// If arguments have been parsed through generateCmd do the following...
+	if generateCmd.Parsed() {
+		// Check the address and a positive number of blocks have been given, if not print the usage and exit
+		if *generateAddress == "" || *generateBlocks <= 0 {
+			generateCmd.Usage()
+			runtime.Goexit()
+		}
+
+		// Otherwise make a call to generate with the address and number of blocks
+		cli.generate(*generateAddress, *generateBlocks)
+	}
+
+	// If arguments have been parsed through importChainCmd do the following...
+	if importChainCmd.Parsed() {
+		// Check the file has been given, if not print the usage and exit
+		if *importChainFile == "" {
+			importChainCmd.Usage()
+			runtime.Goexit()
+		}
+
+		// Otherwise make a call to importChain with the details
+		cli.importChain(*importChainFile, *importChainTxIndex)
+	}
+
+	// If arguments have been parsed through snapshotCmd do the following...
+	if snapshotCmd.Parsed() {
+		// Check the file has been given, if not print the usage and exit
+		if *snapshotOut == "" {
+			snapshotCmd.Usage()
+			runtime.Goexit()
+		}
+
+		// With credentials ask the running node to take the snapshot, otherwise take it directly
+		if *snapshotUser != "" {
+			cli.snapshotRPC(*snapshotHost, *snapshotPort, *snapshotUser, *snapshotPassword, *snapshotOut)
+		} else {
+			cli.snapshot(*snapshotOut)
+		}
+	}
+
+	// If arguments have been parsed through restoreCmd do the following...
+	if restoreCmd.Parsed() {
+		// Check the file has been given, if not print the usage and exit
+		if *restoreIn == "" {
+			restoreCmd.Usage()
+			runtime.Goexit()
+		}
+
+		// Otherwise make a call to restore with the file
+		cli.restore(*restoreIn)
 	}
 
 }
@@ -0,0 +1,63 @@
+package cli
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/liamcf44/go-blockchain.git/blockchain"
+)
+
+// createKey makes a new key pair, saving it to a key file and printing the public key and its address
+func (cli *CLI) createKey(f string) {
+	// Generate the key and write it to the file, handling any errors
+	pk, k, err := blockchain.GenerateKey()
+	blockchain.HandleError(err)
+
+	err = blockchain.SaveKey(f, k)
+	if err != nil {
+		cli.fail(fmt.Sprintf("Could not save the key: %s", err))
+	}
+
+	a := blockchain.KeyHashAddress(pk)
+
+	if cli.isJSON() {
+		cli.printJSON(KeyOutput{hex.EncodeToString(pk), a, f})
+		return
+	}
+
+	fmt.Printf("Public key: %x\n", []byte(pk))
+	fmt.Printf("Address: %s\n", a)
+	fmt.Printf("Private key saved to %s\n", f)
+}
+
+// createMultiSig prints the address and script needing m signatures from the given comma separated public keys
+func (cli *CLI) createMultiSig(m int, ks string) {
+	// Decode each of the public keys, exiting if any of them aren't hex
+	var pks [][]byte
+
+	for _, k := range strings.Split(ks, ",") {
+		pk, err := hex.DecodeString(strings.TrimSpace(k))
+		if err != nil {
+			cli.fail(fmt.Sprintf("Public key %q is not hex encoded", k))
+		}
+
+		pks = append(pks, pk)
+	}
+
+	s, err := blockchain.NewMultiSigScript(m, pks)
+	if err != nil {
+		cli.fail(err.Error())
+	}
+
+	a := blockchain.ScriptHashAddress(s)
+
+	if cli.isJSON() {
+		cli.printJSON(MultiSigOutput{a, hex.EncodeToString(s), m, len(pks)})
+		return
+	}
+
+	fmt.Printf("Address: %s\n", a)
+	fmt.Printf("Redeem script: %x\n", []byte(s))
+	fmt.Printf("Needs %d of %d signatures\n", m, len(pks))
+}
@@ -0,0 +1,278 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+
+	"github.com/liamcf44/go-blockchain.git/blockchain"
+)
+
+// The output formats the CLI can print in
+const (
+	textOutput = "text"
+	jsonOutput = "json"
+)
+
+// BlockOutput is the JSON document for a block
+type BlockOutput struct {
+	Block      *blockchain.Block `json:"block"`
+	Height     int               `json:"height"`
+	ValidProof bool              `json:"validProof"`
+}
+
+// ChainOutput is the JSON document printed by print
+type ChainOutput struct {
+	Blocks []BlockOutput `json:"blocks"`
+}
+
+// CreateOutput is the JSON document printed by createblockchain
+type CreateOutput struct {
+	Address     string `json:"address,omitempty"`
+	GenesisHash string `json:"genesisHash"`
+	Network     string `json:"network"`
+}
+
+// BalanceOutput is the JSON document printed by getbalance. The balance is every unspent output and spendable is
+// what can be spent in the next block, as they always have been. Spendable is split into confirmed, with at least
+// minConf confirmations, and unconfirmed, with fewer or paid by pending transactions. Outputs pending transactions
+// spend aren't counted.
+type BalanceOutput struct {
+	Address     string            `json:"address"`
+	Balance     blockchain.Amount `json:"balance"`
+	Spendable   blockchain.Amount `json:"spendable"`
+	Immature    blockchain.Amount `json:"immature"`
+	MinConf     int               `json:"minConf"`
+	Confirmed   blockchain.Amount `json:"confirmed"`
+	Unconfirmed blockchain.Amount `json:"unconfirmed"`
+}
+
+// ListUnspentOutput is the JSON document printed by listunspent
+type ListUnspentOutput struct {
+	Address string                    `json:"address"`
+	Outputs []blockchain.UnspentEntry `json:"outputs"`
+}
+
+// SendOutput is the JSON document printed by send
+type SendOutput struct {
+	TxID      string            `json:"txid"`
+	BlockHash string            `json:"blockHash"`
+	From      string            `json:"from"`
+	To        string            `json:"to"`
+	Amount    blockchain.Amount `json:"amount"`
+}
+
+// PendingOutput is the JSON document printed by send for a transaction held until it is mined
+type PendingOutput struct {
+	TxID        string            `json:"txid"`
+	From        string            `json:"from"`
+	To          string            `json:"to"`
+	Amount      blockchain.Amount `json:"amount"`
+	Fee         blockchain.Amount `json:"fee"`
+	Replaceable bool              `json:"replaceable"`
+}
+
+// BumpFeeOutput is the JSON document printed by bumpfee
+type BumpFeeOutput struct {
+	Replaced string            `json:"replaced"`
+	TxID     string            `json:"txid"`
+	OldFee   blockchain.Amount `json:"oldFee"`
+	Fee      blockchain.Amount `json:"fee"`
+}
+
+// SendManyOutput is the JSON document printed by sendmany
+type SendManyOutput struct {
+	TxID      string                 `json:"txid"`
+	BlockHash string                 `json:"blockHash"`
+	From      string                 `json:"from"`
+	To        []blockchain.Recipient `json:"to"`
+}
+
+// TransactionOutput is the JSON document printed by gettransaction
+type TransactionOutput struct {
+	Transaction   *blockchain.Transaction `json:"transaction"`
+	BlockHash     string                  `json:"blockHash"`
+	Height        int                     `json:"height"`
+	Confirmations int                     `json:"confirmations"`
+}
+
+// HistoryOutput is the JSON document printed by history
+type HistoryOutput struct {
+	Address    string                    `json:"address"`
+	History    []blockchain.HistoryEntry `json:"history"`
+	NextCursor string                    `json:"nextCursor,omitempty"`
+}
+
+// ReindexOutput is the JSON document printed by reindex
+type ReindexOutput struct {
+	TxIndex bool `json:"txIndex"`
+}
+
+// MigrateOutput is the JSON document printed by migrate, Transactions maps old to new transaction IDs
+type MigrateOutput struct {
+	Blocks       int               `json:"blocks"`
+	Transactions map[string]string `json:"transactions"`
+}
+
+// ExportOutput is the JSON document printed by exportchain and importchain
+type ExportOutput struct {
+	File       string `json:"file"`
+	Blocks     int    `json:"blocks"`
+	LatestHash string `json:"latestHash"`
+}
+
+// GenerateOutput is the JSON document printed by generate
+type GenerateOutput struct {
+	Address string   `json:"address"`
+	Blocks  []string `json:"blocks"`
+	Height  int      `json:"height"`
+}
+
+// KeyOutput is the JSON document printed by createkey
+type KeyOutput struct {
+	PublicKey string `json:"publicKey"`
+	Address   string `json:"address"`
+	File      string `json:"file"`
+}
+
+// MultiSigOutput is the JSON document printed by createmultisig
+type MultiSigOutput struct {
+	Address      string `json:"address"`
+	RedeemScript string `json:"redeemScript"`
+	Required     int    `json:"required"`
+	Keys         int    `json:"keys"`
+}
+
+// PSBTOutput is the JSON document printed by createpsbt, signpsbt and combinepsbt, with the signatures each
+// input has and needs
+type PSBTOutput struct {
+	File     string `json:"file"`
+	TxID     string `json:"txid"`
+	Signed   []int  `json:"signed"`
+	Required []int  `json:"required"`
+	Complete bool   `json:"complete"`
+}
+
+// FinaliseOutput is the JSON document printed by finalizepsbt
+type FinaliseOutput struct {
+	TxID        string `json:"txid"`
+	Transaction string `json:"transaction"`
+	File        string `json:"file,omitempty"`
+}
+
+// BroadcastOutput is the JSON document printed by broadcast, without a block hash when the transaction is pending
+type BroadcastOutput struct {
+	TxID      string `json:"txid"`
+	BlockHash string `json:"blockHash,omitempty"`
+}
+
+// ContractOutput is the JSON document printed by initiateswap and auditswap. The secret is only known to
+// initiateswap when it made it, and the transaction ID when it paid the contract.
+type ContractOutput struct {
+	Address    string            `json:"address"`
+	Contract   string            `json:"contract"`
+	SecretHash string            `json:"secretHash"`
+	Secret     string            `json:"secret,omitempty"`
+	Recipient  string            `json:"recipient"`
+	Sender     string            `json:"sender"`
+	LockTime   uint32            `json:"lockTime"`
+	Locked     blockchain.Amount `json:"locked"`
+	TxID       string            `json:"txid,omitempty"`
+}
+
+// SecretOutput is the JSON document printed by extractsecret
+type SecretOutput struct {
+	Secret string `json:"secret"`
+	TxID   string `json:"txid"`
+}
+
+// ChannelOutput is the JSON document printed by openchannel, paychannel and channelinfo. Signed is whether the
+// payer has signed the commitment paying what has been paid.
+type ChannelOutput struct {
+	File        string            `json:"file"`
+	Address     string            `json:"address"`
+	FundingTxID string            `json:"fundingTxid"`
+	Capacity    blockchain.Amount `json:"capacity"`
+	Paid        blockchain.Amount `json:"paid"`
+	Remaining   blockchain.Amount `json:"remaining"`
+	LockTime    uint32            `json:"lockTime"`
+	Signed      bool              `json:"signed"`
+}
+
+// ServeOutput is the JSON document printed when serve or explorer starts listening
+type ServeOutput struct {
+	Service string `json:"service"`
+	Port    int    `json:"port"`
+}
+
+// ErrorOutput is the JSON document printed when a command fails
+type ErrorOutput struct {
+	Error string `json:"error"`
+}
+
+// Checks whether the CLI is printing JSON
+func (cli *CLI) isJSON() bool {
+	return cli.Output == jsonOutput
+}
+
+// Prints a value as an indented JSON document
+func (cli *CLI) printJSON(v interface{}) {
+	// Encode the value, handling any errors
+	b, err := json.MarshalIndent(v, "", "  ")
+	blockchain.HandleError(err)
+
+	fmt.Println(string(b))
+}
+
+// Prints a message saying why the command failed in the current output format, then exits
+func (cli *CLI) fail(msg string) {
+	if cli.isJSON() {
+		cli.printJSON(ErrorOutput{msg})
+	} else {
+		fmt.Println(msg)
+	}
+
+	runtime.Goexit()
+}
+
+// Turns a panic from the chain into an ErrorOutput in JSON mode, so scripts get a JSON document whichever way a
+// command fails. It has to be deferred, and text mode panics as before.
+func (cli *CLI) recoverJSON() {
+	r := recover()
+	if r == nil {
+		return
+	}
+
+	if cli.isJSON() == false {
+		panic(r)
+	}
+
+	cli.fail(strings.TrimSuffix(strings.TrimPrefix(fmt.Sprint(r), "Error : "), "!"))
+}
+
+// Sets the number of decimal places amounts are read and printed with
+func (cli *CLI) setDecimals(d int) {
+	if d < 0 || d > blockchain.MaxDecimals {
+		fmt.Printf("Decimal places must be between 0 and %d\n", blockchain.MaxDecimals)
+		runtime.Goexit()
+	}
+
+	cli.Decimals = d
+}
+
+// Sets up the output format, in JSON mode the chain's progress messages go to stderr so stdout only holds JSON
+func (cli *CLI) setOutput(f string) {
+	// Check the format is one the CLI knows
+	if f != textOutput && f != jsonOutput {
+		fmt.Printf("Unknown output format %q, use text or json\n", f)
+		runtime.Goexit()
+	}
+
+	cli.Output = f
+
+	if cli.isJSON() {
+		blockchain.Output = os.Stderr
+	}
+}
@@ -0,0 +1,214 @@
+package cli
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/liamcf44/go-blockchain.git/blockchain"
+)
+
+// createPSBT writes a partially signed transaction paying an amount from one address to another to a file.
+// Sending from a script hash address needs its hex redeem script, cs names the coin selector to use, lt is
+// the lock time, 0 for none, and r signals the transaction can be replaced while it is pending.
+func (cli *CLI) createPSBT(f, t string, a blockchain.Amount, rs, cs string, lt uint32, r bool, o string) {
+	// Decode the redeem script, exiting if it isn't hex
+	s, err := hex.DecodeString(rs)
+	if err != nil {
+		cli.fail("Redeem script is not hex encoded")
+	}
+
+	// Create the blockchain with ContinueBlockChain and the from address
+	bc := blockchain.ContinueBlockChain(f)
+
+	// Defer the closing of the database
+	defer bc.Database.Close()
+
+	// Build the transaction along with the outputs it spends, exiting if it can't be
+	p, err := bc.CreatePSBT(f, []blockchain.Recipient{{Address: t, Amount: a}}, cli.coinSelector(cs), s)
+	if err != nil {
+		cli.fail(err.Error())
+	}
+
+	// Lock the transaction and signal it can be replaced if asked, before anything signs it
+	if lt != 0 {
+		p.Tx.SetLockTime(lt)
+	}
+
+	if r {
+		p.Tx.SetReplaceable()
+	}
+
+	cli.savePSBT(o, p)
+}
+
+// signPSBT signs a partially signed transaction with a key file, writing the result to the out file.
+// It doesn't need the chain so it can be run on a machine without it.
+func (cli *CLI) signPSBT(in, kf, o string) {
+	// Load the transaction and the key, exiting if either can't be read
+	p := cli.loadPSBT(in)
+
+	k, err := blockchain.LoadKey(kf)
+	if err != nil {
+		cli.fail(fmt.Sprintf("Could not load the key: %s", err))
+	}
+
+	// Sign every input the key can and save the transaction
+	_, err = p.Sign(k)
+	if err != nil {
+		cli.fail(err.Error())
+	}
+
+	cli.savePSBT(o, p)
+}
+
+// combinePSBT merges the signatures of several partially signed transaction files into one
+func (cli *CLI) combinePSBT(ins []string, o string) {
+	// Load each of the transactions, exiting if any can't be read
+	var ps []*blockchain.PSBT
+
+	for _, in := range ins {
+		ps = append(ps, cli.loadPSBT(in))
+	}
+
+	p, err := blockchain.CombinePSBTs(ps...)
+	if err != nil {
+		cli.fail(err.Error())
+	}
+
+	cli.savePSBT(o, p)
+}
+
+// finalisePSBT fills in the script sigs of a fully signed transaction, printing it in hex and writing it to the
+// out file if one is given
+func (cli *CLI) finalisePSBT(in, o string) {
+	// Load the transaction and finalise it, exiting if it isn't signed enough
+	p := cli.loadPSBT(in)
+
+	tx, err := p.Finalise()
+	if err != nil {
+		cli.fail(err.Error())
+	}
+
+	h := hex.EncodeToString(tx.Serialise())
+
+	if o != "" {
+		err = ioutil.WriteFile(o, []byte(h+"\n"), 0644)
+		if err != nil {
+			cli.fail(fmt.Sprintf("Could not save the transaction: %s", err))
+		}
+	}
+
+	if cli.isJSON() {
+		cli.printJSON(FinaliseOutput{hex.EncodeToString(tx.ID), h, o})
+		return
+	}
+
+	fmt.Printf("Transaction %x\n", tx.ID)
+	fmt.Println(h)
+}
+
+// broadcast adds a finalised transaction, given in hex or in a file holding it in hex, to the chain, or to the
+// pending transactions for a later block if p is set or it is locked past the next block
+func (cli *CLI) broadcast(h, f string, p bool) {
+	// Read the transaction from the file if there is one
+	if f != "" {
+		data, err := ioutil.ReadFile(f)
+		if err != nil {
+			cli.fail(fmt.Sprintf("Could not read the transaction: %s", err))
+		}
+
+		h = string(data)
+	}
+
+	// Decode the transaction, exiting if it isn't valid
+	data, err := hex.DecodeString(strings.TrimSpace(h))
+	if err != nil {
+		cli.fail("Transaction is not hex encoded")
+	}
+
+	tx, err := blockchain.DeserialiseTransaction(data)
+	if err != nil {
+		cli.fail(fmt.Sprintf("Invalid transaction: %s", err))
+	}
+
+	// Create the blockchain with ContinueBlockChain and a blank address
+	bc := blockchain.ContinueBlockChain("")
+
+	// Defer the closing of the database
+	defer bc.Database.Close()
+
+	// Add a pending transaction, or one that can't go in the next block, to the pool, exiting if it isn't valid
+	if p || bc.IsFinal(tx) == false {
+		err := bc.AcceptTransaction(tx)
+		if err != nil {
+			cli.fail(err.Error())
+		}
+
+		if cli.isJSON() {
+			cli.printJSON(BroadcastOutput{hex.EncodeToString(tx.ID), ""})
+			return
+		}
+
+		fmt.Printf("Transaction %x is pending\n", tx.ID)
+		return
+	}
+
+	// Check the transaction can go in the next block, exiting if it can't, then append it to the chain
+	err = bc.CheckTransaction(tx)
+	if err != nil {
+		cli.fail(err.Error())
+	}
+
+	bc.AppendBlock([]*blockchain.Transaction{tx})
+
+	if cli.isJSON() {
+		cli.printJSON(BroadcastOutput{hex.EncodeToString(tx.ID), hex.EncodeToString(bc.LatestHash)})
+		return
+	}
+
+	fmt.Printf("Successfully sent transaction %x\n", tx.ID)
+}
+
+// Loads a partially signed transaction from a file, exiting if it can't be read
+func (cli *CLI) loadPSBT(f string) *blockchain.PSBT {
+	p, err := blockchain.LoadPSBT(f)
+	if err != nil {
+		cli.fail(fmt.Sprintf("Could not load the transaction: %s", err))
+	}
+
+	return p
+}
+
+// Saves a partially signed transaction to a file and prints how many signatures each input has
+func (cli *CLI) savePSBT(f string, p *blockchain.PSBT) {
+	err := blockchain.SavePSBT(f, p)
+	if err != nil {
+		cli.fail(fmt.Sprintf("Could not save the transaction: %s", err))
+	}
+
+	po := PSBTOutput{f, hex.EncodeToString(p.Tx.ID), []int{}, []int{}, p.Complete()}
+
+	for i := range p.Inputs {
+		n, m := p.InputSignatures(i)
+
+		po.Signed = append(po.Signed, n)
+		po.Required = append(po.Required, m)
+	}
+
+	if cli.isJSON() {
+		cli.printJSON(po)
+		return
+	}
+
+	fmt.Printf("Transaction %x saved to %s\n", p.Tx.ID, f)
+
+	for i := range p.Inputs {
+		fmt.Printf("Input %d ==> %d of %d signatures\n", i, po.Signed[i], po.Required[i])
+	}
+
+	if po.Complete {
+		fmt.Println("Ready to finalise")
+	}
+}
@@ -0,0 +1,281 @@
+package cli
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/liamcf44/go-blockchain.git/blockchain"
+)
+
+// initiateSwap pays an amount from an address into a hash time locked contract, redeemable by the recipient's
+// public key with the secret or refundable to the key in the key file after the lock time. A new secret is made
+// unless the hash of one is given, as the participant of a swap does with the initiator's hash. Sending from a key
+// hash address needs it to be the address of the key file's key, which signs the payment.
+func (cli *CLI) initiateSwap(f string, a blockchain.Amount, rpk, kf string, lt uint32, sh string) {
+	// Decode the recipient's key and load the sender's, exiting if either can't be read
+	r, err := hex.DecodeString(rpk)
+	if err != nil {
+		cli.fail("Recipient public key is not hex encoded")
+	}
+
+	k := cli.loadKey(kf)
+
+	// Make a secret if one's hash hasn't been given
+	var s, h []byte
+
+	if sh == "" {
+		s, h, err = blockchain.NewSecret()
+		blockchain.HandleError(err)
+	} else {
+		h, err = hex.DecodeString(sh)
+		if err != nil {
+			cli.fail("Secret hash is not hex encoded")
+		}
+	}
+
+	c, err := blockchain.NewHTLC(h, r, k.Public().(ed25519.PublicKey), lt)
+	if err != nil {
+		cli.fail(err.Error())
+	}
+
+	// Create the blockchain with ContinueBlockChain and the from address
+	bc := blockchain.ContinueBlockChain(f)
+
+	// Defer the closing of the database
+	defer bc.Database.Close()
+
+	// Pay the contract's address, signing with the key if the from address needs it
+	tx := cli.payFrom(bc, f, c.Address(), a, k)
+
+	// Append the transaction to the chain
+	bc.AppendBlock([]*blockchain.Transaction{tx})
+
+	cli.printContract(bc, c, s, tx.ID)
+}
+
+// auditSwap prints the details of a contract and how much is locked to it, so a participant can check a contract
+// before paying into their own
+func (cli *CLI) auditSwap(cs string) {
+	c := cli.parseContract(cs)
+
+	// Create the blockchain with ContinueBlockChain and a blank address
+	bc := blockchain.ContinueBlockChain("")
+
+	// Defer the closing of the database
+	defer bc.Database.Close()
+
+	cli.printContract(bc, c, nil, nil)
+}
+
+// redeemSwap spends everything locked to a contract to an address with the secret and the recipient's key file
+func (cli *CLI) redeemSwap(cs, sh, kf, t string) {
+	c := cli.parseContract(cs)
+
+	s, err := hex.DecodeString(sh)
+	if err != nil {
+		cli.fail("Secret is not hex encoded")
+	}
+
+	k := cli.loadKey(kf)
+
+	// Create the blockchain with ContinueBlockChain and the contract's address
+	bc := blockchain.ContinueBlockChain(c.Address())
+
+	// Defer the closing of the database
+	defer bc.Database.Close()
+
+	tx, err := c.Redeem(bc, s, k, t)
+	if err != nil {
+		cli.fail(err.Error())
+	}
+
+	// Append the transaction to the chain
+	bc.AppendBlock([]*blockchain.Transaction{tx})
+
+	cli.printSpend(bc, tx, "redeemed", t)
+}
+
+// refundSwap spends everything locked to a contract back to an address with the sender's key file, once the
+// contract's lock time has passed
+func (cli *CLI) refundSwap(cs, kf, t string) {
+	c := cli.parseContract(cs)
+
+	k := cli.loadKey(kf)
+
+	// Create the blockchain with ContinueBlockChain and the contract's address
+	bc := blockchain.ContinueBlockChain(c.Address())
+
+	// Defer the closing of the database
+	defer bc.Database.Close()
+
+	tx, err := c.Refund(bc, k, t)
+	if err != nil {
+		cli.fail(err.Error())
+	}
+
+	// Refunding early is refused rather than held pending, so the recipient can still redeem before the lock time
+	if bc.IsFinal(tx) == false {
+		cli.fail(fmt.Sprintf("Contract can't be refunded until %s", blockchain.DescribeLockTime(c.LockTime)))
+	}
+
+	// Append the transaction to the chain
+	bc.AppendBlock([]*blockchain.Transaction{tx})
+
+	cli.printSpend(bc, tx, "refunded", t)
+}
+
+// extractSecret prints the secret revealed by the transaction redeeming a contract, looking through the chain
+// for it if its ID isn't given
+func (cli *CLI) extractSecret(cs, id string) {
+	c := cli.parseContract(cs)
+
+	// Create the blockchain with ContinueBlockChain and a blank address
+	bc := blockchain.ContinueBlockChain("")
+
+	// Defer the closing of the database
+	defer bc.Database.Close()
+
+	var s []byte
+	var tx *blockchain.Transaction
+
+	if id == "" {
+		var err error
+
+		s, tx, err = c.FindSecret(bc)
+		if err != nil {
+			cli.fail(err.Error())
+		}
+	} else {
+		txID, err := hex.DecodeString(id)
+		if err != nil {
+			cli.fail("Transaction ID is not hex encoded")
+		}
+
+		tx, _, err = bc.FindTransaction(txID)
+		if err != nil {
+			cli.fail(err.Error())
+		}
+
+		s, err = c.ExtractSecret(tx)
+		if err != nil {
+			cli.fail(err.Error())
+		}
+	}
+
+	if cli.isJSON() {
+		cli.printJSON(SecretOutput{hex.EncodeToString(s), hex.EncodeToString(tx.ID)})
+		return
+	}
+
+	fmt.Printf("Secret: %x\n", s)
+	fmt.Printf("Revealed by transaction %x\n", tx.ID)
+}
+
+// Returns a transaction paying an amount from an address to another, signed with the key if the from address is
+// the key's key hash address. Exits if the from address needs any other signatures.
+func (cli *CLI) payFrom(bc *blockchain.BlockChain, f, t string, a blockchain.Amount, k ed25519.PrivateKey) *blockchain.Transaction {
+	rs := []blockchain.Recipient{{Address: t, Amount: a}}
+
+	if blockchain.NeedsSignatures(f) == false {
+		return blockchain.NewTransaction(f, rs, nil, bc)
+	}
+
+	if f != blockchain.KeyHashAddress(k.Public().(ed25519.PublicKey)) {
+		cli.fail(fmt.Sprintf("Sending from %s needs signatures, use createpsbt to pay %s", f, t))
+	}
+
+	// Build the transaction as a partially signed one so it can be signed and finalised with the key
+	p, err := bc.CreatePSBT(f, rs, nil, nil)
+	if err != nil {
+		cli.fail(err.Error())
+	}
+
+	_, err = p.Sign(k)
+	if err != nil {
+		cli.fail(err.Error())
+	}
+
+	tx, err := p.Finalise()
+	if err != nil {
+		cli.fail(err.Error())
+	}
+
+	return tx
+}
+
+// Decodes a hex contract script and reads the contract out of it, exiting if it isn't one
+func (cli *CLI) parseContract(cs string) *blockchain.HTLC {
+	s, err := hex.DecodeString(cs)
+	if err != nil {
+		cli.fail("Contract is not hex encoded")
+	}
+
+	c, err := blockchain.ParseHTLC(s)
+	if err != nil {
+		cli.fail(err.Error())
+	}
+
+	return c
+}
+
+// Prints a contract's details along with how much is locked to it, and its secret and the transaction paying it
+// when they are known
+func (cli *CLI) printContract(bc *blockchain.BlockChain, c *blockchain.HTLC, s, id []byte) {
+	a := c.Address()
+
+	// Add up the outputs still locked to the contract
+	var v blockchain.Amount
+
+	for _, u := range bc.FindUnspentOutputs(a) {
+		var err error
+
+		v, err = v.Add(u.Value)
+		if err != nil {
+			cli.fail(err.Error())
+		}
+	}
+
+	co := ContractOutput{
+		a,
+		hex.EncodeToString(c.Script()),
+		hex.EncodeToString(c.SecretHash),
+		hex.EncodeToString(s),
+		hex.EncodeToString(c.Recipient),
+		hex.EncodeToString(c.Sender),
+		c.LockTime,
+		v,
+		hex.EncodeToString(id),
+	}
+
+	if cli.isJSON() {
+		cli.printJSON(co)
+		return
+	}
+
+	if id != nil {
+		fmt.Printf("Paid contract in transaction %s\n", co.TxID)
+	}
+
+	fmt.Printf("Address: %s\n", co.Address)
+	fmt.Printf("Contract: %s\n", co.Contract)
+	fmt.Printf("Secret hash: %s\n", co.SecretHash)
+
+	if s != nil {
+		fmt.Printf("Secret: %s - keep this private until redeeming the other side of the swap\n", co.Secret)
+	}
+
+	fmt.Printf("Recipient: %s\n", co.Recipient)
+	fmt.Printf("Refundable to %s after %s\n", co.Sender, blockchain.DescribeLockTime(c.LockTime))
+	fmt.Printf("Locked: %s\n", v.Format(cli.Decimals))
+}
+
+// Prints the transaction spending a contract
+func (cli *CLI) printSpend(bc *blockchain.BlockChain, tx *blockchain.Transaction, how, t string) {
+	if cli.isJSON() {
+		cli.printJSON(BroadcastOutput{hex.EncodeToString(tx.ID), hex.EncodeToString(bc.LatestHash)})
+		return
+	}
+
+	fmt.Printf("Successfully %s %s to %s in transaction %x\n", how, tx.Outputs[0].Value.Format(cli.Decimals), t, tx.ID)
+}
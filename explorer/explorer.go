@@ -0,0 +1,207 @@
+// Package explorer serves a read only REST API for browsing the blocks, transactions and addresses in a chain.
+package explorer
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/liamcf44/go-blockchain.git/blockchain"
+)
+
+// Explorer holds the chain being browsed
+type Explorer struct {
+	Chain *blockchain.BlockChain
+	mux   *http.ServeMux
+}
+
+// BlockResponse is the response for a block
+type BlockResponse struct {
+	Block         *blockchain.Block `json:"block"`
+	Height        int               `json:"height"`
+	Confirmations int               `json:"confirmations"`
+}
+
+// TransactionResponse is the response for a transaction
+type TransactionResponse struct {
+	Transaction   *blockchain.Transaction `json:"transaction"`
+	BlockHash     string                  `json:"blockHash"`
+	Height        int                     `json:"height"`
+	Confirmations int                     `json:"confirmations"`
+}
+
+// AddressResponse is the response for an address, holding its balance and a page of its history
+type AddressResponse struct {
+	Address    string                    `json:"address"`
+	Balance    blockchain.Amount         `json:"balance"`
+	History    []blockchain.HistoryEntry `json:"history"`
+	NextCursor string                    `json:"nextCursor,omitempty"`
+}
+
+// TipResponse is the response for the latest block in the chain
+type TipResponse struct {
+	Hash   string `json:"hash"`
+	Height int    `json:"height"`
+}
+
+// Error is the response for any request that fails
+type Error struct {
+	Error string `json:"error"`
+}
+
+// New creates an Explorer for a chain, which should be opened read only
+func New(bc *blockchain.BlockChain) *Explorer {
+	// Create the explorer and route each path to its handler
+	e := &Explorer{bc, http.NewServeMux()}
+
+	e.mux.HandleFunc("/blocks", e.blockByHeight)
+	e.mux.HandleFunc("/blocks/", e.blockByHash)
+	e.mux.HandleFunc("/tx/", e.transaction)
+	e.mux.HandleFunc("/address/", e.address)
+	e.mux.HandleFunc("/tip", e.tip)
+
+	return e
+}
+
+// ListenAndServe serves the API on the given address until an error occurs
+func (e *Explorer) ListenAndServe(addr string) error {
+	return http.ListenAndServe(addr, e)
+}
+
+// ServeHTTP handles a single request, only GET requests are allowed
+func (e *Explorer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		writeJSON(w, http.StatusMethodNotAllowed, Error{"only GET requests are allowed"})
+		return
+	}
+
+	e.mux.ServeHTTP(w, r)
+}
+
+// Handles /blocks/{hash}
+func (e *Explorer) blockByHash(w http.ResponseWriter, r *http.Request) {
+	// Decode the hash from the path
+	h, err := hex.DecodeString(strings.TrimPrefix(r.URL.Path, "/blocks/"))
+	if err != nil || len(h) == 0 {
+		writeJSON(w, http.StatusBadRequest, Error{"block hash must be hex encoded"})
+		return
+	}
+
+	e.writeBlock(w, h)
+}
+
+// Handles /blocks?height=
+func (e *Explorer) blockByHeight(w http.ResponseWriter, r *http.Request) {
+	// Parse the height from the query
+	height, err := strconv.Atoi(r.URL.Query().Get("height"))
+	if err != nil || height < 0 {
+		writeJSON(w, http.StatusBadRequest, Error{"height must be a non negative integer"})
+		return
+	}
+
+	// Look up the hash of the block at the height
+	h, err := e.Chain.GetBlockHash(height)
+	if err != nil {
+		writeJSON(w, http.StatusNotFound, Error{fmt.Sprintf("no block at height %d", height)})
+		return
+	}
+
+	e.writeBlock(w, h)
+}
+
+// Writes the block with the given hash along with its height and confirmations
+func (e *Explorer) writeBlock(w http.ResponseWriter, h []byte) {
+	// Only hashes with a height are blocks, anything else in the database is not
+	height, err := e.Chain.GetBlockHeight(h)
+	if err != nil {
+		writeJSON(w, http.StatusNotFound, Error{"block not found"})
+		return
+	}
+
+	b, err := e.Chain.GetBlock(h)
+	if err != nil {
+		writeJSON(w, http.StatusNotFound, Error{"block not found"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, BlockResponse{b, height, e.Chain.Height() - height + 1})
+}
+
+// Handles /tx/{id}
+func (e *Explorer) transaction(w http.ResponseWriter, r *http.Request) {
+	// Decode the ID from the path
+	id, err := hex.DecodeString(strings.TrimPrefix(r.URL.Path, "/tx/"))
+	if err != nil || len(id) == 0 {
+		writeJSON(w, http.StatusBadRequest, Error{"transaction ID must be hex encoded"})
+		return
+	}
+
+	// Find the transaction
+	t, l, err := e.Chain.FindTransaction(id)
+	if err == blockchain.ErrTxNotFound {
+		writeJSON(w, http.StatusNotFound, Error{err.Error()})
+		return
+	}
+
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, Error{err.Error()})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, TransactionResponse{t, hex.EncodeToString(l.BlockHash), l.Height, e.Chain.Confirmations(l)})
+}
+
+// Handles /address/{addr}, with optional cursor and limit query params to page through the history
+func (e *Explorer) address(w http.ResponseWriter, r *http.Request) {
+	// Get the address from the path
+	a := strings.TrimPrefix(r.URL.Path, "/address/")
+	if a == "" {
+		writeJSON(w, http.StatusBadRequest, Error{"address is required"})
+		return
+	}
+
+	// Parse the limit, defaulting to 20 per page
+	l := 20
+
+	if q := r.URL.Query().Get("limit"); q != "" {
+		var err error
+
+		l, err = strconv.Atoi(q)
+		if err != nil || l <= 0 {
+			writeJSON(w, http.StatusBadRequest, Error{"limit must be a positive integer"})
+			return
+		}
+	}
+
+	// Get the page of history
+	es, nc, err := e.Chain.AddressHistory(a, r.URL.Query().Get("cursor"), l)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, Error{"cursor is not valid"})
+		return
+	}
+
+	// Always return a list, even if it is empty
+	if es == nil {
+		es = []blockchain.HistoryEntry{}
+	}
+
+	writeJSON(w, http.StatusOK, AddressResponse{a, e.Chain.GetBalance(a), es, nc})
+}
+
+// Handles /tip
+func (e *Explorer) tip(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, TipResponse{hex.EncodeToString(e.Chain.LatestHash), e.Chain.Height()})
+}
+
+// Writes a value as JSON with the given status code
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+
+	err := json.NewEncoder(w).Encode(v)
+	blockchain.HandleError(err)
+}
@@ -0,0 +1,67 @@
+package rpc
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// Client calls the methods of a Server over HTTP
+type Client struct {
+	URL      string
+	User     string
+	Password string
+}
+
+// NewClient creates a Client for the server at the given URL with the given credentials
+func NewClient(url, u, p string) *Client {
+	return &Client{url, u, p}
+}
+
+// Call calls a method with the given params and returns the raw result
+func (c *Client) Call(m string, p json.RawMessage) (json.RawMessage, error) {
+	// Build the request, always using ID 1 as only one request is made at a time
+	b, err := json.Marshal(Request{"2.0", m, p, json.RawMessage("1")})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, c.URL, bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.SetBasicAuth(c.User, c.Password)
+
+	// Send the request
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	defer res.Body.Close()
+
+	// Anything other than a 200 means the request never reached a method
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("server responded with %s", res.Status)
+	}
+
+	// Decode the response, which holds either a result or an error
+	var r struct {
+		Result json.RawMessage `json:"result"`
+		Error  *Error          `json:"error"`
+	}
+
+	err = json.NewDecoder(res.Body).Decode(&r)
+	if err != nil {
+		return nil, err
+	}
+
+	if r.Error != nil {
+		return nil, r.Error
+	}
+
+	return r.Result, nil
+}
@@ -0,0 +1,554 @@
+package rpc
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/liamcf44/go-blockchain.git/blockchain"
+)
+
+// A method takes the server and the raw params and returns a result or an error
+type method func(s *Server, p json.RawMessage) (interface{}, *Error)
+
+// All of the methods the server supports, keyed by name
+var methods = map[string]method{
+	"getbalance":         getBalance,
+	"listunspent":        listUnspent,
+	"getblock":           getBlock,
+	"getblockcount":      getBlockCount,
+	"gettransaction":     getTransaction,
+	"sendtransaction":    sendTransaction,
+	"sendmany":           sendMany,
+	"sendrawtransaction": sendRawTransaction,
+	"getmempool":         getMempool,
+	"createwallet":       createWallet,
+	"snapshot":           snapshot,
+	"generate":           generate,
+}
+
+// BalanceResult is the result of getbalance. The balance is every unspent output and spendable is what can be spent
+// in the next block, as they always have been. Spendable is split into confirmed, with at least minConf
+// confirmations, and unconfirmed, with fewer or paid by pending transactions. Outputs pending transactions spend
+// aren't counted.
+type BalanceResult struct {
+	Address     string            `json:"address"`
+	Balance     blockchain.Amount `json:"balance"`
+	Spendable   blockchain.Amount `json:"spendable"`
+	Immature    blockchain.Amount `json:"immature"`
+	MinConf     int               `json:"minConf"`
+	Confirmed   blockchain.Amount `json:"confirmed"`
+	Unconfirmed blockchain.Amount `json:"unconfirmed"`
+}
+
+// ListUnspentResult is the result of listunspent
+type ListUnspentResult struct {
+	Address string                    `json:"address"`
+	Outputs []blockchain.UnspentEntry `json:"outputs"`
+}
+
+// BlockResult is the result of getblock
+type BlockResult struct {
+	Block         *blockchain.Block `json:"block"`
+	Height        int               `json:"height"`
+	Confirmations int               `json:"confirmations"`
+}
+
+// TransactionResult is the result of gettransaction
+type TransactionResult struct {
+	Transaction   *blockchain.Transaction `json:"transaction"`
+	BlockHash     string                  `json:"blockHash"`
+	Height        int                     `json:"height"`
+	Confirmations int                     `json:"confirmations"`
+}
+
+// SnapshotResult is the result of snapshot
+type SnapshotResult struct {
+	File       string `json:"file"`
+	LatestHash string `json:"latestHash"`
+	Height     int    `json:"height"`
+}
+
+// WalletResult is the result of createwallet
+type WalletResult struct {
+	Name      string `json:"name"`
+	PublicKey string `json:"publicKey"`
+	Address   string `json:"address"`
+}
+
+// SendResult is the result of sendtransaction, sendmany and sendrawtransaction. The block hash is left out when
+// the transaction is pending.
+type SendResult struct {
+	TxID      string `json:"txid"`
+	BlockHash string `json:"blockHash,omitempty"`
+}
+
+// GenerateResult is the result of generate
+type GenerateResult struct {
+	Blocks []string `json:"blocks"`
+	Height int      `json:"height"`
+}
+
+// getbalance returns the balance of an address, params {"address": ADDRESS, "minconf": N}. Spendable outputs with
+// fewer than minconf confirmations, 1 if not given, count as unconfirmed, they are still in the balance, as do the
+// outputs of pending transactions. Outputs pending transactions spend are left out.
+func getBalance(s *Server, p json.RawMessage) (interface{}, *Error) {
+	// Decode the params
+	var params struct {
+		Address string `json:"address"`
+		MinConf *int   `json:"minconf"`
+	}
+
+	if err := decodeParams(p, &params); err != nil {
+		return nil, err
+	}
+
+	mc, err := addressParams(params.Address, params.MinConf)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	b := s.Chain.GetBalances(params.Address, mc)
+
+	sp, serr := b.Spendable()
+	if serr != nil {
+		return nil, &Error{ChainError, serr.Error()}
+	}
+
+	t, serr := b.Total()
+	if serr != nil {
+		return nil, &Error{ChainError, serr.Error()}
+	}
+
+	return BalanceResult{params.Address, t, sp, b.Immature, mc, b.Confirmed, b.Unconfirmed}, nil
+}
+
+// listunspent returns the unspent outputs of an address with their confirmations, params
+// {"address": ADDRESS, "minconf": N}. Only outputs with at least minconf confirmations, 1 if not given, are listed,
+// so the outputs of pending transactions are only listed with a minconf of 0. Outputs pending transactions spend
+// are left out.
+func listUnspent(s *Server, p json.RawMessage) (interface{}, *Error) {
+	// Decode the params
+	var params struct {
+		Address string `json:"address"`
+		MinConf *int   `json:"minconf"`
+	}
+
+	if err := decodeParams(p, &params); err != nil {
+		return nil, err
+	}
+
+	mc, err := addressParams(params.Address, params.MinConf)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	// Always return a list, even if it is empty
+	es := s.Chain.ListUnspent(params.Address, mc)
+	if es == nil {
+		es = []blockchain.UnspentEntry{}
+	}
+
+	return ListUnspentResult{params.Address, es}, nil
+}
+
+// Checks the address and minimum confirmations params of getbalance and listunspent, returning the minimum
+// confirmations with the default filled in
+func addressParams(a string, mc *int) (int, *Error) {
+	if a == "" {
+		return 0, &Error{InvalidParams, "address is required"}
+	}
+
+	if mc == nil {
+		return 1, nil
+	}
+
+	if *mc < 0 {
+		return 0, &Error{InvalidParams, "minconf can't be negative"}
+	}
+
+	return *mc, nil
+}
+
+// getblock returns a block by its hex hash or its height, params {"hash": HASH} or {"height": HEIGHT}
+func getBlock(s *Server, p json.RawMessage) (interface{}, *Error) {
+	// Decode the params
+	var params struct {
+		Hash   string `json:"hash"`
+		Height *int   `json:"height"`
+	}
+
+	if err := decodeParams(p, &params); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	// Work out the hash of the block, either given directly or looked up by height
+	var h []byte
+	var err error
+
+	switch {
+	case params.Hash != "":
+		h, err = hex.DecodeString(params.Hash)
+		if err != nil {
+			return nil, &Error{InvalidParams, "hash must be hex encoded"}
+		}
+	case params.Height != nil:
+		h, err = s.Chain.GetBlockHash(*params.Height)
+		if err != nil {
+			return nil, &Error{ChainError, "no block at that height"}
+		}
+	default:
+		return nil, &Error{InvalidParams, "hash or height is required"}
+	}
+
+	// Fetch the block and its height
+	b, err := s.Chain.GetBlock(h)
+	if err != nil {
+		return nil, &Error{ChainError, "block not found"}
+	}
+
+	height, err := s.Chain.GetBlockHeight(b.Hash)
+	if err != nil {
+		return nil, &Error{ChainError, err.Error()}
+	}
+
+	return BlockResult{b, height, s.Chain.Height() - height + 1}, nil
+}
+
+// getblockcount returns the height of the latest block, the initial block being height 0
+func getBlockCount(s *Server, p json.RawMessage) (interface{}, *Error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.Chain.Height(), nil
+}
+
+// gettransaction returns a transaction along with where it lives in the chain, params {"id": ID}
+func getTransaction(s *Server, p json.RawMessage) (interface{}, *Error) {
+	// Decode the params
+	var params struct {
+		ID string `json:"id"`
+	}
+
+	if err := decodeParams(p, &params); err != nil {
+		return nil, err
+	}
+
+	id, err := hex.DecodeString(params.ID)
+	if err != nil || len(id) == 0 {
+		return nil, &Error{InvalidParams, "id must be hex encoded"}
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	// Find the transaction
+	t, l, err := s.Chain.FindTransaction(id)
+	if err != nil {
+		return nil, &Error{ChainError, err.Error()}
+	}
+
+	return TransactionResult{t, hex.EncodeToString(l.BlockHash), l.Height, s.Chain.Confirmations(l)}, nil
+}
+
+// sendtransaction sends an amount between addresses and mines it into a block,
+// params {"from": FROM, "to": TO, "amount": AMOUNT, "coinselect": SELECTOR, "locktime": LOCKTIME, "pending": BOOL},
+// the coin selector, lock time and pending are optional. A pending transaction, or one locked past the next block,
+// is added to the pending transactions instead.
+func sendTransaction(s *Server, p json.RawMessage) (interface{}, *Error) {
+	// Decode the params
+	var params struct {
+		From       string            `json:"from"`
+		To         string            `json:"to"`
+		Amount     blockchain.Amount `json:"amount"`
+		CoinSelect string            `json:"coinselect"`
+		LockTime   uint32            `json:"locktime"`
+		Pending    bool              `json:"pending"`
+	}
+
+	if err := decodeParams(p, &params); err != nil {
+		return nil, err
+	}
+
+	if params.From == "" || params.To == "" || params.Amount == 0 {
+		return nil, &Error{InvalidParams, "from, to and a positive amount are required"}
+	}
+
+	if blockchain.NeedsSignatures(params.From) {
+		return nil, &Error{InvalidParams, "sending from " + params.From + " needs signatures, use sendrawtransaction"}
+	}
+
+	cs, err := blockchain.NewCoinSelector(params.CoinSelect)
+	if err != nil {
+		return nil, &Error{InvalidParams, err.Error()}
+	}
+
+	// Adding a block changes the chain so take the write lock
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Create the transaction, locking it if asked, and send it
+	tx := blockchain.NewTransaction(params.From, []blockchain.Recipient{{Address: params.To, Amount: params.Amount}}, cs, s.Chain)
+
+	if params.LockTime != 0 {
+		tx.SetLockTime(params.LockTime)
+	}
+
+	return sendTx(s, tx, params.Pending)
+}
+
+// sendmany pays several addresses from one address in a single transaction and mines it into a block,
+// params {"from": FROM, "to": [{"address": ADDRESS, "amount": AMOUNT}, ...], "coinselect": SELECTOR, "pending": BOOL},
+// the coin selector and pending are optional. A pending transaction is added to the pending transactions instead.
+func sendMany(s *Server, p json.RawMessage) (interface{}, *Error) {
+	// Decode the params
+	var params struct {
+		From       string                 `json:"from"`
+		To         []blockchain.Recipient `json:"to"`
+		CoinSelect string                 `json:"coinselect"`
+		Pending    bool                   `json:"pending"`
+	}
+
+	if err := decodeParams(p, &params); err != nil {
+		return nil, err
+	}
+
+	if params.From == "" || len(params.To) == 0 {
+		return nil, &Error{InvalidParams, "from and at least one recipient are required"}
+	}
+
+	if blockchain.NeedsSignatures(params.From) {
+		return nil, &Error{InvalidParams, "sending from " + params.From + " needs signatures, use sendrawtransaction"}
+	}
+
+	for _, r := range params.To {
+		if r.Address == "" || r.Amount == 0 {
+			return nil, &Error{InvalidParams, "every recipient needs an address and a positive amount"}
+		}
+	}
+
+	cs, err := blockchain.NewCoinSelector(params.CoinSelect)
+	if err != nil {
+		return nil, &Error{InvalidParams, err.Error()}
+	}
+
+	// Adding a block changes the chain so take the write lock
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Create the transaction and send it
+	tx := blockchain.NewTransaction(params.From, params.To, cs, s.Chain)
+
+	return sendTx(s, tx, params.Pending)
+}
+
+// sendrawtransaction mines a finalised transaction into a block, params {"tx": HEX, "pending": BOOL} with the
+// transaction's canonical encoding in hex, as printed by finalizepsbt, pending is optional. A pending transaction,
+// or one locked past the next block, is added to the pending transactions instead.
+func sendRawTransaction(s *Server, p json.RawMessage) (interface{}, *Error) {
+	// Decode the params
+	var params struct {
+		Tx      string `json:"tx"`
+		Pending bool   `json:"pending"`
+	}
+
+	if err := decodeParams(p, &params); err != nil {
+		return nil, err
+	}
+
+	data, err := hex.DecodeString(params.Tx)
+	if err != nil || len(data) == 0 {
+		return nil, &Error{InvalidParams, "tx must be a hex encoded transaction"}
+	}
+
+	tx, err := blockchain.DeserialiseTransaction(data)
+	if err != nil {
+		return nil, &Error{InvalidParams, err.Error()}
+	}
+
+	// Adding a block changes the chain so take the write lock
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return sendTx(s, tx, params.Pending)
+}
+
+// Mines a transaction into a block, or adds it to the pending transactions if p is set or it is locked past the
+// next block. It is checked first, so one that isn't valid is reported rather than stopping the chain. The caller
+// holds the write lock.
+func sendTx(s *Server, tx *blockchain.Transaction, p bool) (interface{}, *Error) {
+	if p || s.Chain.IsFinal(tx) == false {
+		if err := s.Chain.AcceptTransaction(tx); err != nil {
+			return nil, &Error{ChainError, err.Error()}
+		}
+
+		return SendResult{hex.EncodeToString(tx.ID), ""}, nil
+	}
+
+	if err := s.Chain.CheckTransaction(tx); err != nil {
+		return nil, &Error{ChainError, err.Error()}
+	}
+
+	s.Chain.AppendBlock([]*blockchain.Transaction{tx})
+
+	return SendResult{hex.EncodeToString(tx.ID), hex.EncodeToString(s.Chain.LatestHash)}, nil
+}
+
+// getmempool returns the pending transactions in the order they arrived, which is the order they are mined in once
+// they are final
+func getMempool(s *Server, p json.RawMessage) (interface{}, *Error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	ts := s.Chain.Mempool()
+
+	// Always return a list, even if it is empty
+	if ts == nil {
+		ts = []*blockchain.Transaction{}
+	}
+
+	return ts, nil
+}
+
+// createwallet generates a key and saves it to a key file in the server's wallet directory, returning the key hash
+// address coins can be sent to it at, params {"name": NAME}. The name is the key file's name, it can't be a path
+// and can't already be taken.
+func createWallet(s *Server, p json.RawMessage) (interface{}, *Error) {
+	// Decode the params
+	var params struct {
+		Name string `json:"name"`
+	}
+
+	if err := decodeParams(p, &params); err != nil {
+		return nil, err
+	}
+
+	f, rerr := serverFile(s.WalletDir, params.Name)
+	if rerr != nil {
+		return nil, rerr
+	}
+
+	// Never overwrite a key, the coins sent to it would be lost
+	if _, err := os.Stat(f); err == nil {
+		return nil, &Error{InvalidParams, "wallet " + params.Name + " already exists"}
+	}
+
+	pk, k, err := blockchain.GenerateKey()
+	if err != nil {
+		return nil, &Error{InternalError, err.Error()}
+	}
+
+	err = blockchain.SaveKey(f, k)
+	if err != nil {
+		return nil, &Error{InternalError, err.Error()}
+	}
+
+	return WalletResult{params.Name, hex.EncodeToString(pk), blockchain.KeyHashAddress(pk)}, nil
+}
+
+// Returns the path of a file named by a client inside one of the server's directories, creating the directory if
+// it doesn't exist. Clients can only name files, never paths, so they can't touch anything outside it.
+func serverFile(d, n string) (string, *Error) {
+	if d == "" {
+		return "", &Error{ChainError, "the server has no directory set for this"}
+	}
+
+	if n == "" || n == "." || n == ".." || strings.ContainsAny(n, `/\`) || filepath.IsAbs(n) {
+		return "", &Error{InvalidParams, "name must be a plain file name, not a path"}
+	}
+
+	err := os.MkdirAll(d, 0700)
+	if err != nil {
+		return "", &Error{InternalError, err.Error()}
+	}
+
+	return filepath.Join(d, n), nil
+}
+
+// snapshot writes a consistent backup of the database to a file in the server's backup directory, params
+// {"file": NAME}. The name can't be a path, and the result holds the path the snapshot was written to.
+func snapshot(s *Server, p json.RawMessage) (interface{}, *Error) {
+	// Decode the params
+	var params struct {
+		File string `json:"file"`
+	}
+
+	if err := decodeParams(p, &params); err != nil {
+		return nil, err
+	}
+
+	fp, rerr := serverFile(s.BackupDir, params.File)
+	if rerr != nil {
+		return nil, rerr
+	}
+
+	// The backup is consistent by itself, the read lock keeps the latest hash reported in step with it
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	// Create the file and write the snapshot to it
+	f, err := os.Create(fp)
+	if err != nil {
+		return nil, &Error{ChainError, err.Error()}
+	}
+
+	defer f.Close()
+
+	err = s.Chain.Snapshot(f)
+	if err != nil {
+		return nil, &Error{ChainError, err.Error()}
+	}
+
+	return SnapshotResult{fp, hex.EncodeToString(s.Chain.LatestHash), s.Chain.Height()}, nil
+}
+
+// generate mines blocks paying the rewards to an address along with any pending transactions,
+// params {"blocks": N, "address": ADDRESS}
+func generate(s *Server, p json.RawMessage) (interface{}, *Error) {
+	// Decode the params
+	var params struct {
+		Blocks  int    `json:"blocks"`
+		Address string `json:"address"`
+	}
+
+	if err := decodeParams(p, &params); err != nil {
+		return nil, err
+	}
+
+	if params.Address == "" || params.Blocks <= 0 {
+		return nil, &Error{InvalidParams, "address and a positive number of blocks are required"}
+	}
+
+	// Adding blocks changes the chain so take the write lock
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Mine the blocks, failing if any of them can't be added
+	hs, err := s.Chain.Generate(params.Address, params.Blocks)
+	if errors.Is(err, blockchain.ErrNotRegtest) {
+		return nil, &Error{ChainError, err.Error()}
+	}
+
+	if err != nil {
+		return nil, &Error{InternalError, err.Error()}
+	}
+
+	r := GenerateResult{[]string{}, s.Chain.Height()}
+	for _, h := range hs {
+		r.Blocks = append(r.Blocks, hex.EncodeToString(h))
+	}
+
+	return r, nil
+}
@@ -0,0 +1,159 @@
+// Package rpc exposes a running blockchain over HTTP using JSON-RPC 2.0, along with a client to call it.
+package rpc
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/liamcf44/go-blockchain.git/blockchain"
+)
+
+// Standard JSON-RPC 2.0 error codes, along with the code used for errors from the chain itself
+const (
+	ParseError     = -32700
+	InvalidRequest = -32600
+	MethodNotFound = -32601
+	InvalidParams  = -32602
+	InternalError  = -32603
+	ChainError     = -32000
+)
+
+// Request is a JSON-RPC 2.0 request, a request without an ID is a notification and gets no response
+type Request struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	ID      json.RawMessage `json:"id,omitempty"`
+}
+
+// Response is a JSON-RPC 2.0 response, holding either a result or an error. The result is always written on
+// success, even when it is null, as the spec needs one of the two.
+type Response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Result  interface{}     `json:"result"`
+	Error   *Error          `json:"error,omitempty"`
+	ID      json.RawMessage `json:"id"`
+}
+
+// Error is a JSON-RPC 2.0 error object
+type Error struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// Error allows an Error to be used as a Go error
+func (e *Error) Error() string {
+	return fmt.Sprintf("rpc error %d: %s", e.Code, e.Message)
+}
+
+// Server holds the chain being served along with the basic auth credentials needed to use it, and the directories
+// the key files of wallets and the snapshots made over RPC are saved in
+type Server struct {
+	Chain     *blockchain.BlockChain
+	User      string
+	Password  string
+	WalletDir string
+	BackupDir string
+
+	// Guards the chain, methods that add blocks take the write lock
+	mu sync.RWMutex
+}
+
+// NewServer creates a Server for a chain with the given credentials, saving wallets in the wallet directory and
+// snapshots in the backup directory
+func NewServer(bc *blockchain.BlockChain, u, p, wd, bd string) *Server {
+	return &Server{Chain: bc, User: u, Password: p, WalletDir: wd, BackupDir: bd}
+}
+
+// ListenAndServe serves JSON-RPC requests on the given address until an error occurs
+func (s *Server) ListenAndServe(addr string) error {
+	return http.ListenAndServe(addr, s)
+}
+
+// ServeHTTP handles a single JSON-RPC request posted over HTTP
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// Only POST requests can carry a JSON-RPC request
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "JSON-RPC requests must be POSTed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Check the basic auth credentials, comparing in constant time
+	u, p, ok := r.BasicAuth()
+	if !ok || subtle.ConstantTimeCompare([]byte(u), []byte(s.User)) != 1 || subtle.ConstantTimeCompare([]byte(p), []byte(s.Password)) != 1 {
+		w.Header().Set("WWW-Authenticate", `Basic realm="go-blockchain"`)
+		http.Error(w, "Unauthorised", http.StatusUnauthorized)
+		return
+	}
+
+	// Decode the request, responding with a parse error if it isn't valid JSON
+	var req Request
+
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		writeResponse(w, Response{"2.0", nil, &Error{ParseError, err.Error()}, nil})
+		return
+	}
+
+	// Check the request is a valid JSON-RPC 2.0 request
+	if req.JSONRPC != "2.0" || req.Method == "" {
+		writeResponse(w, Response{"2.0", nil, &Error{InvalidRequest, "request must be JSON-RPC 2.0 with a method"}, req.ID})
+		return
+	}
+
+	// Call the method
+	res, rerr := s.call(req.Method, req.Params)
+
+	// Notifications get no response
+	if req.ID == nil {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	writeResponse(w, Response{"2.0", res, rerr, req.ID})
+}
+
+// Writes a response as JSON
+func writeResponse(w http.ResponseWriter, res Response) {
+	w.Header().Set("Content-Type", "application/json")
+
+	err := json.NewEncoder(w).Encode(res)
+	blockchain.HandleError(err)
+}
+
+// Calls the named method with the given params, turning any panic from the chain into an error
+func (s *Server) call(m string, p json.RawMessage) (res interface{}, rerr *Error) {
+	// Look up the method
+	f, ok := methods[m]
+	if !ok {
+		return nil, &Error{MethodNotFound, fmt.Sprintf("method %q not found", m)}
+	}
+
+	// The chain panics on errors, so recover and report them as internal errors
+	defer func() {
+		if r := recover(); r != nil {
+			res = nil
+			rerr = &Error{InternalError, fmt.Sprint(r)}
+		}
+	}()
+
+	return f(s, p)
+}
+
+// Decodes the params of a request into v, an empty params is left as v's zero value
+func decodeParams(p json.RawMessage, v interface{}) *Error {
+	if len(p) == 0 {
+		return nil
+	}
+
+	err := json.Unmarshal(p, v)
+	if err != nil {
+		return &Error{InvalidParams, err.Error()}
+	}
+
+	return nil
+}